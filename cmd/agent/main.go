@@ -6,16 +6,38 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	cl "github.com/c2pc/go-musthave-metrics/internal/client"
+	"github.com/c2pc/go-musthave-metrics/internal/codec"
 	config "github.com/c2pc/go-musthave-metrics/internal/config/agent"
+	"github.com/c2pc/go-musthave-metrics/internal/database"
 	"github.com/c2pc/go-musthave-metrics/internal/logger"
 	"github.com/c2pc/go-musthave-metrics/internal/metric"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
 	"github.com/c2pc/go-musthave-metrics/internal/reporter"
 )
 
+const (
+	collectorRuntime  = "runtime"
+	collectorExpvar   = "expvar"
+	collectorPostgres = "postgres"
+	collectorProbe    = "probe"
+	collectorLogs     = "logs"
+)
+
+type Client interface {
+	reporter.Updater
+	reporter.SettingsFetcher
+	SetServerAddress(serverAddr string)
+	SetToken(token string)
+	SetMetaDefaults(ctx context.Context, metas []model.MetricMeta) error
+}
+
 type Reporter interface {
 	Run(context.Context)
+	Configure(settings reporter.Settings)
+	WatchSettings(ctx context.Context, fetcher reporter.SettingsFetcher, agentID string, interval time.Duration)
 }
 
 func main() {
@@ -33,30 +55,190 @@ func main() {
 		logger.Log.Fatal("failed to parse config", logger.Error(err))
 	}
 
-	counterMetric := metric.NewCounterMetric()
-	gaugeMetric := metric.NewGaugeMetric()
+	collectors := []reporter.Collector{{
+		Name:     collectorRuntime,
+		Counters: []reporter.MetricReader[int64]{metric.NewCounterMetric()},
+		Gauges:   []reporter.MetricReader[float64]{metric.NewGaugeMetric()},
+	}}
+
+	if len(cfg.ExpvarEndpoints) > 0 {
+		endpoints := make([]metric.ExpvarEndpoint, len(cfg.ExpvarEndpoints))
+		for i, e := range cfg.ExpvarEndpoints {
+			endpoints[i], err = metric.ParseExpvarEndpoint(e)
+			if err != nil {
+				logger.Log.Fatal("failed to parse expvar endpoint", logger.Error(err))
+			}
+		}
+
+		collectors = append(collectors, reporter.Collector{
+			Name: collectorExpvar,
+			Gauges: []reporter.MetricReader[float64]{metric.NewExpvarMetric(metric.ExpvarConfig{
+				Endpoints: endpoints,
+				Include:   cfg.ExpvarInclude,
+				Exclude:   cfg.ExpvarExclude,
+			})},
+		})
+	}
+
+	if cfg.PostgresDSN != "" {
+		db, err := database.New(cfg.PostgresDSN)
+		if err != nil {
+			logger.Log.Fatal("failed to connect to postgres", logger.Error(err))
+		}
+		defer db.Close()
+
+		collectors = append(collectors, reporter.Collector{
+			Name:     collectorPostgres,
+			Counters: []reporter.MetricReader[int64]{metric.NewPostgresCounterMetric(db)},
+			Gauges:   []reporter.MetricReader[float64]{metric.NewPostgresGaugeMetric(db)},
+		})
+	}
+
+	if len(cfg.Probes) > 0 {
+		checks := make([]metric.ProbeCheck, len(cfg.Probes))
+		for i, p := range cfg.Probes {
+			checks[i] = metric.ProbeCheck{
+				Name:           p.Name,
+				URL:            p.URL,
+				Method:         p.Method,
+				ExpectedStatus: p.ExpectedStatus,
+				BodyRegex:      p.BodyRegex,
+				Timeout:        p.Timeout.Duration,
+				SkipTLSVerify:  p.SkipTLSVerify,
+			}
+		}
+
+		probeMetric, err := metric.NewProbeMetric(checks, cfg.ProbeConcurrency)
+		if err != nil {
+			logger.Log.Fatal("failed to initialize probes", logger.Error(err))
+		}
+		collectors = append(collectors, reporter.Collector{
+			Name:   collectorProbe,
+			Gauges: []reporter.MetricReader[float64]{probeMetric},
+		})
+	}
+
+	if len(cfg.LogFiles) > 0 {
+		files := make([]metric.LogFile, len(cfg.LogFiles))
+		for i, f := range cfg.LogFiles {
+			files[i] = metric.LogFile{Path: f.Path}
+			for _, rule := range f.Rules {
+				files[i].Rules = append(files[i].Rules, metric.LogRule{
+					Name:    rule.Name,
+					Pattern: rule.Pattern,
+					Type:    rule.Type,
+					Group:   rule.Group,
+				})
+			}
+		}
 
-	client := cl.NewClient(cfg.ServerAddress)
+		logTail, err := metric.NewLogTail(metric.LogTailConfig{
+			Files:     files,
+			StatePath: cfg.LogStatePath,
+		})
+		if err != nil {
+			logger.Log.Fatal("failed to initialize log tailing", logger.Error(err))
+		}
+		defer logTail.Close()
 
-	var report Reporter = reporter.New(client, reporter.Timer{
-		PollInterval:   cfg.PollInterval,
-		ReportInterval: cfg.ReportInterval,
-	}, counterMetric, gaugeMetric)
+		collectors = append(collectors, reporter.Collector{
+			Name:     collectorLogs,
+			Counters: []reporter.MetricReader[int64]{logTail.Counters()},
+			Gauges:   []reporter.MetricReader[float64]{logTail.Gauges()},
+		})
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	payloadCodec, _ := codec.ByName(cfg.Encoding)
+
+	var clientOptions []cl.Option
+	if cfg.TLS() {
+		tlsConfig, err := cl.LoadTLSConfig(cfg.TLSCA, cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			logger.Log.Fatal("failed to load tls config", logger.Error(err))
+		}
+		clientOptions = append(clientOptions, cl.WithTLS(tlsConfig))
+	}
+
+	var client Client
+	if cfg.Stream {
+		client = cl.NewStreamClient(ctx, cfg.ServerAddress, cfg.AgentID, payloadCodec, clientOptions...)
+	} else {
+		client = cl.NewClient(cfg.ServerAddress, cfg.AgentID, payloadCodec, clientOptions...)
+	}
+	client.SetToken(cfg.Token)
+
+	var report Reporter = reporter.New(client, reporterSettings(cfg), collectors...)
+
+	if cfg.SettingsInterval > 0 {
+		go report.WatchSettings(ctx, client, cfg.AgentID, time.Duration(cfg.SettingsInterval)*time.Second)
+	}
+
 	go report.Run(ctx)
+	go shipMeta(ctx, client, metric.RuntimeMeta)
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-quit:
 			return
+		case <-reload:
+			newCfg, err := config.Parse()
+			if err != nil {
+				logger.Log.Info("Failed to reload config, keeping the current one", logger.Error(err))
+				continue
+			}
+
+			if fields := cfg.RestartRequired(newCfg); len(fields) > 0 {
+				logger.Log.Info("Config changes require a restart to take effect", logger.Any("fields", fields))
+			}
+
+			client.SetServerAddress(newCfg.ServerAddress)
+			client.SetToken(newCfg.Token)
+			report.Configure(reporterSettings(newCfg))
+
+			logger.Log.Info("Config reloaded")
+		}
+	}
+}
+
+func reporterSettings(cfg *config.Config) reporter.Settings {
+	return reporter.Settings{
+		Timer: reporter.Timer{
+			PollInterval:   cfg.PollInterval,
+			ReportInterval: cfg.ReportInterval,
+		},
+		Include: cfg.Include,
+		Exclude: cfg.Exclude,
+	}
+}
+
+// shipMeta sends the metadata of the built-in metrics as defaults, so the
+// metadata edited on the server is kept, retrying until the server accepts
+// it.
+func shipMeta(ctx context.Context, client Client, metas []model.MetricMeta) {
+	delay := time.Second
+	for {
+		err := client.SetMetaDefaults(ctx, metas)
+		if err == nil {
+			return
+		}
+		logger.Log.Info("Failed to send metrics metadata", logger.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
 		}
+		delay = min(delay*2, time.Minute)
 	}
 }
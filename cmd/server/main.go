@@ -2,25 +2,45 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"errors"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/c2pc/go-musthave-metrics/internal/agentconfig"
+	"github.com/c2pc/go-musthave-metrics/internal/alert"
+	"github.com/c2pc/go-musthave-metrics/internal/auth"
 	config "github.com/c2pc/go-musthave-metrics/internal/config/server"
 	"github.com/c2pc/go-musthave-metrics/internal/database"
 	"github.com/c2pc/go-musthave-metrics/internal/database/migrate"
+	"github.com/c2pc/go-musthave-metrics/internal/forward"
+	"github.com/c2pc/go-musthave-metrics/internal/graphite"
 	"github.com/c2pc/go-musthave-metrics/internal/handler"
+	"github.com/c2pc/go-musthave-metrics/internal/handler/middleware"
 	"github.com/c2pc/go-musthave-metrics/internal/logger"
 	"github.com/c2pc/go-musthave-metrics/internal/server"
 	"github.com/c2pc/go-musthave-metrics/internal/storage"
 	"github.com/c2pc/go-musthave-metrics/internal/sync"
 )
 
+const (
+	// batchTTL is how long applied batches are remembered, it must exceed the
+	// time an agent keeps retrying a batch.
+	batchTTL               = 10 * time.Minute
+	historyCompactInterval = time.Minute
+	metricExpireInterval   = 10 * time.Second
+)
+
 func main() {
 	err := logger.Initialize("info")
 	if err != nil {
@@ -39,6 +59,10 @@ func main() {
 		logger.Log.Fatal("failed to parse config", logger.Error(err))
 	}
 
+	if err := logger.SetLevel(cfg.LogLevel); err != nil {
+		logger.Log.Fatal("failed to set log level", logger.Error(err))
+	}
+
 	var db *database.DB
 	var memoryType storage.Type
 	if cfg.DatabaseDSN == "" {
@@ -71,11 +95,18 @@ func main() {
 		logger.Log.Fatal("failed to initialize counterStorage", logger.Error(err))
 	}
 
+	metaStorage, err := storage.NewMetaStorage(memoryType, db)
+	if err != nil {
+		logger.Log.Fatal("failed to initialize metaStorage", logger.Error(err))
+	}
+
+	var syncer *sync.Sync
 	if cfg.FileStoragePath != "" && cfg.DatabaseDSN == "" {
-		syncer, err := sync.Start(ctx, sync.Config{
+		syncer, err = sync.Start(ctx, sync.Config{
 			StoreInterval:   cfg.StoreInterval,
 			FileStoragePath: cfg.FileStoragePath,
 			Restore:         cfg.Restore,
+			Meta:            metaStorage,
 		}, gaugeStorage, counterStorage)
 		if err != nil {
 			logger.Log.Fatal("failed to start syncer", logger.Error(err))
@@ -83,9 +114,104 @@ func main() {
 		defer syncer.Close()
 	}
 
-	handlers := handler.NewHandler(gaugeStorage, counterStorage, db)
+	batchStorage, err := storage.NewBatchStorage(memoryType, db, batchTTL, gaugeStorage, counterStorage)
+	if err != nil {
+		logger.Log.Fatal("failed to initialize batchStorage", logger.Error(err))
+	}
+
+	historyStorage, err := storage.NewHistoryStorage(memoryType, db, cfg.HistorySize, cfg.Retention)
+	if err != nil {
+		logger.Log.Fatal("failed to initialize historyStorage", logger.Error(err))
+	}
+	go compactHistory(ctx, historyStorage)
+
+	metricTTL := &atomic.Int64{}
+	metricTTL.Store(int64(cfg.MetricTTL))
+	go expireMetrics(ctx, metricTTL, gaugeStorage, counterStorage)
+
+	if cfg.AlertRulesPath != "" {
+		alertCfg, err := alert.Load(cfg.AlertRulesPath)
+		if err != nil {
+			logger.Log.Fatal("failed to load alert rules", logger.Error(err))
+		}
+		notifier := alert.NewNotifier(alertCfg.Webhooks, []time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second})
+		go notifier.Run(ctx)
+		go alert.NewEngine(alertCfg.Rules, gaugeStorage, counterStorage, notifier).Run(ctx, alertCfg.Interval.Duration)
+	}
 
-	httpServer := server.NewServer(handlers, cfg.Address)
+	rateLimiter := middleware.NewRateLimiter(cfg.RateLimit)
+	handlerOptions := []handler.Option{
+		handler.WithRateLimiter(rateLimiter),
+		handler.WithBatchRegistry(batchStorage),
+		handler.WithHistory(historyStorage),
+		handler.WithMeta(metaStorage),
+	}
+	if cfg.AgentConfigPath != "" {
+		agentSettings, err := agentconfig.Load(cfg.AgentConfigPath)
+		if err != nil {
+			logger.Log.Fatal("failed to load agent config", logger.Error(err))
+		}
+		handlerOptions = append(handlerOptions, handler.WithAgentSettings(agentSettings))
+	}
+	if cfg.ForwardPath != "" {
+		forwardCfg, err := forward.Load(cfg.ForwardPath)
+		if err != nil {
+			logger.Log.Fatal("failed to load forward config", logger.Error(err))
+		}
+		forwarder := forward.New(forwardCfg, []time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second})
+		go forwarder.Run(ctx)
+		handlerOptions = append(handlerOptions, handler.WithForwarder(forwarder))
+	}
+	if cfg.AuthTokensPath != "" || cfg.AuthDB {
+		var tokens []auth.Token
+		if cfg.AuthTokensPath != "" {
+			authCfg, err := auth.Load(cfg.AuthTokensPath)
+			if err != nil {
+				logger.Log.Fatal("failed to load auth tokens", logger.Error(err))
+			}
+			tokens = authCfg.Tokens
+		}
+		var tokenDB auth.Querier
+		if cfg.AuthDB {
+			tokenDB = db
+		}
+		handlerOptions = append(handlerOptions, handler.WithAuth(middleware.NewAuth(auth.New(tokens, tokenDB))))
+	}
+
+	if cfg.TLSAgentCN {
+		handlerOptions = append(handlerOptions, handler.WithClientCertAgentID())
+	}
+
+	handlers := handler.NewHandler(gaugeStorage, counterStorage, db, handlerOptions...)
+
+	var serverOptions []server.Option
+	if cfg.TLS() {
+		tlsConfig, err := serverTLSConfig(cfg)
+		if err != nil {
+			logger.Log.Fatal("failed to load tls config", logger.Error(err))
+		}
+		serverOptions = append(serverOptions, server.WithTLS(tlsConfig))
+	}
+
+	httpServer := server.NewServer(handlers, cfg.Address, serverOptions...)
+
+	var graphiteServer *graphite.Server
+	if cfg.GraphiteAddress != "" {
+		graphiteCfg := graphite.DefaultConfig()
+		if cfg.GraphitePath != "" {
+			graphiteCfg, err = graphite.Load(cfg.GraphitePath)
+			if err != nil {
+				logger.Log.Fatal("failed to load graphite config", logger.Error(err))
+			}
+		}
+
+		graphiteServer, err = graphite.Listen(cfg.GraphiteAddress, graphiteCfg, handlers)
+		if err != nil {
+			logger.Log.Fatal("failed to start graphite listener", logger.Error(err))
+		}
+		logger.Log.Info("Starting Graphite listener", logger.Any("address", cfg.GraphiteAddress))
+		go graphiteServer.Serve()
+	}
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
@@ -98,13 +224,135 @@ func main() {
 		}
 	}()
 
-	<-quit
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
+	for running := true; running; {
+		select {
+		case <-quit:
+			running = false
+		case <-reload:
+			newCfg, err := config.Parse()
+			if err != nil {
+				logger.Log.Info("Failed to reload config, keeping the current one", logger.Error(err))
+				continue
+			}
+
+			if fields := cfg.RestartRequired(newCfg); len(fields) > 0 {
+				logger.Log.Info("Config changes require a restart to take effect", logger.Any("fields", fields))
+			}
+
+			if err := logger.SetLevel(newCfg.LogLevel); err != nil {
+				logger.Log.Info("Failed to set log level", logger.Error(err))
+			}
+			if syncer != nil {
+				if err := syncer.SetStoreInterval(newCfg.StoreInterval); err != nil {
+					logger.Log.Info("Failed to set store interval", logger.Error(err))
+				}
+			}
+			rateLimiter.SetLimit(newCfg.RateLimit)
+			metricTTL.Store(int64(newCfg.MetricTTL))
+
+			logger.Log.Info("Config reloaded")
+		}
+	}
 
 	const timeout = 5 * time.Second
 	ctx2, shutdown := context.WithTimeout(ctx, timeout)
 	defer shutdown()
 
+	handlers.Close()
 	if err := httpServer.Stop(ctx2); err != nil {
 		logger.Log.Info("Failed to Stop Server", logger.Error(err))
 	}
+	if graphiteServer != nil {
+		if err := graphiteServer.Stop(ctx2); err != nil {
+			logger.Log.Info("Failed to Stop Graphite listener", logger.Error(err))
+		}
+	}
+}
+
+func compactHistory(ctx context.Context, history *storage.HistoryStorage) {
+	ticker := time.NewTicker(historyCompactInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := history.Compact(ctx, time.Now()); err != nil {
+				logger.Log.Info("Failed to compact history", logger.Error(err))
+			}
+		}
+	}
+}
+
+type expirer interface {
+	GetName() string
+	Expire(ctx context.Context, before time.Time) ([]string, error)
+}
+
+// expireMetrics deletes the metrics that were not updated within the ttl, a
+// zero ttl keeps them.
+func expireMetrics(ctx context.Context, ttl *atomic.Int64, storages ...expirer) {
+	ticker := time.NewTicker(metricExpireInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ttl := time.Duration(ttl.Load())
+			if ttl == 0 {
+				continue
+			}
+
+			for _, s := range storages {
+				keys, err := s.Expire(ctx, time.Now().Add(-ttl))
+				if err != nil {
+					logger.Log.Info("Failed to expire metrics", logger.Any("type", s.GetName()), logger.Error(err))
+					continue
+				}
+				if len(keys) > 0 {
+					logger.Log.Info("Expired metrics", logger.Any("type", s.GetName()), logger.Any("keys", keys))
+				}
+			}
+		}
+	}
+}
+
+func serverTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+	if cfg.TLSSelfSigned {
+		hosts := []string{"localhost", "127.0.0.1", "::1"}
+		if host, _, err := net.SplitHostPort(cfg.Address); err == nil && host != "" {
+			hosts = append(hosts, host)
+		}
+
+		cert, err = server.SelfSignedCertificate("go-musthave-metrics", hosts...)
+		if err != nil {
+			return nil, err
+		}
+
+		fingerprint := sha256.Sum256(cert.Certificate[0])
+		logger.Log.Info("Serving a self-signed certificate, for development only", logger.Any("sha256", hex.EncodeToString(fingerprint[:])))
+	} else {
+		cert, err = tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var clientCAs *x509.CertPool
+	if cfg.TLSClientCA != "" {
+		clientCAs, err = server.LoadCertPool(cfg.TLSClientCA)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return server.NewTLSConfig(cert, clientCAs), nil
 }
@@ -0,0 +1,60 @@
+package agentconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+)
+
+type Config struct {
+	Default model.AgentSettings            `json:"default"`
+	Agents  map[string]model.AgentSettings `json:"agents"`
+}
+
+type Store struct {
+	cfg Config
+}
+
+func Load(path string) (*Store, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse agent config: %s", err)
+	}
+
+	if err := validate(cfg.Default); err != nil {
+		return nil, fmt.Errorf("default: %s", err)
+	}
+	for id, settings := range cfg.Agents {
+		if err := validate(settings); err != nil {
+			return nil, fmt.Errorf("agent %s: %s", id, err)
+		}
+	}
+
+	return &Store{cfg: cfg}, nil
+}
+
+// Get returns the settings of the agent, or the default ones when the agent
+// has no settings of its own.
+func (s *Store) Get(agentID string) model.AgentSettings {
+	if settings, ok := s.cfg.Agents[agentID]; ok {
+		return settings
+	}
+	return s.cfg.Default
+}
+
+func validate(settings model.AgentSettings) error {
+	if settings.PollInterval < 0 {
+		return fmt.Errorf("invalid poll_interval: %d", settings.PollInterval)
+	}
+	if settings.ReportInterval < 0 {
+		return fmt.Errorf("invalid report_interval: %d", settings.ReportInterval)
+	}
+	return nil
+}
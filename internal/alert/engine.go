@@ -0,0 +1,198 @@
+package alert
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/c2pc/go-musthave-metrics/internal/logger"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+type State string
+
+const (
+	StateInactive State = "inactive"
+	StatePending  State = "pending"
+	StateFiring   State = "firing"
+)
+
+type Getter[T float64 | int64] interface {
+	Get(ctx context.Context, key string) (T, error)
+}
+
+type Sender interface {
+	Notify(notification Notification)
+}
+
+// Alert is the current state of a rule.
+type Alert struct {
+	Rule  string
+	State State
+	Since time.Time
+	Value *float64
+}
+
+type ruleState struct {
+	state State
+	since time.Time
+	// value is the value seen on the last evaluation, nil when the metric
+	// was absent.
+	value *float64
+}
+
+type Engine struct {
+	rules    []Rule
+	gauges   Getter[float64]
+	counters Getter[int64]
+	sender   Sender
+	mu       sync.Mutex
+	states   map[string]*ruleState
+}
+
+func NewEngine(rules []Rule, gauges Getter[float64], counters Getter[int64], sender Sender) *Engine {
+	states := make(map[string]*ruleState, len(rules))
+	for _, rule := range rules {
+		states[rule.Name] = &ruleState{state: StateInactive}
+	}
+
+	return &Engine{
+		rules:    rules,
+		gauges:   gauges,
+		counters: counters,
+		sender:   sender,
+		mu:       sync.Mutex{},
+		states:   states,
+	}
+}
+
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.Evaluate(ctx, time.Now())
+		}
+	}
+}
+
+// Evaluate checks every rule once. A rule becomes pending when its condition
+// holds and fires when it keeps holding for the duration of the rule.
+func (e *Engine) Evaluate(ctx context.Context, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, rule := range e.rules {
+		value, found, err := e.get(ctx, rule)
+		if err != nil {
+			logger.Log.Info("Failed to evaluate alert rule", logger.Any("rule", rule.Name), logger.Error(err))
+			continue
+		}
+
+		state := e.states[rule.Name]
+		active := rule.active(value, found, state.value)
+
+		state.value = nil
+		if found {
+			state.value = &value
+		}
+
+		if !active {
+			if state.state == StateFiring {
+				e.sender.Notify(notification(rule, state, StatusResolved, now))
+			}
+			state.state = StateInactive
+			state.since = time.Time{}
+			continue
+		}
+
+		if state.state == StateInactive {
+			state.state = StatePending
+			state.since = now
+		}
+		if state.state == StatePending && now.Sub(state.since) >= rule.For.Duration {
+			state.state = StateFiring
+			e.sender.Notify(notification(rule, state, StatusFiring, now))
+		}
+	}
+}
+
+// Alerts returns the states of the rules sorted by name.
+func (e *Engine) Alerts() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := make([]Alert, 0, len(e.states))
+	for name, state := range e.states {
+		alerts = append(alerts, Alert{Rule: name, State: state.state, Since: state.since, Value: copyValue(state.value)})
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].Rule < alerts[j].Rule
+	})
+
+	return alerts
+}
+
+func (e *Engine) get(ctx context.Context, rule Rule) (float64, bool, error) {
+	var value float64
+	var err error
+	switch rule.Type {
+	case "counter":
+		var v int64
+		v, err = e.counters.Get(ctx, rule.Metric)
+		value = float64(v)
+	default:
+		value, err = e.gauges.Get(ctx, rule.Metric)
+	}
+
+	if errors.Is(err, storage.ErrNotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return value, true, nil
+}
+
+func notification(rule Rule, state *ruleState, status Status, now time.Time) Notification {
+	n := Notification{
+		Rule:        rule.Name,
+		Status:      status,
+		Type:        rule.Type,
+		Metric:      rule.Metric,
+		Condition:   rule.Condition,
+		Value:       copyValue(state.value),
+		Description: rule.Description,
+		Since:       state.since,
+		Time:        now,
+		Fingerprint: fingerprint(rule.Name, state.since),
+	}
+	if rule.Condition.compares() {
+		threshold := float64(rule.Threshold)
+		n.Threshold = &threshold
+	}
+	return n
+}
+
+// fingerprint identifies one firing of the rule.
+func fingerprint(rule string, since time.Time) string {
+	sum := sha256.Sum256([]byte(rule + "\x00" + since.UTC().Format(time.RFC3339Nano)))
+	return hex.EncodeToString(sum[:8])
+}
+
+func copyValue(value *float64) *float64 {
+	if value == nil {
+		return nil
+	}
+	v := *value
+	return &v
+}
@@ -0,0 +1,180 @@
+package alert_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/alert"
+	"github.com/c2pc/go-musthave-metrics/internal/duration"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+type getter[T float64 | int64] map[string]T
+
+func (g getter[T]) Get(_ context.Context, key string) (T, error) {
+	value, ok := g[key]
+	if !ok {
+		return 0, storage.ErrNotFound
+	}
+	return value, nil
+}
+
+type senderMock struct {
+	notifications []alert.Notification
+}
+
+func (s *senderMock) Notify(notification alert.Notification) {
+	s.notifications = append(s.notifications, notification)
+}
+
+func TestEngine_Evaluate(t *testing.T) {
+	gauges := getter[float64]{"HeapAlloc": 100}
+	counters := getter[int64]{"PollCount": 1}
+	sender := &senderMock{}
+
+	engine := alert.NewEngine([]alert.Rule{
+		{Name: "heap", Type: "gauge", Metric: "HeapAlloc", Condition: alert.ConditionGreater, Threshold: 500, For: duration.Duration{Duration: 2 * time.Minute}},
+		{Name: "stopped", Type: "counter", Metric: "PollCount", Condition: alert.ConditionNotIncreasing, For: duration.Duration{Duration: 5 * time.Minute}},
+		{Name: "missing", Type: "gauge", Metric: "Alloc", Condition: alert.ConditionAbsent},
+	}, gauges, counters, sender)
+
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	states := func() map[string]alert.State {
+		result := map[string]alert.State{}
+		for _, a := range engine.Alerts() {
+			result[a.Rule] = a.State
+		}
+		return result
+	}
+
+	t.Run("Initial", func(t *testing.T) {
+		engine.Evaluate(ctx, start)
+		assert.Equal(t, map[string]alert.State{"heap": alert.StateInactive, "stopped": alert.StateInactive, "missing": alert.StateFiring}, states())
+		require.Len(t, sender.notifications, 1)
+		assert.Equal(t, "missing", sender.notifications[0].Rule)
+		assert.Equal(t, alert.StatusFiring, sender.notifications[0].Status)
+		assert.Nil(t, sender.notifications[0].Value)
+		assert.Nil(t, sender.notifications[0].Threshold)
+	})
+
+	t.Run("Pending", func(t *testing.T) {
+		sender.notifications = nil
+		gauges["HeapAlloc"] = 600
+		gauges["Alloc"] = 1
+		engine.Evaluate(ctx, start.Add(time.Minute))
+		assert.Equal(t, map[string]alert.State{"heap": alert.StatePending, "stopped": alert.StatePending, "missing": alert.StateInactive}, states())
+		require.Len(t, sender.notifications, 1)
+		assert.Equal(t, "missing", sender.notifications[0].Rule)
+		assert.Equal(t, alert.StatusResolved, sender.notifications[0].Status)
+	})
+
+	t.Run("Back to inactive", func(t *testing.T) {
+		sender.notifications = nil
+		counters["PollCount"] = 2
+		engine.Evaluate(ctx, start.Add(2*time.Minute))
+		assert.Equal(t, alert.StateInactive, states()["stopped"])
+		assert.Empty(t, sender.notifications)
+	})
+
+	t.Run("Firing", func(t *testing.T) {
+		engine.Evaluate(ctx, start.Add(3*time.Minute))
+		assert.Equal(t, alert.StateFiring, states()["heap"])
+		require.Len(t, sender.notifications, 1)
+
+		n := sender.notifications[0]
+		assert.Equal(t, "heap", n.Rule)
+		assert.Equal(t, alert.StatusFiring, n.Status)
+		assert.Equal(t, start.Add(time.Minute), n.Since)
+		require.NotNil(t, n.Value)
+		assert.Equal(t, 600.0, *n.Value)
+		require.NotNil(t, n.Threshold)
+		assert.Equal(t, 500.0, *n.Threshold)
+	})
+
+	t.Run("Still firing", func(t *testing.T) {
+		sender.notifications = nil
+		engine.Evaluate(ctx, start.Add(4*time.Minute))
+		assert.Equal(t, alert.StateFiring, states()["heap"])
+		assert.Empty(t, sender.notifications)
+	})
+
+	t.Run("Resolved", func(t *testing.T) {
+		gauges["HeapAlloc"] = 200
+		counters["PollCount"] = 3
+		engine.Evaluate(ctx, start.Add(5*time.Minute))
+		assert.Equal(t, alert.StateInactive, states()["heap"])
+		require.Len(t, sender.notifications, 1)
+		assert.Equal(t, alert.StatusResolved, sender.notifications[0].Status)
+	})
+}
+
+func TestNotifier(t *testing.T) {
+	var mu sync.Mutex
+	var received []alert.Notification
+	var keys []string
+	failures := 2
+
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var n alert.Notification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = append(received, n)
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+	}))
+	defer flaky.Close()
+
+	rejecting := 0
+	rejected := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		rejecting++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer rejected.Close()
+
+	notifier := alert.NewNotifier([]string{flaky.URL, rejected.URL},
+		[]time.Duration{time.Millisecond, time.Millisecond, time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx)
+
+	firing := alert.Notification{Rule: "heap", Status: alert.StatusFiring, Fingerprint: "abc"}
+	resolved := alert.Notification{Rule: "heap", Status: alert.StatusResolved, Fingerprint: "abc"}
+	notifier.Notify(firing)
+	notifier.Notify(firing)
+	notifier.Notify(resolved)
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 2 && rejecting == 3
+	}, time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, alert.StatusFiring, received[0].Status)
+	assert.Equal(t, alert.StatusResolved, received[1].Status)
+	assert.Equal(t, []string{"abc-firing", "abc-resolved"}, keys)
+}
@@ -0,0 +1,154 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/c2pc/go-musthave-metrics/internal/logger"
+	"github.com/c2pc/go-musthave-metrics/internal/retry"
+)
+
+const (
+	notifyQueueSize = 100
+	notifyTimeout   = 10 * time.Second
+	// sentTTL is how long the delivered notifications are remembered to drop
+	// the duplicates.
+	sentTTL = 24 * time.Hour
+)
+
+type Status string
+
+const (
+	StatusFiring   Status = "firing"
+	StatusResolved Status = "resolved"
+)
+
+// Notification is posted as JSON to the webhooks. The firing and the
+// resolved notifications of the same alert share the fingerprint.
+type Notification struct {
+	Rule        string    `json:"rule"`
+	Status      Status    `json:"status"`
+	Type        string    `json:"type"`
+	Metric      string    `json:"metric"`
+	Condition   Condition `json:"condition"`
+	Threshold   *float64  `json:"threshold,omitempty"`
+	Value       *float64  `json:"value,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Since       time.Time `json:"since"`
+	Time        time.Time `json:"time"`
+	Fingerprint string    `json:"fingerprint"`
+}
+
+type Notifier struct {
+	urls          []string
+	delays        []time.Duration
+	client        *http.Client
+	notifications chan Notification
+	sent          map[string]time.Time
+}
+
+// NewNotifier creates a notifier posting to the urls, delays are the pauses
+// between the delivery attempts.
+func NewNotifier(urls []string, delays []time.Duration) *Notifier {
+	return &Notifier{
+		urls:          urls,
+		delays:        delays,
+		client:        &http.Client{Timeout: notifyTimeout},
+		notifications: make(chan Notification, notifyQueueSize),
+		sent:          make(map[string]time.Time),
+	}
+}
+
+// Notify queues the notification, it is dropped when the queue is full.
+func (n *Notifier) Notify(notification Notification) {
+	select {
+	case n.notifications <- notification:
+	default:
+		logger.Log.Info("Alert notification queue is full, dropping notification",
+			logger.Any("rule", notification.Rule), logger.Any("status", notification.Status))
+	}
+}
+
+func (n *Notifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification := <-n.notifications:
+			n.deliver(ctx, notification)
+		}
+	}
+}
+
+func (n *Notifier) deliver(ctx context.Context, notification Notification) {
+	now := time.Now()
+	for key, sent := range n.sent {
+		if now.Sub(sent) > sentTTL {
+			delete(n.sent, key)
+		}
+	}
+
+	// keep the conditions such as > readable
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(notification); err != nil {
+		logger.Log.Info("Failed to encode alert notification", logger.Error(err))
+		return
+	}
+	body := buf.Bytes()
+
+	for _, url := range n.urls {
+		key := url + " " + notification.Fingerprint + " " + string(notification.Status)
+		if _, ok := n.sent[key]; ok {
+			continue
+		}
+
+		err := retry.Retry(
+			func() error {
+				return n.post(ctx, url, notification.Fingerprint+"-"+string(notification.Status), body)
+			},
+			func(err error) bool {
+				var statusErr *retry.StatusError
+				if errors.As(err, &statusErr) {
+					return statusErr.Temporary()
+				}
+				return ctx.Err() == nil
+			},
+			n.delays,
+		)
+		if err != nil {
+			logger.Log.Info("Failed to send alert notification",
+				logger.Any("url", url), logger.Any("rule", notification.Rule), logger.Error(err))
+			continue
+		}
+
+		n.sent[key] = now
+	}
+}
+
+func (n *Notifier) post(ctx context.Context, url string, idempotencyKey string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// lets the receivers drop the notifications delivered twice
+	req.Header.Set("Idempotency-Key", idempotencyKey)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &retry.StatusError{Code: resp.StatusCode}
+	}
+
+	return nil
+}
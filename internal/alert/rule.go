@@ -0,0 +1,198 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c2pc/go-musthave-metrics/internal/duration"
+)
+
+const defaultInterval = 15 * time.Second
+
+type Condition string
+
+const (
+	ConditionGreater        Condition = ">"
+	ConditionGreaterOrEqual Condition = ">="
+	ConditionLess           Condition = "<"
+	ConditionLessOrEqual    Condition = "<="
+	ConditionEqual          Condition = "=="
+	ConditionNotEqual       Condition = "!="
+	// ConditionNotIncreasing is active while the value is not greater than on
+	// the previous evaluation, e.g. a counter that stopped increasing.
+	ConditionNotIncreasing Condition = "not_increasing"
+	// ConditionAbsent is active while the metric does not exist.
+	ConditionAbsent Condition = "absent"
+)
+
+func (c Condition) IsValid() bool {
+	switch c {
+	case ConditionGreater, ConditionGreaterOrEqual, ConditionLess, ConditionLessOrEqual,
+		ConditionEqual, ConditionNotEqual, ConditionNotIncreasing, ConditionAbsent:
+		return true
+	default:
+		return false
+	}
+}
+
+// compares reports whether the condition compares the value with the
+// threshold.
+func (c Condition) compares() bool {
+	return c != ConditionNotIncreasing && c != ConditionAbsent
+}
+
+var thresholdUnits = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"KB", 1 << 10},
+	{"MB", 1 << 20},
+	{"GB", 1 << 30},
+	{"TB", 1 << 40},
+	{"B", 1},
+}
+
+// Threshold is a number or a string with a byte size suffix, e.g. "500MB".
+type Threshold float64
+
+func (t *Threshold) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	switch value := v.(type) {
+	case float64:
+		*t = Threshold(value)
+		return nil
+	case string:
+		multiplier := 1.0
+		for _, unit := range thresholdUnits {
+			if number, ok := strings.CutSuffix(value, unit.suffix); ok {
+				value, multiplier = number, unit.multiplier
+				break
+			}
+		}
+
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return fmt.Errorf("invalid threshold: %s", err)
+		}
+		*t = Threshold(number * multiplier)
+		return nil
+	default:
+		return errors.New("invalid threshold")
+	}
+}
+
+type Rule struct {
+	Name        string            `json:"name"`
+	Type        string            `json:"type"`
+	Metric      string            `json:"metric"`
+	Condition   Condition         `json:"condition"`
+	Threshold   Threshold         `json:"threshold"`
+	For         duration.Duration `json:"for"`
+	Description string            `json:"description"`
+}
+
+func (r Rule) validate() error {
+	if r.Type != "gauge" && r.Type != "counter" {
+		return fmt.Errorf("invalid type: %s", r.Type)
+	}
+	if r.Metric == "" {
+		return errors.New("metric is empty")
+	}
+	if !r.Condition.IsValid() {
+		return fmt.Errorf("invalid condition: %s", r.Condition)
+	}
+	if r.For.Duration < 0 {
+		return errors.New("invalid for")
+	}
+	return nil
+}
+
+// active reports whether the condition of the rule holds. previous is the
+// value seen on the previous evaluation, nil when there was none.
+func (r Rule) active(value float64, found bool, previous *float64) bool {
+	switch r.Condition {
+	case ConditionAbsent:
+		return !found
+	case ConditionNotIncreasing:
+		return found && previous != nil && value <= *previous
+	}
+
+	if !found {
+		return false
+	}
+
+	threshold := float64(r.Threshold)
+	switch r.Condition {
+	case ConditionGreater:
+		return value > threshold
+	case ConditionGreaterOrEqual:
+		return value >= threshold
+	case ConditionLess:
+		return value < threshold
+	case ConditionLessOrEqual:
+		return value <= threshold
+	case ConditionEqual:
+		return value == threshold
+	case ConditionNotEqual:
+		return value != threshold
+	default:
+		return false
+	}
+}
+
+type Config struct {
+	Interval duration.Duration `json:"interval"`
+	Webhooks []string          `json:"webhooks"`
+	Rules    []Rule            `json:"rules"`
+}
+
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse alert rules: %s", err)
+	}
+
+	if cfg.Interval.Duration < 0 {
+		return nil, errors.New("invalid interval")
+	}
+	if cfg.Interval.Duration == 0 {
+		cfg.Interval.Duration = defaultInterval
+	}
+
+	for i, webhook := range cfg.Webhooks {
+		if webhook == "" {
+			return nil, fmt.Errorf("webhook %d: url is empty", i)
+		}
+	}
+
+	names := make(map[string]bool, len(cfg.Rules))
+	for i, rule := range cfg.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d: name is empty", i)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("rule %s: duplicate name", rule.Name)
+		}
+		names[rule.Name] = true
+
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("rule %s: %s", rule.Name, err)
+		}
+	}
+
+	return &cfg, nil
+}
@@ -0,0 +1,75 @@
+package alert_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/alert"
+	"github.com/c2pc/go-musthave-metrics/internal/duration"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    *alert.Config
+		wantErr bool
+	}{
+		{
+			name: "Valid",
+			data: `{
+				"interval": "30s",
+				"webhooks": ["http://localhost:9000/alerts"],
+				"rules": [
+					{"name": "heap", "type": "gauge", "metric": "HeapAlloc", "condition": ">", "threshold": "500MB", "for": "2m"},
+					{"name": "stopped", "type": "counter", "metric": "PollCount", "condition": "not_increasing", "for": 300}
+				]
+			}`,
+			want: &alert.Config{
+				Interval: duration.Duration{Duration: 30 * time.Second},
+				Webhooks: []string{"http://localhost:9000/alerts"},
+				Rules: []alert.Rule{
+					{Name: "heap", Type: "gauge", Metric: "HeapAlloc", Condition: alert.ConditionGreater, Threshold: 500 << 20, For: duration.Duration{Duration: 2 * time.Minute}},
+					{Name: "stopped", Type: "counter", Metric: "PollCount", Condition: alert.ConditionNotIncreasing, For: duration.Duration{Duration: 5 * time.Minute}},
+				},
+			},
+		},
+		{
+			name: "Default interval",
+			data: `{"rules": [{"name": "low", "type": "gauge", "metric": "Alloc", "condition": "<", "threshold": 1.5}]}`,
+			want: &alert.Config{
+				Interval: duration.Duration{Duration: 15 * time.Second},
+				Rules:    []alert.Rule{{Name: "low", Type: "gauge", Metric: "Alloc", Condition: alert.ConditionLess, Threshold: 1.5}},
+			},
+		},
+		{name: "Invalid JSON", data: `{`, wantErr: true},
+		{name: "Invalid threshold", data: `{"rules": [{"name": "a", "type": "gauge", "metric": "Alloc", "condition": ">", "threshold": "lots"}]}`, wantErr: true},
+		{name: "Invalid type", data: `{"rules": [{"name": "a", "type": "histogram", "metric": "Alloc", "condition": ">"}]}`, wantErr: true},
+		{name: "Invalid condition", data: `{"rules": [{"name": "a", "type": "gauge", "metric": "Alloc", "condition": "~"}]}`, wantErr: true},
+		{name: "Empty metric", data: `{"rules": [{"name": "a", "type": "gauge", "condition": ">"}]}`, wantErr: true},
+		{name: "Empty name", data: `{"rules": [{"type": "gauge", "metric": "Alloc", "condition": ">"}]}`, wantErr: true},
+		{name: "Duplicate name", data: `{"rules": [{"name": "a", "type": "gauge", "metric": "Alloc", "condition": ">"}, {"name": "a", "type": "gauge", "metric": "Alloc", "condition": "<"}]}`, wantErr: true},
+		{name: "Negative for", data: `{"rules": [{"name": "a", "type": "gauge", "metric": "Alloc", "condition": ">", "for": "-1m"}]}`, wantErr: true},
+		{name: "Empty webhook", data: `{"webhooks": [""]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "alerts.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.data), 0644))
+
+			got, err := alert.Load(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
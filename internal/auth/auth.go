@@ -0,0 +1,180 @@
+// Package auth checks the bearer tokens of the API. The tokens are defined
+// in a JSON file or in the api_tokens table, where only their SHA-256 hash
+// is stored.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Scope is what a token allows, every scope includes the ones before it.
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	ScopeAdmin Scope = "admin"
+)
+
+var scopeRanks = map[Scope]int{
+	ScopeRead:  1,
+	ScopeWrite: 2,
+	ScopeAdmin: 3,
+}
+
+func (s Scope) IsValid() bool {
+	_, ok := scopeRanks[s]
+	return ok
+}
+
+// Includes reports whether a token of the scope may do what the other
+// scope allows.
+func (s Scope) Includes(other Scope) bool {
+	return s.IsValid() && scopeRanks[s] >= scopeRanks[other]
+}
+
+type Token struct {
+	ID     string `json:"id"`
+	Secret string `json:"token"`
+	Scope  Scope  `json:"scope"`
+	// Prefix restricts the token to the metrics named with it
+	Prefix string `json:"prefix"`
+}
+
+// Allows reports whether the token may access the metric.
+func (t Token) Allows(id string) bool {
+	return strings.HasPrefix(id, t.Prefix)
+}
+
+type Config struct {
+	Tokens []Token `json:"tokens"`
+}
+
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse auth tokens: %s", err)
+	}
+
+	ids := make(map[string]bool, len(cfg.Tokens))
+	secrets := make(map[string]bool, len(cfg.Tokens))
+	for _, token := range cfg.Tokens {
+		if token.ID == "" {
+			return nil, errors.New("token id is empty")
+		}
+		if ids[token.ID] {
+			return nil, fmt.Errorf("token %s: duplicate id", token.ID)
+		}
+		ids[token.ID] = true
+
+		if token.Secret == "" {
+			return nil, fmt.Errorf("token %s: token is empty", token.ID)
+		}
+		if secrets[token.Secret] {
+			return nil, fmt.Errorf("token %s: duplicate token", token.ID)
+		}
+		secrets[token.Secret] = true
+
+		if !token.Scope.IsValid() {
+			return nil, fmt.Errorf("token %s: invalid scope %q", token.ID, token.Scope)
+		}
+	}
+
+	return cfg, nil
+}
+
+// Hash returns the hex SHA-256 of the token as stored in the api_tokens
+// table.
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Authenticator finds the tokens by their hash, so the lookup time doesn't
+// depend on how much of a guessed token is right.
+type Authenticator struct {
+	tokens map[string]Token
+	db     Querier
+}
+
+// New returns an authenticator of the tokens, the api_tokens table is also
+// searched when db is not nil.
+func New(tokens []Token, db Querier) *Authenticator {
+	a := &Authenticator{
+		tokens: make(map[string]Token, len(tokens)),
+		db:     db,
+	}
+	for _, token := range tokens {
+		hash := Hash(token.Secret)
+		token.Secret = ""
+		a.tokens[hash] = token
+	}
+	return a
+}
+
+// Authenticate returns the token, without its secret, or ErrInvalidToken.
+func (a *Authenticator) Authenticate(ctx context.Context, secret string) (Token, error) {
+	hash := Hash(secret)
+	if token, ok := a.tokens[hash]; ok {
+		return token, nil
+	}
+
+	if a.db == nil {
+		return Token{}, ErrInvalidToken
+	}
+
+	rows, err := a.db.QueryContext(ctx, `SELECT id, scope, prefix FROM api_tokens WHERE token_hash=$1 LIMIT 1`, hash)
+	if err != nil {
+		return Token{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if rows.Err() != nil {
+			return Token{}, rows.Err()
+		}
+		return Token{}, ErrInvalidToken
+	}
+
+	var token Token
+	if err := rows.Scan(&token.ID, &token.Scope, &token.Prefix); err != nil {
+		return Token{}, err
+	}
+	if !token.Scope.IsValid() {
+		return Token{}, ErrInvalidToken
+	}
+
+	return token, nil
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, token Token) context.Context {
+	return context.WithValue(ctx, contextKey{}, token)
+}
+
+// FromContext returns the token of the request, the zero token allowing
+// every metric when the request is not authenticated.
+func FromContext(ctx context.Context) Token {
+	token, _ := ctx.Value(contextKey{}).(Token)
+	return token
+}
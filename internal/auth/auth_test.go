@@ -0,0 +1,118 @@
+package auth_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/auth"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name:    "Valid",
+			content: `{"tokens":[{"id":"agent","token":"secret","scope":"write","prefix":"host1."},{"id":"ops","token":"other","scope":"admin"}]}`,
+		},
+		{
+			name:    "Empty id",
+			content: `{"tokens":[{"token":"secret","scope":"read"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "Empty token",
+			content: `{"tokens":[{"id":"agent","scope":"read"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "Duplicate token",
+			content: `{"tokens":[{"id":"agent","token":"secret","scope":"read"},{"id":"ops","token":"secret","scope":"admin"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "Invalid scope",
+			content: `{"tokens":[{"id":"agent","token":"secret","scope":"root"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "Invalid JSON",
+			content: `{"tokens":`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tokens.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+
+			cfg, err := auth.Load(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, cfg.Tokens, 2)
+		})
+	}
+}
+
+func TestScope_Includes(t *testing.T) {
+	assert.True(t, auth.ScopeAdmin.Includes(auth.ScopeWrite))
+	assert.True(t, auth.ScopeWrite.Includes(auth.ScopeRead))
+	assert.True(t, auth.ScopeRead.Includes(auth.ScopeRead))
+	assert.False(t, auth.ScopeRead.Includes(auth.ScopeWrite))
+	assert.False(t, auth.ScopeWrite.Includes(auth.ScopeAdmin))
+	assert.False(t, auth.Scope("").Includes(auth.ScopeRead))
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	ctx := context.Background()
+
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	authenticator := auth.New([]auth.Token{{ID: "agent", Secret: "secret", Scope: auth.ScopeWrite, Prefix: "host1."}}, mockDB)
+
+	t.Run("Static token", func(t *testing.T) {
+		token, err := authenticator.Authenticate(ctx, "secret")
+		require.NoError(t, err)
+		assert.Equal(t, auth.Token{ID: "agent", Scope: auth.ScopeWrite, Prefix: "host1."}, token)
+	})
+
+	t.Run("Database token", func(t *testing.T) {
+		mock.ExpectQuery("^SELECT id, scope, prefix FROM api_tokens WHERE token_hash=\\$1 LIMIT 1$").
+			WithArgs(auth.Hash("stored")).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "scope", "prefix"}).AddRow("ops", "admin", ""))
+
+		token, err := authenticator.Authenticate(ctx, "stored")
+		require.NoError(t, err)
+		assert.Equal(t, auth.Token{ID: "ops", Scope: auth.ScopeAdmin}, token)
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		mock.ExpectQuery("^SELECT id, scope, prefix FROM api_tokens").
+			WithArgs(auth.Hash("unknown")).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "scope", "prefix"}))
+
+		_, err := authenticator.Authenticate(ctx, "unknown")
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	t.Run("Without database", func(t *testing.T) {
+		_, err := auth.New(nil, nil).Authenticate(ctx, "secret")
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+}
@@ -0,0 +1,119 @@
+// Package broadcast fans out events to subscribers, each with its own
+// buffer, so that a slow subscriber never blocks the publisher.
+package broadcast
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	ErrSlowSubscriber = errors.New("subscriber is too slow")
+	ErrClosed         = errors.New("broadcaster is closed")
+)
+
+type Subscription[T any] struct {
+	events chan T
+	filter func(T) bool
+	err    error
+}
+
+// Events returns the channel of the events, it is closed when the
+// subscription ends.
+func (s *Subscription[T]) Events() <-chan T {
+	return s.events
+}
+
+// Err returns why the subscription ended, it is nil until the events channel
+// is closed and after Unsubscribe.
+func (s *Subscription[T]) Err() error {
+	return s.err
+}
+
+type Broadcaster[T any] struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription[T]]struct{}
+	closed      bool
+}
+
+func New[T any]() *Broadcaster[T] {
+	return &Broadcaster[T]{
+		mu:          sync.RWMutex{},
+		subscribers: make(map[*Subscription[T]]struct{}),
+	}
+}
+
+// Subscribe registers a subscriber receiving the events accepted by the
+// filter, a nil filter accepts every event. The subscription is ended when
+// its buffer is full.
+func (b *Broadcaster[T]) Subscribe(buffer int, filter func(T) bool) *Subscription[T] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := &Subscription[T]{
+		events: make(chan T, buffer),
+		filter: filter,
+	}
+	if b.closed {
+		b.end(s, ErrClosed)
+		return s
+	}
+	b.subscribers[s] = struct{}{}
+	return s
+}
+
+func (b *Broadcaster[T]) Unsubscribe(s *Subscription[T]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[s]; ok {
+		b.end(s, nil)
+	}
+}
+
+// Len returns the number of subscribers, the publishers can skip preparing
+// the events when there are none.
+func (b *Broadcaster[T]) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
+
+// Publish sends the events to the subscribers without blocking.
+func (b *Broadcaster[T]) Publish(events ...T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subscribers {
+		for _, event := range events {
+			if s.filter != nil && !s.filter(event) {
+				continue
+			}
+			select {
+			case s.events <- event:
+			default:
+				b.end(s, ErrSlowSubscriber)
+			}
+			if s.err != nil {
+				break
+			}
+		}
+	}
+}
+
+// Close ends every subscription.
+func (b *Broadcaster[T]) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subscribers {
+		b.end(s, ErrClosed)
+	}
+}
+
+func (b *Broadcaster[T]) end(s *Subscription[T], err error) {
+	delete(b.subscribers, s)
+	s.err = err
+	close(s.events)
+}
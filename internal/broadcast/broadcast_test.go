@@ -0,0 +1,69 @@
+package broadcast_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c2pc/go-musthave-metrics/internal/broadcast"
+)
+
+func receive(s *broadcast.Subscription[int]) []int {
+	var result []int
+	for {
+		select {
+		case event, ok := <-s.Events():
+			if !ok {
+				return result
+			}
+			result = append(result, event)
+		default:
+			return result
+		}
+	}
+}
+
+func TestBroadcaster(t *testing.T) {
+	b := broadcast.New[int]()
+
+	all := b.Subscribe(10, nil)
+	even := b.Subscribe(10, func(event int) bool { return event%2 == 0 })
+	slow := b.Subscribe(1, nil)
+	assert.Equal(t, 3, b.Len())
+
+	b.Publish(1, 2, 3, 4)
+
+	assert.Equal(t, []int{1, 2, 3, 4}, receive(all))
+	assert.Equal(t, []int{2, 4}, receive(even))
+
+	t.Run("Slow subscriber", func(t *testing.T) {
+		assert.Equal(t, []int{1}, receive(slow))
+		assert.ErrorIs(t, slow.Err(), broadcast.ErrSlowSubscriber)
+		assert.Equal(t, 2, b.Len())
+	})
+
+	t.Run("Unsubscribe", func(t *testing.T) {
+		b.Unsubscribe(even)
+		b.Unsubscribe(even)
+		b.Publish(6)
+
+		_, ok := <-even.Events()
+		assert.False(t, ok)
+		assert.NoError(t, even.Err())
+		assert.Equal(t, []int{6}, receive(all))
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		b.Close()
+
+		_, ok := <-all.Events()
+		assert.False(t, ok)
+		assert.ErrorIs(t, all.Err(), broadcast.ErrClosed)
+
+		late := b.Subscribe(10, nil)
+		_, ok = <-late.Events()
+		assert.False(t, ok)
+		assert.ErrorIs(t, late.Err(), broadcast.ErrClosed)
+		assert.Equal(t, 0, b.Len())
+	})
+}
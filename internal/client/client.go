@@ -4,34 +4,102 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/c2pc/go-musthave-metrics/internal/codec"
 	"github.com/c2pc/go-musthave-metrics/internal/model"
-	"github.com/c2pc/go-musthave-metrics/internal/reporter"
 )
 
 const requestTimeout = 1 * time.Second
 
 type Client struct {
+	mu         *sync.RWMutex
 	serverAddr string
+	token      string
+	agentID    string
+	codec      codec.Codec
+	tlsConfig  *tls.Config
+	transport  http.RoundTripper
 }
 
-func NewClient(serverAddr string) reporter.Updater {
+type Option func(*Client)
+
+// WithTLS verifies the server, and presents the client certificate if any,
+// with the config. Addresses without a scheme default to https.
+func WithTLS(config *tls.Config) Option {
+	return func(c *Client) {
+		c.tlsConfig = config
+		c.transport = &http.Transport{TLSClientConfig: config}
+	}
+}
+
+func NewClient(serverAddr string, agentID string, payloadCodec codec.Codec, opts ...Option) *Client {
+	c := &Client{
+		mu:      &sync.RWMutex{},
+		agentID: agentID,
+		codec:   payloadCodec,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.SetServerAddress(serverAddr)
+
+	return c
+}
+
+// SetServerAddress changes the server the following requests are sent to.
+func (c *Client) SetServerAddress(serverAddr string) {
 	if !strings.Contains(serverAddr, "http") {
-		serverAddr = "http://" + serverAddr
+		if c.tlsConfig != nil {
+			serverAddr = "https://" + serverAddr
+		} else {
+			serverAddr = "http://" + serverAddr
+		}
 	}
 
-	return &Client{
-		serverAddr: serverAddr,
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.serverAddr = serverAddr
+}
+
+func (c *Client) getServerAddress() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.serverAddr
+}
+
+// SetToken changes the bearer token of the following requests, an empty
+// token sends none.
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.token = token
+}
+
+func (c *Client) setAuthorization(header http.Header) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
 	}
 }
 
-func (c *Client) UpdateMetric(ctx context.Context, metrics []model.Metrics) error {
-	body, err := json.Marshal(metrics)
+// UpdateMetric sends a batch of metrics. Sending the same sequence again is
+// acknowledged by the server without applying the batch twice.
+func (c *Client) UpdateMetric(ctx context.Context, sequence int64, metrics []model.Metrics) error {
+	body, err := c.codec.Marshal(metrics)
 	if err != nil {
 		return err
 	}
@@ -47,15 +115,21 @@ func (c *Client) UpdateMetric(ctx context.Context, metrics []model.Metrics) erro
 	}
 
 	client := &http.Client{
-		Timeout: requestTimeout,
+		Timeout:   requestTimeout,
+		Transport: c.transport,
 	}
-	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.serverAddr+"/updates/", &buf)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.getServerAddress()+"/updates/", &buf)
 	if err != nil {
 		return err
 	}
-	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Content-Type", c.codec.ContentType())
 	request.Header.Set("Content-Encoding", "gzip")
 	request.Header.Set("Accept-Encoding", "gzip")
+	if c.agentID != "" {
+		request.Header.Set(model.AgentIDHeader, c.agentID)
+		request.Header.Set(model.BatchSequenceHeader, strconv.FormatInt(sequence, 10))
+	}
+	c.setAuthorization(request.Header)
 
 	response, err := client.Do(request)
 	if err != nil {
@@ -69,3 +143,74 @@ func (c *Client) UpdateMetric(ctx context.Context, metrics []model.Metrics) erro
 
 	return nil
 }
+
+// SetMetaDefaults sends the default metadata of the metrics, the server
+// keeps the metadata that was already set. A server without a metadata
+// registry answers 404, which is not an error.
+func (c *Client) SetMetaDefaults(ctx context.Context, metas []model.MetricMeta) error {
+	body, err := json.Marshal(metas)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{
+		Timeout:   requestTimeout,
+		Transport: c.transport,
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.getServerAddress()+"/api/v1/meta/defaults", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	c.setAuthorization(request.Header)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK, http.StatusNotFound:
+		return nil
+	default:
+		return fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+}
+
+func (c *Client) GetSettings(ctx context.Context, agentID string, etag string) (*model.AgentSettings, string, error) {
+	client := &http.Client{
+		Timeout:   requestTimeout,
+		Transport: c.transport,
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.getServerAddress()+"/api/v1/agents/"+url.PathEscape(agentID)+"/config", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if etag != "" {
+		request.Header.Set("If-None-Match", etag)
+	}
+	c.setAuthorization(request.Header)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		var settings model.AgentSettings
+		if err := json.NewDecoder(response.Body).Decode(&settings); err != nil {
+			return nil, "", err
+		}
+		return &settings, response.Header.Get("ETag"), nil
+	case http.StatusNotModified:
+		return nil, etag, nil
+	case http.StatusNotFound:
+		// the server has no settings for agents, keep the local ones
+		return &model.AgentSettings{}, "", nil
+	default:
+		return nil, "", fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+}
@@ -0,0 +1,51 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/client"
+	"github.com/c2pc/go-musthave-metrics/internal/codec"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+)
+
+func TestClient_SetMetaDefaults(t *testing.T) {
+	metas := []model.MetricMeta{{Type: "gauge", ID: "Alloc", Unit: "bytes"}}
+
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"Accepted", http.StatusOK, false},
+		{"No registry", http.StatusNotFound, false},
+		{"Server error", http.StatusInternalServerError, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []model.MetricMeta
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				assert.Equal(t, "/api/v1/meta/defaults", r.URL.Path)
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			err := client.NewClient(server.URL, "agent", codec.JSON{}).SetMetaDefaults(context.Background(), metas)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, metas, got)
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/c2pc/go-musthave-metrics/internal/codec"
+	"github.com/c2pc/go-musthave-metrics/internal/logger"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+)
+
+const (
+	streamPath       = "/api/v1/agents/stream"
+	streamAckTimeout = 5 * time.Second
+	streamMinBackoff = 1 * time.Second
+	streamMaxBackoff = 30 * time.Second
+)
+
+var errNotConnected = errors.New("stream is not connected")
+
+type pendingBatch struct {
+	sequence int64
+	frame    []byte
+	ack      chan model.BatchAck
+}
+
+// StreamClient sends batches over one long-lived WebSocket connection and
+// waits for the server to acknowledge each of them. The connection is
+// reopened with backoff when it breaks, and unacknowledged batches are sent
+// again once it is back.
+type StreamClient struct {
+	*Client
+	connMu    *sync.Mutex
+	conn      *websocket.Conn
+	pendingMu *sync.Mutex
+	pending   map[int64]*pendingBatch
+}
+
+func NewStreamClient(ctx context.Context, serverAddr string, agentID string, payloadCodec codec.Codec, opts ...Option) *StreamClient {
+	s := &StreamClient{
+		Client:    NewClient(serverAddr, agentID, payloadCodec, opts...),
+		connMu:    &sync.Mutex{},
+		pendingMu: &sync.Mutex{},
+		pending:   make(map[int64]*pendingBatch),
+	}
+
+	go s.run(ctx)
+
+	return s
+}
+
+// SetServerAddress changes the server and reopens the connection to it.
+func (s *StreamClient) SetServerAddress(serverAddr string) {
+	previous := s.getServerAddress()
+	s.Client.SetServerAddress(serverAddr)
+
+	if s.getServerAddress() != previous {
+		s.closeConn()
+	}
+}
+
+// SetToken changes the token and reopens the connection with it.
+func (s *StreamClient) SetToken(token string) {
+	s.mu.RLock()
+	previous := s.token
+	s.mu.RUnlock()
+	s.Client.SetToken(token)
+
+	if token != previous {
+		s.closeConn()
+	}
+}
+
+func (s *StreamClient) UpdateMetric(ctx context.Context, sequence int64, metrics []model.Metrics) error {
+	payload, err := s.codec.Marshal(metrics)
+	if err != nil {
+		return err
+	}
+
+	batch := &pendingBatch{
+		sequence: sequence,
+		frame:    codec.EncodeFrame(sequence, payload),
+		ack:      make(chan model.BatchAck, 1),
+	}
+
+	s.pendingMu.Lock()
+	s.pending[sequence] = batch
+	s.pendingMu.Unlock()
+
+	defer func() {
+		s.pendingMu.Lock()
+		defer s.pendingMu.Unlock()
+
+		if s.pending[sequence] == batch {
+			delete(s.pending, sequence)
+		}
+	}()
+
+	// a batch that cannot be written now is sent after the reconnect
+	_ = s.write(batch.frame)
+
+	ctx, cancel := context.WithTimeout(ctx, streamAckTimeout)
+	defer cancel()
+
+	select {
+	case ack := <-batch.ack:
+		if ack.Status != http.StatusOK {
+			return fmt.Errorf("unexpected status code: %d: %s", ack.Status, ack.Error)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *StreamClient) run(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		s.closeConn()
+	}()
+
+	backoff := streamMinBackoff
+	for {
+		conn, err := s.dial(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			logger.Log.Info("Failed to open metrics stream", logger.Any("retry_in", backoff.String()), logger.Error(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff = min(backoff*2, streamMaxBackoff)
+			continue
+		}
+
+		logger.Log.Info("Metrics stream opened")
+		backoff = streamMinBackoff
+
+		s.connMu.Lock()
+		s.conn = conn
+		s.connMu.Unlock()
+
+		s.replay()
+		s.readAcks(conn)
+
+		s.connMu.Lock()
+		s.conn = nil
+		s.connMu.Unlock()
+		_ = conn.Close()
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (s *StreamClient) dial(ctx context.Context) (*websocket.Conn, error) {
+	address := s.getServerAddress()
+	if strings.HasPrefix(address, "https://") {
+		address = "wss://" + strings.TrimPrefix(address, "https://")
+	} else {
+		address = "ws://" + strings.TrimPrefix(address, "http://")
+	}
+
+	dialer := websocket.Dialer{
+		HandshakeTimeout: requestTimeout,
+		Subprotocols:     []string{s.codec.Name()},
+		TLSClientConfig:  s.tlsConfig,
+	}
+
+	header := http.Header{}
+	if s.agentID != "" {
+		header.Set(model.AgentIDHeader, s.agentID)
+	}
+	s.setAuthorization(header)
+
+	conn, response, err := dialer.DialContext(ctx, address+streamPath, header)
+	if err != nil {
+		return nil, err
+	}
+	_ = response.Body.Close()
+
+	return conn, nil
+}
+
+func (s *StreamClient) write(frame []byte) error {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	if s.conn == nil {
+		return errNotConnected
+	}
+
+	_ = s.conn.SetWriteDeadline(time.Now().Add(streamAckTimeout))
+	if err := s.conn.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+		// the reader sees the broken connection and reconnects
+		_ = s.conn.Close()
+		return err
+	}
+
+	return nil
+}
+
+// replay sends the batches that are still waiting for an ack, in order.
+func (s *StreamClient) replay() {
+	s.pendingMu.Lock()
+	batches := make([]*pendingBatch, 0, len(s.pending))
+	for _, batch := range s.pending {
+		batches = append(batches, batch)
+	}
+	s.pendingMu.Unlock()
+
+	sort.Slice(batches, func(i, j int) bool {
+		return batches[i].sequence < batches[j].sequence
+	})
+
+	for _, batch := range batches {
+		if err := s.write(batch.frame); err != nil {
+			return
+		}
+	}
+}
+
+func (s *StreamClient) readAcks(conn *websocket.Conn) {
+	for {
+		var ack model.BatchAck
+		if err := conn.ReadJSON(&ack); err != nil {
+			logger.Log.Info("Metrics stream closed", logger.Error(err))
+			return
+		}
+
+		s.pendingMu.Lock()
+		if batch, ok := s.pending[ack.Sequence]; ok {
+			select {
+			case batch.ack <- ack:
+			default:
+			}
+		}
+		s.pendingMu.Unlock()
+	}
+}
+
+func (s *StreamClient) closeConn() {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+
+	if s.conn != nil {
+		_ = s.conn.Close()
+	}
+}
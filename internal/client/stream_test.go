@@ -0,0 +1,66 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/client"
+	"github.com/c2pc/go-musthave-metrics/internal/codec"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+)
+
+func TestStreamClient_UpdateMetric_Replay(t *testing.T) {
+	var connections, frames atomic.Int32
+	upgrader := websocket.Upgrader{Subprotocols: []string{codec.NameMsgpack}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "agent1", r.Header.Get(model.AgentIDHeader))
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		first := connections.Add(1) == 1
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			frames.Add(1)
+
+			// drop the first connection before acknowledging the batch
+			if first {
+				return
+			}
+
+			sequence, payload, err := codec.DecodeFrame(message)
+			require.NoError(t, err)
+
+			var metrics []model.Metrics
+			require.NoError(t, codec.Msgpack{}.Unmarshal(payload, &metrics))
+			assert.Equal(t, "PollCount", metrics[0].ID)
+
+			require.NoError(t, conn.WriteJSON(model.BatchAck{Sequence: sequence, Status: http.StatusOK}))
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := client.NewStreamClient(ctx, server.URL, "agent1", codec.Msgpack{})
+
+	var delta int64 = 1
+	err := c.UpdateMetric(ctx, 42, []model.Metrics{{ID: "PollCount", Type: "counter", Delta: &delta}})
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(2), connections.Load())
+	assert.GreaterOrEqual(t, frames.Load(), int32(2))
+}
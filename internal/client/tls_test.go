@@ -0,0 +1,89 @@
+package client_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/client"
+	"github.com/c2pc/go-musthave-metrics/internal/codec"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+	"github.com/c2pc/go-musthave-metrics/internal/server"
+)
+
+// writeCertificate writes the certificate and its key as PEM files.
+func writeCertificate(t *testing.T, dir string, name string, cert tls.Certificate) (string, string) {
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	require.NoError(t, err)
+
+	certPath := filepath.Join(dir, name+".crt")
+	keyPath := filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600))
+
+	return certPath, keyPath
+}
+
+func TestClient_TLS(t *testing.T) {
+	dir := t.TempDir()
+
+	serverCert, err := server.SelfSignedCertificate("server", "127.0.0.1")
+	require.NoError(t, err)
+	agentCert, err := server.SelfSignedCertificate("agent1")
+	require.NoError(t, err)
+
+	caPath, _ := writeCertificate(t, dir, "server", serverCert)
+	agentCertPath, agentKeyPath := writeCertificate(t, dir, "agent", agentCert)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(agentCert.Leaf)
+
+	var commonName string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commonName = r.TLS.PeerCertificates[0].Subject.CommonName
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.TLS = server.NewTLSConfig(serverCert, clientCAs)
+	srv.StartTLS()
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		caFile   string
+		certFile string
+		keyFile  string
+		wantErr  bool
+	}{
+		{"Client certificate", caPath, agentCertPath, agentKeyPath, false},
+		{"No client certificate", caPath, "", "", true},
+		{"Unknown server", "", agentCertPath, agentKeyPath, true},
+	}
+
+	metrics := []model.Metrics{{ID: "Alloc", Type: "gauge", Value: new(float64)}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commonName = ""
+
+			config, err := client.LoadTLSConfig(tt.caFile, tt.certFile, tt.keyFile)
+			require.NoError(t, err)
+
+			c := client.NewClient(srv.Listener.Addr().String(), "agent1", codec.JSON{}, client.WithTLS(config))
+			err = c.UpdateMetric(context.Background(), 1, metrics)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "agent1", commonName)
+		})
+	}
+}
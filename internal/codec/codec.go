@@ -0,0 +1,68 @@
+package codec
+
+import (
+	"encoding/json"
+	"mime"
+
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+)
+
+const (
+	NameJSON     = "json"
+	NameProtobuf = "protobuf"
+	NameMsgpack  = "msgpack"
+)
+
+// Codec encodes the batches of metrics sent to /updates/.
+type Codec interface {
+	Name() string
+	ContentType() string
+	Marshal(metrics []model.Metrics) ([]byte, error)
+	Unmarshal(data []byte, metrics *[]model.Metrics) error
+}
+
+var codecs = []Codec{JSON{}, Protobuf{}, Msgpack{}}
+
+// ByName returns the codec with the given name.
+func ByName(name string) (Codec, bool) {
+	for _, c := range codecs {
+		if c.Name() == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// ByContentType returns the codec for the given Content-Type header. Bodies
+// of any other type are treated as JSON.
+func ByContentType(contentType string) Codec {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return JSON{}
+	}
+
+	for _, c := range codecs {
+		if c.ContentType() == mediaType {
+			return c
+		}
+	}
+	return JSON{}
+}
+
+type JSON struct{}
+
+func (JSON) Name() string {
+	return NameJSON
+}
+
+func (JSON) ContentType() string {
+	return "application/json"
+}
+
+func (JSON) Marshal(metrics []model.Metrics) ([]byte, error) {
+	return json.Marshal(metrics)
+}
+
+func (JSON) Unmarshal(data []byte, metrics *[]model.Metrics) error {
+	return json.Unmarshal(data, metrics)
+}
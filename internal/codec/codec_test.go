@@ -0,0 +1,61 @@
+package codec_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/codec"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+)
+
+func TestCodec_RoundTrip(t *testing.T) {
+	var delta, negative int64 = 10, -6
+	var value, zero float64 = 0.5, 0
+
+	metrics := []model.Metrics{
+		{ID: "PollCount", Type: "counter", Delta: &delta},
+		{ID: "Drops", Type: "counter", Delta: &negative},
+		{ID: "Alloc", Type: "gauge", Value: &value},
+		{ID: "Zero", Type: "gauge", Value: &zero},
+	}
+
+	for _, name := range []string{codec.NameJSON, codec.NameProtobuf, codec.NameMsgpack} {
+		t.Run(name, func(t *testing.T) {
+			c, ok := codec.ByName(name)
+			require.True(t, ok)
+
+			data, err := c.Marshal(metrics)
+			require.NoError(t, err)
+
+			var got []model.Metrics
+			require.NoError(t, codec.ByContentType(c.ContentType()+"; charset=utf-8").Unmarshal(data, &got))
+			assert.Equal(t, metrics, got)
+		})
+	}
+}
+
+func TestCodec_ByContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		{"application/json", codec.NameJSON},
+		{"application/x-protobuf", codec.NameProtobuf},
+		{"application/msgpack", codec.NameMsgpack},
+		{"text/plain; charset=utf-8", codec.NameJSON},
+		{"", codec.NameJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			assert.Equal(t, tt.want, codec.ByContentType(tt.contentType).Name())
+		})
+	}
+}
+
+func TestProtobuf_Unmarshal_Invalid(t *testing.T) {
+	var got []model.Metrics
+	assert.Error(t, codec.Protobuf{}.Unmarshal([]byte{0x0a, 0x05, 0x0a}, &got))
+}
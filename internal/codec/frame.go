@@ -0,0 +1,25 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const frameHeaderSize = 8
+
+// EncodeFrame prefixes an encoded batch with its sequence for the streaming
+// connection.
+func EncodeFrame(sequence int64, payload []byte) []byte {
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint64(frame, uint64(sequence))
+	return append(frame, payload...)
+}
+
+// DecodeFrame splits a frame built by EncodeFrame.
+func DecodeFrame(frame []byte) (int64, []byte, error) {
+	if len(frame) < frameHeaderSize {
+		return 0, nil, errors.New("frame is too short")
+	}
+
+	return int64(binary.BigEndian.Uint64(frame)), frame[frameHeaderSize:], nil
+}
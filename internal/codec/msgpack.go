@@ -0,0 +1,36 @@
+package codec
+
+import (
+	"bytes"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+)
+
+// Msgpack encodes metrics as MessagePack maps with the same keys as JSON.
+type Msgpack struct{}
+
+func (Msgpack) Name() string {
+	return NameMsgpack
+}
+
+func (Msgpack) ContentType() string {
+	return "application/msgpack"
+}
+
+func (Msgpack) Marshal(metrics []model.Metrics) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(metrics); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (Msgpack) Unmarshal(data []byte, metrics *[]model.Metrics) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(metrics)
+}
@@ -0,0 +1,142 @@
+package codec
+
+import (
+	"errors"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+)
+
+// Protobuf encodes metrics with the following schema:
+//
+//	message Metric {
+//	  string id = 1;
+//	  string type = 2;
+//	  optional sint64 delta = 3;
+//	  optional double value = 4;
+//	}
+//
+//	message Metrics {
+//	  repeated Metric metrics = 1;
+//	}
+type Protobuf struct{}
+
+const (
+	fieldMetrics = 1
+
+	fieldID    = 1
+	fieldType  = 2
+	fieldDelta = 3
+	fieldValue = 4
+)
+
+var errInvalidProtobuf = errors.New("invalid protobuf message")
+
+func (Protobuf) Name() string {
+	return NameProtobuf
+}
+
+func (Protobuf) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func (Protobuf) Marshal(metrics []model.Metrics) ([]byte, error) {
+	var b []byte
+	for _, m := range metrics {
+		b = protowire.AppendTag(b, fieldMetrics, protowire.BytesType)
+		b = protowire.AppendBytes(b, marshalMetric(m))
+	}
+	return b, nil
+}
+
+func marshalMetric(m model.Metrics) []byte {
+	var b []byte
+	if m.ID != "" {
+		b = protowire.AppendTag(b, fieldID, protowire.BytesType)
+		b = protowire.AppendString(b, m.ID)
+	}
+	if m.Type != "" {
+		b = protowire.AppendTag(b, fieldType, protowire.BytesType)
+		b = protowire.AppendString(b, m.Type)
+	}
+	if m.Delta != nil {
+		b = protowire.AppendTag(b, fieldDelta, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeZigZag(*m.Delta))
+	}
+	if m.Value != nil {
+		b = protowire.AppendTag(b, fieldValue, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(*m.Value))
+	}
+	return b
+}
+
+func (Protobuf) Unmarshal(data []byte, metrics *[]model.Metrics) error {
+	var result []model.Metrics
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return errInvalidProtobuf
+		}
+		data = data[n:]
+
+		if num != fieldMetrics || typ != protowire.BytesType {
+			if n = protowire.ConsumeFieldValue(num, typ, data); n < 0 {
+				return errInvalidProtobuf
+			}
+			data = data[n:]
+			continue
+		}
+
+		b, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return errInvalidProtobuf
+		}
+		data = data[n:]
+
+		m, err := unmarshalMetric(b)
+		if err != nil {
+			return err
+		}
+		result = append(result, m)
+	}
+
+	*metrics = result
+	return nil
+}
+
+func unmarshalMetric(data []byte) (model.Metrics, error) {
+	var m model.Metrics
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return m, errInvalidProtobuf
+		}
+		data = data[n:]
+
+		switch {
+		case num == fieldID && typ == protowire.BytesType:
+			m.ID, n = protowire.ConsumeString(data)
+		case num == fieldType && typ == protowire.BytesType:
+			m.Type, n = protowire.ConsumeString(data)
+		case num == fieldDelta && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(data)
+			delta := protowire.DecodeZigZag(v)
+			m.Delta = &delta
+		case num == fieldValue && typ == protowire.Fixed64Type:
+			var v uint64
+			v, n = protowire.ConsumeFixed64(data)
+			value := math.Float64frombits(v)
+			m.Value = &value
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return m, errInvalidProtobuf
+		}
+		data = data[n:]
+	}
+	return m, nil
+}
@@ -4,71 +4,345 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"reflect"
+	"strings"
 
 	"github.com/caarlos0/env/v6"
+
+	"github.com/c2pc/go-musthave-metrics/internal/codec"
 )
 
 const (
-	defaultServerAddress  = "localhost:8080"
-	defaultPollInterval   = 2
-	defaultReportInterval = 10
+	defaultServerAddress    = "localhost:8080"
+	defaultPollInterval     = 2
+	defaultReportInterval   = 10
+	defaultSettingsInterval = 60
 )
 
-var (
-	serverAddress  string
-	pollInterval   int
-	reportInterval int
-)
+type flags struct {
+	serverAddress    string
+	pollInterval     int
+	reportInterval   int
+	include          string
+	exclude          string
+	expvarEndpoints  string
+	expvarInclude    string
+	expvarExclude    string
+	postgresDSN      string
+	configPath       string
+	agentID          string
+	settingsInterval int
+	encoding         string
+	stream           bool
+	token            string
+	tlsCA            string
+	tlsCert          string
+	tlsKey           string
+}
 
 type envConfig struct {
-	ServerAddress  string `env:"ADDRESS"`
-	PollInterval   int    `env:"POLL_INTERVAL"`
-	ReportInterval int    `env:"REPORT_INTERVAL"`
+	ServerAddress    string   `env:"ADDRESS"`
+	PollInterval     int      `env:"POLL_INTERVAL"`
+	ReportInterval   int      `env:"REPORT_INTERVAL"`
+	Include          []string `env:"INCLUDE" envSeparator:","`
+	Exclude          []string `env:"EXCLUDE" envSeparator:","`
+	ExpvarEndpoints  []string `env:"EXPVAR_ENDPOINTS" envSeparator:","`
+	ExpvarInclude    []string `env:"EXPVAR_INCLUDE" envSeparator:","`
+	ExpvarExclude    []string `env:"EXPVAR_EXCLUDE" envSeparator:","`
+	PostgresDSN      string   `env:"POSTGRES_DSN"`
+	ConfigPath       string   `env:"CONFIG"`
+	AgentID          string   `env:"AGENT_ID"`
+	SettingsInterval *int     `env:"SETTINGS_INTERVAL"`
+	Encoding         string   `env:"ENCODING"`
+	Stream           *bool    `env:"STREAM"`
+	Token            string   `env:"API_TOKEN"`
+	TLSCA            string   `env:"TLS_CA"`
+	TLSCert          string   `env:"TLS_CERT"`
+	TLSKey           string   `env:"TLS_KEY"`
 }
 
 type Config struct {
-	ServerAddress  string
-	PollInterval   int
-	ReportInterval int
+	ServerAddress    string
+	PollInterval     int
+	ReportInterval   int
+	Include          []string
+	Exclude          []string
+	ExpvarEndpoints  []string
+	ExpvarInclude    []string
+	ExpvarExclude    []string
+	PostgresDSN      string
+	AgentID          string
+	SettingsInterval int
+	Encoding         string
+	Stream           bool
+	Token            string
+	TLSCA            string
+	TLSCert          string
+	TLSKey           string
+	Probes           []Probe
+	ProbeConcurrency int
+	LogFiles         []LogFile
+	LogStatePath     string
 }
 
+// Parse reads the configuration from the command line, the environment and
+// the config file, in that order of precedence. It can be called again to
+// reload the configuration.
 func Parse() (*Config, error) {
-	flag.StringVar(&serverAddress, "a", defaultServerAddress, "The Address of the server")
-	flag.IntVar(&pollInterval, "p", defaultPollInterval, "The interval between polls in seconds")
-	flag.IntVar(&reportInterval, "r", defaultReportInterval, "The interval between reports in seconds")
+	return parse(os.Args[1:])
+}
+
+func parse(args []string) (*Config, error) {
+	f := flags{}
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.StringVar(&f.serverAddress, "a", defaultServerAddress, "The Address of the server")
+	fs.IntVar(&f.pollInterval, "p", defaultPollInterval, "The interval between polls in seconds")
+	fs.IntVar(&f.reportInterval, "r", defaultReportInterval, "The interval between reports in seconds")
+	fs.StringVar(&f.include, "include", "", "Comma-separated patterns of metric names to report")
+	fs.StringVar(&f.exclude, "exclude", "", "Comma-separated patterns of metric names not to report")
+	fs.StringVar(&f.expvarEndpoints, "expvar", "", "Comma-separated expvar endpoints to scrape, as name=url or url")
+	fs.StringVar(&f.expvarInclude, "expvar-include", "", "Comma-separated patterns of expvar paths to keep")
+	fs.StringVar(&f.expvarExclude, "expvar-exclude", "", "Comma-separated patterns of expvar paths to drop")
+	fs.StringVar(&f.postgresDSN, "postgres", "", "The DSN of the PostgreSQL server to collect statistics from")
+	fs.StringVar(&f.configPath, "config", "", "The path to the JSON config file")
+	fs.StringVar(&f.agentID, "id", "", "The agent identifier used to fetch settings from the server, defaults to the hostname")
+	fs.IntVar(&f.settingsInterval, "settings-interval", defaultSettingsInterval, "The interval between settings fetches in seconds, 0 disables them")
+	fs.StringVar(&f.encoding, "encoding", codec.NameJSON, "The encoding of reported metrics: json, protobuf or msgpack")
+	fs.BoolVar(&f.stream, "stream", false, "Report metrics over a persistent WebSocket connection")
+	fs.StringVar(&f.token, "token", "", "The bearer token sent to the server")
+	fs.StringVar(&f.tlsCA, "tls-ca", "", "The path to the PEM bundle of the CAs the server certificate is verified with, enables HTTPS")
+	fs.StringVar(&f.tlsCert, "tls-cert", "", "The path to the PEM client certificate, enables HTTPS")
+	fs.StringVar(&f.tlsKey, "tls-key", "", "The path to the PEM key of the client certificate")
 
 	cfg := Config{}
 
-	flag.Parse()
-	if len(flag.Args()) > 0 {
-		return nil, fmt.Errorf("unknown argument: %s", flag.Args()[0])
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if len(fs.Args()) > 0 {
+		return nil, fmt.Errorf("unknown argument: %s", fs.Args()[0])
 	}
 
+	set := make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) {
+		set[fl.Name] = true
+	})
+
 	envCfg := envConfig{}
 	err := env.Parse(&envCfg)
 	if err != nil {
 		return nil, err
 	}
 
+	if envCfg.ConfigPath != "" {
+		f.configPath = envCfg.ConfigPath
+	}
+
+	fileCfg, err := loadFile(f.configPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := fileCfg.validate(); err != nil {
+		return nil, err
+	}
+
 	if envCfg.ServerAddress != "" {
 		cfg.ServerAddress = envCfg.ServerAddress
-	} else if address := os.Getenv("ADDRESS"); address != "" {
-		cfg.ServerAddress = address
+	} else if set["a"] || fileCfg.Address == "" {
+		cfg.ServerAddress = f.serverAddress
 	} else {
-		cfg.ServerAddress = serverAddress
+		cfg.ServerAddress = fileCfg.Address
 	}
 
 	if envCfg.PollInterval != 0 {
 		cfg.PollInterval = envCfg.PollInterval
+	} else if set["p"] || fileCfg.PollInterval == 0 {
+		cfg.PollInterval = f.pollInterval
 	} else {
-		cfg.PollInterval = pollInterval
+		cfg.PollInterval = fileCfg.PollInterval
+	}
+	if cfg.PollInterval <= 0 {
+		return nil, fmt.Errorf("invalid poll interval: %d", cfg.PollInterval)
 	}
 
 	if envCfg.ReportInterval != 0 {
 		cfg.ReportInterval = envCfg.ReportInterval
+	} else if set["r"] || fileCfg.ReportInterval == 0 {
+		cfg.ReportInterval = f.reportInterval
+	} else {
+		cfg.ReportInterval = fileCfg.ReportInterval
+	}
+	if cfg.ReportInterval <= 0 {
+		return nil, fmt.Errorf("invalid report interval: %d", cfg.ReportInterval)
+	}
+
+	if len(envCfg.Include) > 0 {
+		cfg.Include = envCfg.Include
+	} else if set["include"] || len(fileCfg.Include) == 0 {
+		cfg.Include = splitList(f.include)
+	} else {
+		cfg.Include = fileCfg.Include
+	}
+
+	if len(envCfg.Exclude) > 0 {
+		cfg.Exclude = envCfg.Exclude
+	} else if set["exclude"] || len(fileCfg.Exclude) == 0 {
+		cfg.Exclude = splitList(f.exclude)
+	} else {
+		cfg.Exclude = fileCfg.Exclude
+	}
+
+	if len(envCfg.ExpvarEndpoints) > 0 {
+		cfg.ExpvarEndpoints = envCfg.ExpvarEndpoints
+	} else {
+		cfg.ExpvarEndpoints = splitList(f.expvarEndpoints)
+	}
+
+	if len(envCfg.ExpvarInclude) > 0 {
+		cfg.ExpvarInclude = envCfg.ExpvarInclude
+	} else {
+		cfg.ExpvarInclude = splitList(f.expvarInclude)
+	}
+
+	if len(envCfg.ExpvarExclude) > 0 {
+		cfg.ExpvarExclude = envCfg.ExpvarExclude
+	} else {
+		cfg.ExpvarExclude = splitList(f.expvarExclude)
+	}
+
+	if envCfg.PostgresDSN != "" {
+		cfg.PostgresDSN = envCfg.PostgresDSN
+	} else {
+		cfg.PostgresDSN = f.postgresDSN
+	}
+
+	if envCfg.AgentID != "" {
+		cfg.AgentID = envCfg.AgentID
+	} else if f.agentID != "" {
+		cfg.AgentID = f.agentID
+	} else if cfg.AgentID, err = os.Hostname(); err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %s", err)
+	}
+
+	if envCfg.SettingsInterval != nil {
+		cfg.SettingsInterval = *envCfg.SettingsInterval
+	} else {
+		cfg.SettingsInterval = f.settingsInterval
+	}
+	if cfg.SettingsInterval < 0 {
+		return nil, fmt.Errorf("invalid settings interval: %d", cfg.SettingsInterval)
+	}
+
+	if envCfg.Encoding != "" {
+		cfg.Encoding = envCfg.Encoding
+	} else if set["encoding"] || fileCfg.Encoding == "" {
+		cfg.Encoding = f.encoding
+	} else {
+		cfg.Encoding = fileCfg.Encoding
+	}
+	if _, ok := codec.ByName(cfg.Encoding); !ok {
+		return nil, fmt.Errorf("unknown encoding: %s", cfg.Encoding)
+	}
+
+	if envCfg.Stream != nil {
+		cfg.Stream = *envCfg.Stream
+	} else if set["stream"] || fileCfg.Stream == nil {
+		cfg.Stream = f.stream
+	} else {
+		cfg.Stream = *fileCfg.Stream
+	}
+
+	if envCfg.Token != "" {
+		cfg.Token = envCfg.Token
+	} else if set["token"] || fileCfg.Token == "" {
+		cfg.Token = f.token
+	} else {
+		cfg.Token = fileCfg.Token
+	}
+
+	if envCfg.TLSCA != "" {
+		cfg.TLSCA = envCfg.TLSCA
+	} else if set["tls-ca"] || fileCfg.TLSCA == "" {
+		cfg.TLSCA = f.tlsCA
+	} else {
+		cfg.TLSCA = fileCfg.TLSCA
+	}
+
+	if envCfg.TLSCert != "" {
+		cfg.TLSCert = envCfg.TLSCert
+	} else if set["tls-cert"] || fileCfg.TLSCert == "" {
+		cfg.TLSCert = f.tlsCert
+	} else {
+		cfg.TLSCert = fileCfg.TLSCert
+	}
+
+	if envCfg.TLSKey != "" {
+		cfg.TLSKey = envCfg.TLSKey
+	} else if set["tls-key"] || fileCfg.TLSKey == "" {
+		cfg.TLSKey = f.tlsKey
 	} else {
-		cfg.ReportInterval = reportInterval
+		cfg.TLSKey = fileCfg.TLSKey
+	}
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return nil, fmt.Errorf("the tls certificate and key must be set together")
 	}
 
+	cfg.Probes = fileCfg.Probes
+	cfg.ProbeConcurrency = fileCfg.ProbeConcurrency
+	cfg.LogFiles = fileCfg.LogFiles
+	cfg.LogStatePath = fileCfg.LogStatePath
+
 	return &cfg, nil
 }
+
+// TLS reports whether the agent connects over HTTPS.
+func (cfg *Config) TLS() bool {
+	return cfg.TLSCA != "" || cfg.TLSCert != ""
+}
+
+// RestartRequired returns the settings that differ between the two configs
+// and cannot be applied to a running agent.
+func (cfg *Config) RestartRequired(other *Config) []string {
+	var changed []string
+	if !reflect.DeepEqual(cfg.ExpvarEndpoints, other.ExpvarEndpoints) ||
+		!reflect.DeepEqual(cfg.ExpvarInclude, other.ExpvarInclude) ||
+		!reflect.DeepEqual(cfg.ExpvarExclude, other.ExpvarExclude) {
+		changed = append(changed, "expvar")
+	}
+	if cfg.PostgresDSN != other.PostgresDSN {
+		changed = append(changed, "postgres")
+	}
+	if cfg.AgentID != other.AgentID {
+		changed = append(changed, "id")
+	}
+	if cfg.SettingsInterval != other.SettingsInterval {
+		changed = append(changed, "settings_interval")
+	}
+	if cfg.Encoding != other.Encoding {
+		changed = append(changed, "encoding")
+	}
+	if cfg.Stream != other.Stream {
+		changed = append(changed, "stream")
+	}
+	if cfg.TLSCA != other.TLSCA || cfg.TLSCert != other.TLSCert || cfg.TLSKey != other.TLSKey {
+		changed = append(changed, "tls")
+	}
+	if !reflect.DeepEqual(cfg.Probes, other.Probes) || cfg.ProbeConcurrency != other.ProbeConcurrency {
+		changed = append(changed, "probes")
+	}
+	if !reflect.DeepEqual(cfg.LogFiles, other.LogFiles) || cfg.LogStatePath != other.LogStatePath {
+		changed = append(changed, "log_files")
+	}
+
+	return changed
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,99 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/c2pc/go-musthave-metrics/internal/duration"
+)
+
+const defaultProbeConcurrency = 4
+
+type Probe struct {
+	Name           string            `json:"name"`
+	URL            string            `json:"url"`
+	Method         string            `json:"method"`
+	ExpectedStatus int               `json:"expected_status"`
+	BodyRegex      string            `json:"body_regex"`
+	Timeout        duration.Duration `json:"timeout"`
+	SkipTLSVerify  bool              `json:"skip_tls_verify"`
+}
+
+type LogRule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	Type    string `json:"type"`
+	Group   int    `json:"group"`
+}
+
+type LogFile struct {
+	Path  string    `json:"path"`
+	Rules []LogRule `json:"rules"`
+}
+
+type fileConfig struct {
+	Address          string    `json:"address"`
+	PollInterval     int       `json:"poll_interval"`
+	ReportInterval   int       `json:"report_interval"`
+	Include          []string  `json:"include"`
+	Exclude          []string  `json:"exclude"`
+	Encoding         string    `json:"encoding"`
+	Stream           *bool     `json:"stream"`
+	Token            string    `json:"token"`
+	TLSCA            string    `json:"tls_ca"`
+	TLSCert          string    `json:"tls_cert"`
+	TLSKey           string    `json:"tls_key"`
+	Probes           []Probe   `json:"probes"`
+	ProbeConcurrency int       `json:"probe_concurrency"`
+	LogFiles         []LogFile `json:"log_files"`
+	LogStatePath     string    `json:"log_state_path"`
+}
+
+func loadFile(path string) (*fileConfig, error) {
+	cfg := &fileConfig{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %s", err)
+	}
+
+	return cfg, nil
+}
+
+func (cfg *fileConfig) validate() error {
+	if cfg.ProbeConcurrency < 0 {
+		return errors.New("invalid probe_concurrency")
+	}
+	if cfg.ProbeConcurrency == 0 {
+		cfg.ProbeConcurrency = defaultProbeConcurrency
+	}
+
+	for i, probe := range cfg.Probes {
+		if probe.URL == "" {
+			return fmt.Errorf("probe %d: url is empty", i)
+		}
+		if probe.Timeout.Duration < 0 {
+			return fmt.Errorf("probe %d: invalid timeout", i)
+		}
+	}
+
+	for i, logFile := range cfg.LogFiles {
+		if logFile.Path == "" {
+			return fmt.Errorf("log file %d: path is empty", i)
+		}
+		if len(logFile.Rules) == 0 {
+			return fmt.Errorf("log file %s: no rules", logFile.Path)
+		}
+	}
+
+	return nil
+}
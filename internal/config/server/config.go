@@ -4,24 +4,50 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"reflect"
 	"strconv"
+	"time"
 
 	"github.com/caarlos0/env/v6"
+	"go.uber.org/zap"
+
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
 )
 
 const (
 	defaultServerAddress = "localhost:8080"
 	defaultStoreInterval = 300
 	defaultRestore       = true
+	defaultLogLevel      = "info"
+	defaultHistorySize   = 1000
+	defaultRetention     = "raw=24h,1m=720h,1h=8760h"
 )
 
-var (
+type flags struct {
 	address         string
 	storeInterval   int64
 	fileStoragePath string
 	restore         bool
 	databaseDSN     string
-)
+	agentConfigPath string
+	configPath      string
+	logLevel        string
+	rateLimit       float64
+	historySize     int
+	retention       string
+	metricTTL       time.Duration
+	alertRulesPath  string
+	forwardPath     string
+	graphiteAddress string
+	graphitePath    string
+	authTokensPath  string
+	authDB          bool
+	tlsCert         string
+	tlsKey          string
+	tlsClientCA     string
+	tlsAgentCN      bool
+	tlsSelfSigned   bool
+}
 
 type envConfig struct {
 	Address         string `env:"ADDRESS"`
@@ -29,6 +55,24 @@ type envConfig struct {
 	FileStoragePath string `env:"FILE_STORAGE_PATH"`
 	Restore         string `env:"RESTORE"`
 	DatabaseDSN     string `env:"DATABASE_DSN"`
+	AgentConfigPath string `env:"AGENT_CONFIG"`
+	ConfigPath      string `env:"CONFIG"`
+	LogLevel        string `env:"LOG_LEVEL"`
+	RateLimit       string `env:"RATE_LIMIT"`
+	HistorySize     int    `env:"HISTORY_SIZE"`
+	Retention       string `env:"HISTORY_RETENTION"`
+	MetricTTL       string `env:"METRIC_TTL"`
+	AlertRulesPath  string `env:"ALERT_RULES"`
+	ForwardPath     string `env:"FORWARD_CONFIG"`
+	GraphiteAddress string `env:"GRAPHITE_ADDRESS"`
+	GraphitePath    string `env:"GRAPHITE_CONFIG"`
+	AuthTokensPath  string `env:"AUTH_TOKENS"`
+	AuthDB          string `env:"AUTH_DB"`
+	TLSCert         string `env:"TLS_CERT"`
+	TLSKey          string `env:"TLS_KEY"`
+	TLSClientCA     string `env:"TLS_CLIENT_CA"`
+	TLSAgentCN      string `env:"TLS_AGENT_CN"`
+	TLSSelfSigned   string `env:"TLS_SELF_SIGNED"`
 }
 
 type Config struct {
@@ -37,36 +81,96 @@ type Config struct {
 	FileStoragePath string
 	Restore         bool
 	DatabaseDSN     string
+	AgentConfigPath string
+	LogLevel        string
+	RateLimit       float64
+	HistorySize     int
+	Retention       []storage.RetentionTier
+	MetricTTL       time.Duration
+	AlertRulesPath  string
+	ForwardPath     string
+	GraphiteAddress string
+	GraphitePath    string
+	AuthTokensPath  string
+	AuthDB          bool
+	TLSCert         string
+	TLSKey          string
+	TLSClientCA     string
+	TLSAgentCN      bool
+	TLSSelfSigned   bool
 }
 
+// Parse reads the configuration from the command line, the environment and
+// the config file. It can be called again to reload the configuration.
 func Parse() (*Config, error) {
-	flag.StringVar(&address, "a", defaultServerAddress, "The Address of the server")
-	flag.Int64Var(&storeInterval, "i", defaultStoreInterval, "The interval, in seconds, of the file store")
-	flag.StringVar(&fileStoragePath, "f", "", "The path to the file storage")
-	flag.BoolVar(&restore, "r", defaultRestore, "The restore flag")
-	flag.StringVar(&databaseDSN, "d", "", "The database DSN")
+	return parse(os.Args[1:])
+}
+
+func parse(args []string) (*Config, error) {
+	f := flags{}
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.StringVar(&f.address, "a", defaultServerAddress, "The Address of the server")
+	fs.Int64Var(&f.storeInterval, "i", defaultStoreInterval, "The interval, in seconds, of the file store")
+	fs.StringVar(&f.fileStoragePath, "f", "", "The path to the file storage")
+	fs.BoolVar(&f.restore, "r", defaultRestore, "The restore flag")
+	fs.StringVar(&f.databaseDSN, "d", "", "The database DSN")
+	fs.StringVar(&f.agentConfigPath, "agent-config", "", "The path to the JSON file with the settings served to agents")
+	fs.StringVar(&f.configPath, "config", "", "The path to the JSON config file")
+	fs.StringVar(&f.logLevel, "log-level", defaultLogLevel, "The log level")
+	fs.Float64Var(&f.rateLimit, "rate-limit", 0, "The maximum number of requests per second, 0 disables the limit")
+	fs.IntVar(&f.historySize, "history-size", defaultHistorySize, "The number of points kept per metric by the in-memory history")
+	fs.StringVar(&f.retention, "history-retention", defaultRetention, "The history retention tiers as resolution=retention pairs, the first one raw")
+	fs.DurationVar(&f.metricTTL, "metric-ttl", 0, "The time after which metrics that are not updated are deleted, 0 keeps them forever")
+	fs.StringVar(&f.alertRulesPath, "alert-rules", "", "The path to the JSON file with the alert rules")
+	fs.StringVar(&f.forwardPath, "forward-config", "", "The path to the JSON file with the sinks the updates are forwarded to")
+	fs.StringVar(&f.graphiteAddress, "graphite-address", "", "The TCP and UDP address of the Graphite plaintext listener, empty disables it")
+	fs.StringVar(&f.graphitePath, "graphite-config", "", "The path to the JSON file with the Graphite listener settings")
+	fs.StringVar(&f.authTokensPath, "auth-tokens", "", "The path to the JSON file with the API tokens")
+	fs.BoolVar(&f.authDB, "auth-db", false, "Look up the API tokens in the api_tokens table")
+	fs.StringVar(&f.tlsCert, "tls-cert", "", "The path to the PEM certificate of the server, enables HTTPS")
+	fs.StringVar(&f.tlsKey, "tls-key", "", "The path to the PEM key of the server certificate")
+	fs.StringVar(&f.tlsClientCA, "tls-client-ca", "", "The path to the PEM bundle of the CAs the client certificates must be signed by")
+	fs.BoolVar(&f.tlsAgentCN, "tls-agent-cn", false, "Identify the agents by the common name of their client certificate")
+	fs.BoolVar(&f.tlsSelfSigned, "tls-self-signed", false, "Serve HTTPS with a certificate generated at startup, for development only")
 
 	cfg := &Config{}
 
-	flag.Parse()
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
 
-	if len(flag.Args()) > 0 {
-		return nil, fmt.Errorf("unknown argument: %s", flag.Args()[0])
+	if len(fs.Args()) > 0 {
+		return nil, fmt.Errorf("unknown argument: %s", fs.Args()[0])
 	}
 
+	set := make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) {
+		set[fl.Name] = true
+	})
+
 	envCfg := envConfig{}
 	err := env.Parse(&envCfg)
 	if err != nil {
 		return nil, err
 	}
 
+	//Parsing ConfigPath
+	if envCfg.ConfigPath != "" {
+		f.configPath = envCfg.ConfigPath
+	}
+
+	fileCfg, err := loadFile(f.configPath)
+	if err != nil {
+		return nil, err
+	}
+
 	//Parsing Address
 	if envCfg.Address != "" {
 		cfg.Address = envCfg.Address
-	} else if v := os.Getenv("ADDRESS"); v != "" {
-		cfg.Address = v
+	} else if set["a"] || fileCfg.Address == "" {
+		cfg.Address = f.address
 	} else {
-		cfg.Address = address
+		cfg.Address = fileCfg.Address
 	}
 
 	//Parsing StoreInterval
@@ -75,22 +179,22 @@ func Parse() (*Config, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse STORE_INTERVAL: %s", err)
 		}
-	} else if v := os.Getenv("STORE_INTERVAL"); v != "" {
-		cfg.StoreInterval, err = strconv.ParseInt(v, 10, 64)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse STORE_INTERVAL: %s", err)
-		}
+	} else if set["i"] || fileCfg.StoreInterval == nil {
+		cfg.StoreInterval = f.storeInterval
 	} else {
-		cfg.StoreInterval = storeInterval
+		cfg.StoreInterval = *fileCfg.StoreInterval
+	}
+	if cfg.StoreInterval < 0 {
+		return nil, fmt.Errorf("invalid store interval: %d", cfg.StoreInterval)
 	}
 
 	//Parsing FileStoragePath
 	if envCfg.FileStoragePath != "" {
 		cfg.FileStoragePath = envCfg.FileStoragePath
-	} else if v := os.Getenv("ADDRESS"); v != "" {
-		cfg.FileStoragePath = v
+	} else if set["f"] || fileCfg.FileStoragePath == "" {
+		cfg.FileStoragePath = f.fileStoragePath
 	} else {
-		cfg.FileStoragePath = fileStoragePath
+		cfg.FileStoragePath = fileCfg.FileStoragePath
 	}
 
 	//Parsing Restore
@@ -99,23 +203,280 @@ func Parse() (*Config, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse RESTORE: %s", err)
 		}
-	} else if v := os.Getenv("RESTORE"); v != "" {
-		cfg.Restore, err = strconv.ParseBool(v)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse RESTORE: %s", err)
-		}
+	} else if set["r"] || fileCfg.Restore == nil {
+		cfg.Restore = f.restore
 	} else {
-		cfg.Restore = restore
+		cfg.Restore = *fileCfg.Restore
 	}
 
 	//Parsing Database DSN
 	if envCfg.DatabaseDSN != "" {
 		cfg.DatabaseDSN = envCfg.DatabaseDSN
-	} else if v := os.Getenv("DATABASE_DSN"); v != "" {
-		cfg.DatabaseDSN = v
+	} else if set["d"] || fileCfg.DatabaseDSN == "" {
+		cfg.DatabaseDSN = f.databaseDSN
+	} else {
+		cfg.DatabaseDSN = fileCfg.DatabaseDSN
+	}
+
+	//Parsing AgentConfigPath
+	if envCfg.AgentConfigPath != "" {
+		cfg.AgentConfigPath = envCfg.AgentConfigPath
+	} else if set["agent-config"] || fileCfg.AgentConfigPath == "" {
+		cfg.AgentConfigPath = f.agentConfigPath
+	} else {
+		cfg.AgentConfigPath = fileCfg.AgentConfigPath
+	}
+
+	//Parsing LogLevel
+	if envCfg.LogLevel != "" {
+		cfg.LogLevel = envCfg.LogLevel
+	} else if set["log-level"] || fileCfg.LogLevel == "" {
+		cfg.LogLevel = f.logLevel
+	} else {
+		cfg.LogLevel = fileCfg.LogLevel
+	}
+	if _, err := zap.ParseAtomicLevel(cfg.LogLevel); err != nil {
+		return nil, fmt.Errorf("failed to parse log level: %s", err)
+	}
+
+	//Parsing RateLimit
+	if envCfg.RateLimit != "" {
+		cfg.RateLimit, err = strconv.ParseFloat(envCfg.RateLimit, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RATE_LIMIT: %s", err)
+		}
+	} else if set["rate-limit"] || fileCfg.RateLimit == nil {
+		cfg.RateLimit = f.rateLimit
 	} else {
-		cfg.DatabaseDSN = databaseDSN
+		cfg.RateLimit = *fileCfg.RateLimit
+	}
+	if cfg.RateLimit < 0 {
+		return nil, fmt.Errorf("invalid rate limit: %v", cfg.RateLimit)
+	}
+
+	//Parsing HistorySize
+	if envCfg.HistorySize != 0 {
+		cfg.HistorySize = envCfg.HistorySize
+	} else if set["history-size"] || fileCfg.HistorySize == 0 {
+		cfg.HistorySize = f.historySize
+	} else {
+		cfg.HistorySize = fileCfg.HistorySize
+	}
+	if cfg.HistorySize <= 0 {
+		return nil, fmt.Errorf("invalid history size: %d", cfg.HistorySize)
+	}
+
+	//Parsing Retention
+	retention := f.retention
+	if envCfg.Retention != "" {
+		retention = envCfg.Retention
+	} else if !set["history-retention"] && fileCfg.Retention != "" {
+		retention = fileCfg.Retention
+	}
+	cfg.Retention, err = storage.ParseRetentionTiers(retention)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse history retention: %s", err)
+	}
+
+	//Parsing MetricTTL
+	if envCfg.MetricTTL != "" {
+		cfg.MetricTTL, err = time.ParseDuration(envCfg.MetricTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse METRIC_TTL: %s", err)
+		}
+	} else if set["metric-ttl"] || fileCfg.MetricTTL == "" {
+		cfg.MetricTTL = f.metricTTL
+	} else {
+		cfg.MetricTTL, err = time.ParseDuration(fileCfg.MetricTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse metric_ttl: %s", err)
+		}
+	}
+	if cfg.MetricTTL < 0 {
+		return nil, fmt.Errorf("invalid metric ttl: %s", cfg.MetricTTL)
+	}
+
+	//Parsing AlertRulesPath
+	if envCfg.AlertRulesPath != "" {
+		cfg.AlertRulesPath = envCfg.AlertRulesPath
+	} else if set["alert-rules"] || fileCfg.AlertRulesPath == "" {
+		cfg.AlertRulesPath = f.alertRulesPath
+	} else {
+		cfg.AlertRulesPath = fileCfg.AlertRulesPath
+	}
+
+	//Parsing ForwardPath
+	if envCfg.ForwardPath != "" {
+		cfg.ForwardPath = envCfg.ForwardPath
+	} else if set["forward-config"] || fileCfg.ForwardPath == "" {
+		cfg.ForwardPath = f.forwardPath
+	} else {
+		cfg.ForwardPath = fileCfg.ForwardPath
+	}
+
+	//Parsing GraphiteAddress
+	if envCfg.GraphiteAddress != "" {
+		cfg.GraphiteAddress = envCfg.GraphiteAddress
+	} else if set["graphite-address"] || fileCfg.GraphiteAddress == "" {
+		cfg.GraphiteAddress = f.graphiteAddress
+	} else {
+		cfg.GraphiteAddress = fileCfg.GraphiteAddress
+	}
+
+	//Parsing GraphitePath
+	if envCfg.GraphitePath != "" {
+		cfg.GraphitePath = envCfg.GraphitePath
+	} else if set["graphite-config"] || fileCfg.GraphitePath == "" {
+		cfg.GraphitePath = f.graphitePath
+	} else {
+		cfg.GraphitePath = fileCfg.GraphitePath
+	}
+
+	//Parsing AuthTokensPath
+	if envCfg.AuthTokensPath != "" {
+		cfg.AuthTokensPath = envCfg.AuthTokensPath
+	} else if set["auth-tokens"] || fileCfg.AuthTokensPath == "" {
+		cfg.AuthTokensPath = f.authTokensPath
+	} else {
+		cfg.AuthTokensPath = fileCfg.AuthTokensPath
+	}
+
+	//Parsing AuthDB
+	if envCfg.AuthDB != "" {
+		cfg.AuthDB, err = strconv.ParseBool(envCfg.AuthDB)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse AUTH_DB: %s", err)
+		}
+	} else if set["auth-db"] || fileCfg.AuthDB == nil {
+		cfg.AuthDB = f.authDB
+	} else {
+		cfg.AuthDB = *fileCfg.AuthDB
+	}
+	if cfg.AuthDB && cfg.DatabaseDSN == "" {
+		return nil, fmt.Errorf("the api_tokens table requires a database")
+	}
+
+	//Parsing TLSCert
+	if envCfg.TLSCert != "" {
+		cfg.TLSCert = envCfg.TLSCert
+	} else if set["tls-cert"] || fileCfg.TLSCert == "" {
+		cfg.TLSCert = f.tlsCert
+	} else {
+		cfg.TLSCert = fileCfg.TLSCert
+	}
+
+	//Parsing TLSKey
+	if envCfg.TLSKey != "" {
+		cfg.TLSKey = envCfg.TLSKey
+	} else if set["tls-key"] || fileCfg.TLSKey == "" {
+		cfg.TLSKey = f.tlsKey
+	} else {
+		cfg.TLSKey = fileCfg.TLSKey
+	}
+
+	//Parsing TLSClientCA
+	if envCfg.TLSClientCA != "" {
+		cfg.TLSClientCA = envCfg.TLSClientCA
+	} else if set["tls-client-ca"] || fileCfg.TLSClientCA == "" {
+		cfg.TLSClientCA = f.tlsClientCA
+	} else {
+		cfg.TLSClientCA = fileCfg.TLSClientCA
+	}
+
+	//Parsing TLSAgentCN
+	if envCfg.TLSAgentCN != "" {
+		cfg.TLSAgentCN, err = strconv.ParseBool(envCfg.TLSAgentCN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse TLS_AGENT_CN: %s", err)
+		}
+	} else if set["tls-agent-cn"] || fileCfg.TLSAgentCN == nil {
+		cfg.TLSAgentCN = f.tlsAgentCN
+	} else {
+		cfg.TLSAgentCN = *fileCfg.TLSAgentCN
+	}
+
+	//Parsing TLSSelfSigned
+	if envCfg.TLSSelfSigned != "" {
+		cfg.TLSSelfSigned, err = strconv.ParseBool(envCfg.TLSSelfSigned)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse TLS_SELF_SIGNED: %s", err)
+		}
+	} else if set["tls-self-signed"] || fileCfg.TLSSelfSigned == nil {
+		cfg.TLSSelfSigned = f.tlsSelfSigned
+	} else {
+		cfg.TLSSelfSigned = *fileCfg.TLSSelfSigned
+	}
+
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return nil, fmt.Errorf("the tls certificate and key must be set together")
+	}
+	if cfg.TLSSelfSigned && cfg.TLSCert != "" {
+		return nil, fmt.Errorf("a self-signed certificate can't be used with a tls certificate")
+	}
+	if cfg.TLSClientCA != "" && !cfg.TLS() {
+		return nil, fmt.Errorf("client certificates require tls")
+	}
+	if cfg.TLSAgentCN && cfg.TLSClientCA == "" {
+		return nil, fmt.Errorf("agent ids from client certificates require a client ca")
 	}
 
 	return cfg, nil
 }
+
+// TLS reports whether the server serves HTTPS.
+func (cfg *Config) TLS() bool {
+	return cfg.TLSCert != "" || cfg.TLSSelfSigned
+}
+
+// RestartRequired returns the settings that differ between the two configs
+// and cannot be applied to a running server.
+func (cfg *Config) RestartRequired(other *Config) []string {
+	var changed []string
+	if cfg.Address != other.Address {
+		changed = append(changed, "address")
+	}
+	if cfg.FileStoragePath != other.FileStoragePath {
+		changed = append(changed, "store_file")
+	}
+	if cfg.Restore != other.Restore {
+		changed = append(changed, "restore")
+	}
+	if cfg.DatabaseDSN != other.DatabaseDSN {
+		changed = append(changed, "database_dsn")
+	}
+	if cfg.AgentConfigPath != other.AgentConfigPath {
+		changed = append(changed, "agent_config")
+	}
+	if cfg.HistorySize != other.HistorySize {
+		changed = append(changed, "history_size")
+	}
+	if !reflect.DeepEqual(cfg.Retention, other.Retention) {
+		changed = append(changed, "history_retention")
+	}
+	if cfg.AlertRulesPath != other.AlertRulesPath {
+		changed = append(changed, "alert_rules")
+	}
+	if cfg.ForwardPath != other.ForwardPath {
+		changed = append(changed, "forward_config")
+	}
+	if cfg.GraphiteAddress != other.GraphiteAddress {
+		changed = append(changed, "graphite_address")
+	}
+	if cfg.GraphitePath != other.GraphitePath {
+		changed = append(changed, "graphite_config")
+	}
+	if cfg.AuthTokensPath != other.AuthTokensPath {
+		changed = append(changed, "auth_tokens")
+	}
+	if cfg.AuthDB != other.AuthDB {
+		changed = append(changed, "auth_db")
+	}
+	if cfg.TLSCert != other.TLSCert || cfg.TLSKey != other.TLSKey || cfg.TLSSelfSigned != other.TLSSelfSigned {
+		changed = append(changed, "tls")
+	}
+	if cfg.TLSClientCA != other.TLSClientCA || cfg.TLSAgentCN != other.TLSAgentCN {
+		changed = append(changed, "tls_client_ca")
+	}
+
+	return changed
+}
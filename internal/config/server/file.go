@@ -0,0 +1,50 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+type fileConfig struct {
+	Address         string   `json:"address"`
+	StoreInterval   *int64   `json:"store_interval"`
+	FileStoragePath string   `json:"store_file"`
+	Restore         *bool    `json:"restore"`
+	DatabaseDSN     string   `json:"database_dsn"`
+	AgentConfigPath string   `json:"agent_config"`
+	LogLevel        string   `json:"log_level"`
+	RateLimit       *float64 `json:"rate_limit"`
+	HistorySize     int      `json:"history_size"`
+	Retention       string   `json:"history_retention"`
+	MetricTTL       string   `json:"metric_ttl"`
+	AlertRulesPath  string   `json:"alert_rules"`
+	ForwardPath     string   `json:"forward_config"`
+	GraphiteAddress string   `json:"graphite_address"`
+	GraphitePath    string   `json:"graphite_config"`
+	AuthTokensPath  string   `json:"auth_tokens"`
+	AuthDB          *bool    `json:"auth_db"`
+	TLSCert         string   `json:"tls_cert"`
+	TLSKey          string   `json:"tls_key"`
+	TLSClientCA     string   `json:"tls_client_ca"`
+	TLSAgentCN      *bool    `json:"tls_agent_cn"`
+	TLSSelfSigned   *bool    `json:"tls_self_signed"`
+}
+
+func loadFile(path string) (*fileConfig, error) {
+	cfg := &fileConfig{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %s", err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS batches
+(
+    agent_id   VARCHAR(255) NOT NULL,
+    sequence   BIGINT       NOT NULL,
+    created_at TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY (agent_id, sequence)
+);
+
+CREATE INDEX IF NOT EXISTS batches_created_at_idx ON batches (created_at);
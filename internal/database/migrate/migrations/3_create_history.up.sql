@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS history
+(
+    type  VARCHAR(16)      NOT NULL,
+    key   VARCHAR(255)     NOT NULL,
+    time  TIMESTAMPTZ      NOT NULL,
+    value DOUBLE PRECISION NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS history_type_key_time_idx ON history (type, key, time);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS history_rollups
+(
+    resolution BIGINT           NOT NULL,
+    type       VARCHAR(16)      NOT NULL,
+    key        VARCHAR(255)     NOT NULL,
+    time       TIMESTAMPTZ      NOT NULL,
+    min        DOUBLE PRECISION NOT NULL,
+    max        DOUBLE PRECISION NOT NULL,
+    sum        DOUBLE PRECISION NOT NULL,
+    count      BIGINT           NOT NULL,
+    last       DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (resolution, type, key, time)
+);
+
+CREATE INDEX IF NOT EXISTS history_rollups_resolution_time_idx ON history_rollups (resolution, time);
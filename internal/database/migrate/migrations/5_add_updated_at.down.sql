@@ -0,0 +1,2 @@
+ALTER TABLE gauges DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE counters DROP COLUMN IF EXISTS updated_at;
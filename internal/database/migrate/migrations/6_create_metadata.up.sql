@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS metadata
+(
+    type         VARCHAR(16)  NOT NULL,
+    key          VARCHAR(255) NOT NULL,
+    unit         VARCHAR(64)  NOT NULL DEFAULT '',
+    description  TEXT         NOT NULL DEFAULT '',
+    owner        VARCHAR(255) NOT NULL DEFAULT '',
+    display_name VARCHAR(255) NOT NULL DEFAULT '',
+    PRIMARY KEY (type, key)
+);
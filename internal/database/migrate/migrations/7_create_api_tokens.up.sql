@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS api_tokens
+(
+    id         VARCHAR(255) PRIMARY KEY,
+    token_hash CHAR(64)     NOT NULL UNIQUE,
+    scope      VARCHAR(16)  NOT NULL,
+    prefix     VARCHAR(255) NOT NULL DEFAULT ''
+);
@@ -0,0 +1,33 @@
+// Package duration reads the durations of the JSON configuration files.
+package duration
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Duration is a time.Duration given in a JSON file either as a number of
+// seconds or as a string parsed by time.ParseDuration.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	switch value := v.(type) {
+	case float64:
+		d.Duration = time.Duration(value * float64(time.Second))
+		return nil
+	case string:
+		var err error
+		d.Duration, err = time.ParseDuration(value)
+		return err
+	default:
+		return errors.New("invalid duration")
+	}
+}
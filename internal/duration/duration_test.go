@@ -0,0 +1,38 @@
+package duration_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c2pc/go-musthave-metrics/internal/duration"
+)
+
+func TestDuration_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"Seconds", `1.5`, 1500 * time.Millisecond, false},
+		{"String", `"2m"`, 2 * time.Minute, false},
+		{"Invalid string", `"soon"`, 0, true},
+		{"Invalid type", `true`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d duration.Duration
+			err := json.Unmarshal([]byte(tt.data), &d)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, d.Duration)
+		})
+	}
+}
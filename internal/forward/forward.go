@@ -0,0 +1,262 @@
+package forward
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/c2pc/go-musthave-metrics/internal/duration"
+	"github.com/c2pc/go-musthave-metrics/internal/logger"
+	"github.com/c2pc/go-musthave-metrics/internal/retry"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+const (
+	defaultBatchSize     = 500
+	defaultQueueSize     = 10000
+	defaultFlushInterval = 5 * time.Second
+	defaultTimeout       = 10 * time.Second
+)
+
+type SinkType string
+
+const (
+	SinkRemoteWrite SinkType = "remote_write"
+	SinkInflux      SinkType = "influx"
+	SinkWebhook     SinkType = "webhook"
+)
+
+// Sink sends a batch of points to an external system. Counters are sent with
+// their total value.
+type Sink interface {
+	Send(ctx context.Context, points []storage.Point) error
+}
+
+type SinkConfig struct {
+	Name          string            `json:"name"`
+	Type          SinkType          `json:"type"`
+	URL           string            `json:"url"`
+	Headers       map[string]string `json:"headers"`
+	BatchSize     int               `json:"batch_size"`
+	QueueSize     int               `json:"queue_size"`
+	FlushInterval duration.Duration `json:"flush_interval"`
+	Timeout       duration.Duration `json:"timeout"`
+}
+
+type Config struct {
+	Sinks []SinkConfig `json:"sinks"`
+}
+
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse forward config: %s", err)
+	}
+
+	for i := range cfg.Sinks {
+		sink := &cfg.Sinks[i]
+		if sink.Name == "" {
+			sink.Name = string(sink.Type)
+		}
+		if err := sink.validate(); err != nil {
+			return nil, fmt.Errorf("sink %d: %s", i, err)
+		}
+	}
+
+	return &cfg, nil
+}
+
+func (cfg *SinkConfig) validate() error {
+	switch cfg.Type {
+	case SinkRemoteWrite, SinkInflux, SinkWebhook:
+	default:
+		return fmt.Errorf("invalid type: %s", cfg.Type)
+	}
+	if cfg.URL == "" {
+		return errors.New("url is empty")
+	}
+
+	if cfg.BatchSize < 0 || cfg.QueueSize < 0 || cfg.FlushInterval.Duration < 0 || cfg.Timeout.Duration < 0 {
+		return errors.New("negative batch_size, queue_size, flush_interval or timeout")
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.QueueSize == 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	if cfg.FlushInterval.Duration == 0 {
+		cfg.FlushInterval.Duration = defaultFlushInterval
+	}
+	if cfg.Timeout.Duration == 0 {
+		cfg.Timeout.Duration = defaultTimeout
+	}
+
+	return nil
+}
+
+func newSink(cfg SinkConfig) Sink {
+	client := &httpClient{
+		client:  &http.Client{Timeout: cfg.Timeout.Duration},
+		url:     cfg.URL,
+		headers: cfg.Headers,
+	}
+
+	switch cfg.Type {
+	case SinkRemoteWrite:
+		return &RemoteWrite{client: client}
+	case SinkInflux:
+		return &Influx{client: client}
+	default:
+		return &Webhook{client: client}
+	}
+}
+
+// Forwarder fans the points out to the sinks. Every sink has its own queue,
+// the points are dropped when the queue is full so that a slow sink never
+// blocks the caller.
+type Forwarder struct {
+	queues []*queue
+}
+
+type queue struct {
+	name          string
+	sink          Sink
+	points        chan storage.Point
+	batchSize     int
+	flushInterval time.Duration
+	delays        []time.Duration
+	dropped       atomic.Int64
+}
+
+// New creates the forwarder, delays are the pauses between the attempts to
+// send a batch.
+func New(cfg *Config, delays []time.Duration) *Forwarder {
+	f := &Forwarder{}
+	for _, sinkCfg := range cfg.Sinks {
+		f.queues = append(f.queues, &queue{
+			name:          sinkCfg.Name,
+			sink:          newSink(sinkCfg),
+			points:        make(chan storage.Point, sinkCfg.QueueSize),
+			batchSize:     sinkCfg.BatchSize,
+			flushInterval: sinkCfg.FlushInterval.Duration,
+			delays:        delays,
+		})
+	}
+	return f
+}
+
+func (f *Forwarder) Forward(points ...storage.Point) {
+	for _, q := range f.queues {
+		for _, point := range points {
+			select {
+			case q.points <- point:
+			default:
+				q.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// Run sends the queued points until the context is done.
+func (f *Forwarder) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, q := range f.queues {
+		wg.Add(1)
+		go func(q *queue) {
+			defer wg.Done()
+			q.run(ctx)
+		}(q)
+	}
+	wg.Wait()
+}
+
+func (q *queue) run(ctx context.Context) {
+	ticker := time.NewTicker(q.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]storage.Point, 0, q.batchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case point := <-q.points:
+			batch = append(batch, point)
+			if len(batch) >= q.batchSize {
+				q.flush(ctx, batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				q.flush(ctx, batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+func (q *queue) flush(ctx context.Context, batch []storage.Point) {
+	if dropped := q.dropped.Swap(0); dropped > 0 {
+		logger.Log.Info("Forward queue is full, dropped points", logger.Any("sink", q.name), logger.Any("dropped", dropped))
+	}
+
+	err := retry.Retry(
+		func() error {
+			return q.sink.Send(ctx, batch)
+		},
+		func(err error) bool {
+			var statusErr *retry.StatusError
+			if errors.As(err, &statusErr) {
+				return statusErr.Temporary()
+			}
+			return ctx.Err() == nil
+		},
+		q.delays,
+	)
+	if err != nil {
+		logger.Log.Info("Failed to forward points", logger.Any("sink", q.name), logger.Any("points", len(batch)), logger.Error(err))
+	}
+}
+
+type httpClient struct {
+	client  *http.Client
+	url     string
+	headers map[string]string
+}
+
+func (c *httpClient) post(ctx context.Context, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &retry.StatusError{Code: resp.StatusCode}
+	}
+
+	return nil
+}
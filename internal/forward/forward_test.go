@@ -0,0 +1,272 @@
+package forward_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/c2pc/go-musthave-metrics/internal/duration"
+	"github.com/c2pc/go-musthave-metrics/internal/forward"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+var delays = []time.Duration{time.Millisecond, time.Millisecond, time.Millisecond}
+
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	body, _ := io.ReadAll(req.Body)
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+}
+
+func (r *receiver) received() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.bodies)
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    *forward.Config
+		wantErr bool
+	}{
+		{
+			name: "Defaults",
+			data: `{"sinks": [{"type": "influx", "url": "http://localhost:8086/api/v2/write"}]}`,
+			want: &forward.Config{Sinks: []forward.SinkConfig{{
+				Name:          "influx",
+				Type:          forward.SinkInflux,
+				URL:           "http://localhost:8086/api/v2/write",
+				BatchSize:     500,
+				QueueSize:     10000,
+				FlushInterval: duration.Duration{Duration: 5 * time.Second},
+				Timeout:       duration.Duration{Duration: 10 * time.Second},
+			}}},
+		},
+		{
+			name: "Custom",
+			data: `{"sinks": [{"name": "prom", "type": "remote_write", "url": "http://localhost:9090/api/v1/write", "headers": {"Authorization": "Bearer token"}, "batch_size": 10, "queue_size": 100, "flush_interval": "1s", "timeout": 2}]}`,
+			want: &forward.Config{Sinks: []forward.SinkConfig{{
+				Name:          "prom",
+				Type:          forward.SinkRemoteWrite,
+				URL:           "http://localhost:9090/api/v1/write",
+				Headers:       map[string]string{"Authorization": "Bearer token"},
+				BatchSize:     10,
+				QueueSize:     100,
+				FlushInterval: duration.Duration{Duration: time.Second},
+				Timeout:       duration.Duration{Duration: 2 * time.Second},
+			}}},
+		},
+		{name: "Invalid JSON", data: `{`, wantErr: true},
+		{name: "Invalid type", data: `{"sinks": [{"type": "kafka", "url": "http://localhost"}]}`, wantErr: true},
+		{name: "Empty URL", data: `{"sinks": [{"type": "webhook"}]}`, wantErr: true},
+		{name: "Negative batch size", data: `{"sinks": [{"type": "webhook", "url": "http://localhost", "batch_size": -1}]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "forward.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.data), 0644))
+
+			got, err := forward.Load(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestForwarder(t *testing.T) {
+	remoteWrite := &receiver{failures: 2}
+	influx := &receiver{}
+	webhook := &receiver{}
+
+	servers := map[forward.SinkType]*receiver{
+		forward.SinkRemoteWrite: remoteWrite,
+		forward.SinkInflux:      influx,
+		forward.SinkWebhook:     webhook,
+	}
+
+	cfg := &forward.Config{}
+	for sinkType, r := range servers {
+		server := httptest.NewServer(r)
+		defer server.Close()
+		cfg.Sinks = append(cfg.Sinks, forward.SinkConfig{
+			Name:          string(sinkType),
+			Type:          sinkType,
+			URL:           server.URL,
+			Headers:       map[string]string{"Authorization": "Bearer token"},
+			BatchSize:     3,
+			QueueSize:     10,
+			FlushInterval: duration.Duration{Duration: time.Hour},
+			Timeout:       duration.Duration{Duration: time.Second},
+		})
+	}
+
+	forwarder := forward.New(cfg, delays)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go forwarder.Run(ctx)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	forwarder.Forward(
+		storage.Point{Type: "gauge", ID: "Heap Alloc", Time: now, Value: 1.5},
+		storage.Point{Type: "counter", ID: "PollCount", Time: now, Value: 5},
+		storage.Point{Type: "gauge", ID: "Heap Alloc", Time: now.Add(time.Second), Value: 2},
+	)
+
+	for _, r := range servers {
+		require.Eventually(t, func() bool { return r.received() == 1 }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, "Bearer token", r.requests[0].Header.Get("Authorization"))
+	}
+
+	t.Run("Remote write", func(t *testing.T) {
+		assert.Equal(t, "snappy", remoteWrite.requests[0].Header.Get("Content-Encoding"))
+		assert.Equal(t, "application/x-protobuf", remoteWrite.requests[0].Header.Get("Content-Type"))
+
+		data, err := snappy.Decode(nil, remoteWrite.bodies[0])
+		require.NoError(t, err)
+		assert.Equal(t, []series{
+			{labels: []string{"__name__=Heap_Alloc", "type=gauge"}, samples: [][2]float64{{1.5, float64(now.UnixMilli())}, {2, float64(now.Add(time.Second).UnixMilli())}}},
+			{labels: []string{"__name__=PollCount_total", "type=counter"}, samples: [][2]float64{{5, float64(now.UnixMilli())}}},
+		}, parseWriteRequest(t, data))
+	})
+
+	t.Run("Influx", func(t *testing.T) {
+		assert.Equal(t, "Heap\\ Alloc,type=gauge value=1.5 1704067200000000000\n"+
+			"PollCount,type=counter value=5i 1704067200000000000\n"+
+			"Heap\\ Alloc,type=gauge value=2 1704067201000000000\n", string(influx.bodies[0]))
+	})
+
+	t.Run("Webhook", func(t *testing.T) {
+		var got []map[string]interface{}
+		require.NoError(t, json.Unmarshal(webhook.bodies[0], &got))
+		require.Len(t, got, 3)
+		assert.Equal(t, map[string]interface{}{"id": "PollCount", "type": "counter", "value": 5.0, "time": "2024-01-01T00:00:00Z"}, got[1])
+	})
+}
+
+func TestForwarder_SlowSink(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	forwarder := forward.New(&forward.Config{Sinks: []forward.SinkConfig{{
+		Name:          "slow",
+		Type:          forward.SinkWebhook,
+		URL:           slow.URL,
+		BatchSize:     1,
+		QueueSize:     1,
+		FlushInterval: duration.Duration{Duration: time.Hour},
+		Timeout:       duration.Duration{Duration: time.Minute},
+	}}}, delays)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go forwarder.Run(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			forwarder.Forward(storage.Point{Type: "gauge", ID: "Alloc", Time: time.Now(), Value: float64(i)})
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Forward blocked on a slow sink")
+	}
+}
+
+type series struct {
+	labels  []string
+	samples [][2]float64
+}
+
+func parseWriteRequest(t *testing.T, b []byte) []series {
+	var result []series
+	for _, ts := range fields(t, b) {
+		var s series
+		for _, field := range fields(t, ts.bytes) {
+			switch field.num {
+			case 1:
+				label := fields(t, field.bytes)
+				s.labels = append(s.labels, string(label[0].bytes)+"="+string(label[1].bytes))
+			case 2:
+				sample := fields(t, field.bytes)
+				s.samples = append(s.samples, [2]float64{math.Float64frombits(sample[0].value), float64(int64(sample[1].value))})
+			}
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+type field struct {
+	num   protowire.Number
+	bytes []byte
+	value uint64
+}
+
+func fields(t *testing.T, b []byte) []field {
+	var result []field
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+
+		f := field{num: num}
+		switch typ {
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		case protowire.Fixed64Type:
+			f.value, n = protowire.ConsumeFixed64(b)
+		case protowire.VarintType:
+			f.value, n = protowire.ConsumeVarint(b)
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		result = append(result, f)
+	}
+	return result
+}
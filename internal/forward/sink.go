@@ -0,0 +1,180 @@
+package forward
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/c2pc/go-musthave-metrics/internal/prometheus"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+// RemoteWrite sends the points with the Prometheus remote write protocol,
+// every series is labeled with the type of its metric and the names of the
+// counters end with _total:
+//
+//	message WriteRequest {
+//	  repeated TimeSeries timeseries = 1;
+//	}
+//
+//	message TimeSeries {
+//	  repeated Label labels = 1;
+//	  repeated Sample samples = 2;
+//	}
+//
+//	message Label {
+//	  string name = 1;
+//	  string value = 2;
+//	}
+//
+//	message Sample {
+//	  double value = 1;
+//	  int64 timestamp = 2;
+//	}
+type RemoteWrite struct {
+	client *httpClient
+}
+
+const (
+	fieldTimeSeries = 1
+
+	fieldLabels  = 1
+	fieldSamples = 2
+
+	fieldLabelName  = 1
+	fieldLabelValue = 2
+
+	fieldSampleValue     = 1
+	fieldSampleTimestamp = 2
+)
+
+func (s *RemoteWrite) Send(ctx context.Context, points []storage.Point) error {
+	return s.client.post(ctx, snappy.Encode(nil, marshalWriteRequest(points)), map[string]string{
+		"Content-Type":                      "application/x-protobuf",
+		"Content-Encoding":                  "snappy",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	})
+}
+
+func marshalWriteRequest(points []storage.Point) []byte {
+	// one series per metric, the samples keep the order of the updates
+	type seriesKey struct {
+		metricType string
+		name       string
+	}
+
+	var keys []seriesKey
+	samples := make(map[seriesKey][]byte)
+	for _, point := range points {
+		key := seriesKey{metricType: point.Type, name: prometheus.MetricName(point.Type, point.ID)}
+		if _, ok := samples[key]; !ok {
+			keys = append(keys, key)
+		}
+
+		var sample []byte
+		sample = protowire.AppendTag(sample, fieldSampleValue, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(point.Value))
+		sample = protowire.AppendTag(sample, fieldSampleTimestamp, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(point.Time.UnixMilli()))
+
+		samples[key] = protowire.AppendTag(samples[key], fieldSamples, protowire.BytesType)
+		samples[key] = protowire.AppendBytes(samples[key], sample)
+	}
+
+	var b []byte
+	for _, key := range keys {
+		// the labels are sorted by name
+		var series []byte
+		series = protowire.AppendTag(series, fieldLabels, protowire.BytesType)
+		series = protowire.AppendBytes(series, marshalLabel("__name__", key.name))
+		series = protowire.AppendTag(series, fieldLabels, protowire.BytesType)
+		series = protowire.AppendBytes(series, marshalLabel("type", key.metricType))
+		series = append(series, samples[key]...)
+
+		b = protowire.AppendTag(b, fieldTimeSeries, protowire.BytesType)
+		b = protowire.AppendBytes(b, series)
+	}
+	return b
+}
+
+func marshalLabel(name string, value string) []byte {
+	var label []byte
+	label = protowire.AppendTag(label, fieldLabelName, protowire.BytesType)
+	label = protowire.AppendString(label, name)
+	label = protowire.AppendTag(label, fieldLabelValue, protowire.BytesType)
+	label = protowire.AppendString(label, value)
+	return label
+}
+
+// Influx sends the points in the InfluxDB line protocol, the metric is the
+// measurement and its type is a tag:
+//
+//	HeapAlloc,type=gauge value=1024 1704067200000000000
+type Influx struct {
+	client *httpClient
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `)
+	tagEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `)
+)
+
+func (s *Influx) Send(ctx context.Context, points []storage.Point) error {
+	return s.client.post(ctx, []byte(marshalLines(points)), map[string]string{
+		"Content-Type": "text/plain; charset=utf-8",
+	})
+}
+
+func marshalLines(points []storage.Point) string {
+	var b strings.Builder
+	for _, point := range points {
+		b.WriteString(measurementEscaper.Replace(point.ID))
+		b.WriteString(",type=")
+		b.WriteString(tagEscaper.Replace(point.Type))
+		b.WriteString(" value=")
+		if point.Type == "counter" {
+			b.WriteString(strconv.FormatInt(int64(point.Value), 10))
+			b.WriteByte('i')
+		} else {
+			b.WriteString(strconv.FormatFloat(point.Value, 'g', -1, 64))
+		}
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatInt(point.Time.UnixNano(), 10))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Webhook posts the points as a JSON array.
+type Webhook struct {
+	client *httpClient
+}
+
+type webhookPoint struct {
+	ID    string    `json:"id"`
+	Type  string    `json:"type"`
+	Value float64   `json:"value"`
+	Time  time.Time `json:"time"`
+}
+
+func (s *Webhook) Send(ctx context.Context, points []storage.Point) error {
+	body := make([]webhookPoint, len(points))
+	for i, point := range points {
+		body[i] = webhookPoint{ID: point.ID, Type: point.Type, Value: point.Value, Time: point.Time}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	return s.client.post(ctx, data, map[string]string{
+		"Content-Type": "application/json",
+	})
+}
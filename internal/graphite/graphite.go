@@ -0,0 +1,195 @@
+package graphite
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c2pc/go-musthave-metrics/internal/duration"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+)
+
+const (
+	TypeGauge   = "gauge"
+	TypeCounter = "counter"
+	// TypeDrop skips the matching paths.
+	TypeDrop = "drop"
+)
+
+const (
+	defaultMaxConnections = 100
+	defaultMaxLineLength  = 4096
+	defaultIdleTimeout    = 2 * time.Minute
+	defaultBatchSize      = 1000
+	defaultFlushInterval  = time.Second
+)
+
+// Rule maps the paths matching the glob or the regex to a metric type. In
+// the glob * matches one node of the path, e.g. servers.*.requests.
+type Rule struct {
+	Match string `json:"match"`
+	Regex string `json:"regex"`
+	Type  string `json:"type"`
+
+	re *regexp.Regexp
+}
+
+type Config struct {
+	// DefaultType is used for the paths that match no rule.
+	DefaultType    string            `json:"default_type"`
+	Rules          []Rule            `json:"rules"`
+	MaxConnections int               `json:"max_connections"`
+	MaxLineLength  int               `json:"max_line_length"`
+	IdleTimeout    duration.Duration `json:"idle_timeout"`
+	BatchSize      int               `json:"batch_size"`
+	FlushInterval  duration.Duration `json:"flush_interval"`
+}
+
+func DefaultConfig() *Config {
+	cfg := &Config{}
+	_ = cfg.validate()
+	return cfg
+}
+
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse graphite config: %s", err)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+func (cfg *Config) validate() error {
+	if cfg.DefaultType == "" {
+		cfg.DefaultType = TypeGauge
+	}
+	if !isValidType(cfg.DefaultType) {
+		return fmt.Errorf("invalid default_type: %s", cfg.DefaultType)
+	}
+
+	for i := range cfg.Rules {
+		rule := &cfg.Rules[i]
+		if !isValidType(rule.Type) {
+			return fmt.Errorf("rule %d: invalid type: %s", i, rule.Type)
+		}
+
+		var err error
+		switch {
+		case rule.Match != "" && rule.Regex != "":
+			return fmt.Errorf("rule %d: both match and regex are set", i)
+		case rule.Regex != "":
+			rule.re, err = regexp.Compile("^(?:" + rule.Regex + ")$")
+		case rule.Match != "":
+			pattern := strings.ReplaceAll(regexp.QuoteMeta(rule.Match), `\*`, `[^.]*`)
+			rule.re, err = regexp.Compile("^" + pattern + "$")
+		default:
+			return fmt.Errorf("rule %d: match is empty", i)
+		}
+		if err != nil {
+			return fmt.Errorf("rule %d: invalid regex: %s", i, err)
+		}
+	}
+
+	if cfg.MaxConnections < 0 || cfg.MaxLineLength < 0 || cfg.IdleTimeout.Duration < 0 || cfg.BatchSize < 0 || cfg.FlushInterval.Duration < 0 {
+		return errors.New("negative max_connections, max_line_length, idle_timeout, batch_size or flush_interval")
+	}
+	if cfg.MaxConnections == 0 {
+		cfg.MaxConnections = defaultMaxConnections
+	}
+	if cfg.MaxLineLength == 0 {
+		cfg.MaxLineLength = defaultMaxLineLength
+	}
+	if cfg.IdleTimeout.Duration == 0 {
+		cfg.IdleTimeout.Duration = defaultIdleTimeout
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.FlushInterval.Duration == 0 {
+		cfg.FlushInterval.Duration = defaultFlushInterval
+	}
+
+	return nil
+}
+
+func isValidType(t string) bool {
+	return t == TypeGauge || t == TypeCounter || t == TypeDrop
+}
+
+// metricType returns the type of the first rule matching the path.
+func (cfg *Config) metricType(path string) string {
+	for _, rule := range cfg.Rules {
+		if rule.re.MatchString(path) {
+			return rule.Type
+		}
+	}
+	return cfg.DefaultType
+}
+
+// Line is a line of the plaintext protocol: path value [timestamp].
+type Line struct {
+	Path  string
+	Value float64
+	// Time is zero when the line has no timestamp or it is -1
+	Time time.Time
+}
+
+func Parse(line string) (Line, error) {
+	parts := strings.Fields(line)
+	if len(parts) != 2 && len(parts) != 3 {
+		return Line{}, errors.New("invalid line")
+	}
+
+	value, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return Line{}, fmt.Errorf("invalid value: %s", parts[1])
+	}
+
+	result := Line{Path: parts[0], Value: value}
+	if len(parts) == 3 && parts[2] != "-1" {
+		timestamp, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil || timestamp < 0 {
+			return Line{}, fmt.Errorf("invalid timestamp: %s", parts[2])
+		}
+		result.Time = time.Unix(int64(timestamp), 0).UTC()
+	}
+
+	return result, nil
+}
+
+// metric maps the line to a metric, ok is false when the path is dropped.
+func (cfg *Config) metric(line Line) (model.Metrics, bool, error) {
+	metric := model.Metrics{ID: line.Path}
+	switch cfg.metricType(line.Path) {
+	case TypeGauge:
+		value := line.Value
+		metric.Type = TypeGauge
+		metric.Value = &value
+	case TypeCounter:
+		if line.Value != math.Trunc(line.Value) || math.Abs(line.Value) > math.MaxInt64 {
+			return model.Metrics{}, false, fmt.Errorf("counter %s: value is not an integer", line.Path)
+		}
+		delta := int64(line.Value)
+		metric.Type = TypeCounter
+		metric.Delta = &delta
+	default:
+		return model.Metrics{}, false, nil
+	}
+	return metric, true, nil
+}
@@ -0,0 +1,203 @@
+package graphite_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/graphite"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    graphite.Line
+		wantErr bool
+	}{
+		{name: "With timestamp", line: "servers.a.load 1.5 1704067200", want: graphite.Line{Path: "servers.a.load", Value: 1.5, Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{name: "Without timestamp", line: "servers.a.load 2", want: graphite.Line{Path: "servers.a.load", Value: 2}},
+		{name: "Now timestamp", line: "servers.a.load 2 -1", want: graphite.Line{Path: "servers.a.load", Value: 2}},
+		{name: "Extra spaces", line: "  servers.a.load\t2  ", want: graphite.Line{Path: "servers.a.load", Value: 2}},
+		{name: "Missing value", line: "servers.a.load", wantErr: true},
+		{name: "Invalid value", line: "servers.a.load high", wantErr: true},
+		{name: "NaN", line: "servers.a.load nan", wantErr: true},
+		{name: "Invalid timestamp", line: "servers.a.load 1 today", wantErr: true},
+		{name: "Too many parts", line: "servers.a.load 1 2 3", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := graphite.Parse(tt.line)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "Valid", data: `{"default_type": "drop", "rules": [{"match": "servers.*.requests", "type": "counter"}, {"regex": "servers\\..+", "type": "gauge"}], "idle_timeout": "30s"}`},
+		{name: "Invalid JSON", data: `{`, wantErr: true},
+		{name: "Invalid default type", data: `{"default_type": "histogram"}`, wantErr: true},
+		{name: "Invalid rule type", data: `{"rules": [{"match": "a", "type": "histogram"}]}`, wantErr: true},
+		{name: "Empty match", data: `{"rules": [{"type": "gauge"}]}`, wantErr: true},
+		{name: "Match and regex", data: `{"rules": [{"match": "a", "regex": "a", "type": "gauge"}]}`, wantErr: true},
+		{name: "Invalid regex", data: `{"rules": [{"regex": "(", "type": "gauge"}]}`, wantErr: true},
+		{name: "Negative limit", data: `{"max_connections": -1}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "graphite.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.data), 0644))
+
+			_, err := graphite.Load(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+type updaterMock struct {
+	mu      sync.Mutex
+	batches [][]model.Metrics
+	times   []time.Time
+}
+
+func (u *updaterMock) Update(_ context.Context, metrics []model.Metrics, times []time.Time) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.batches = append(u.batches, metrics)
+	u.times = append(u.times, times...)
+	return nil
+}
+
+// values returns the received metrics as "type id value" in the order they
+// were received.
+func (u *updaterMock) values() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var result []string
+	for _, batch := range u.batches {
+		for _, m := range batch {
+			if m.Value != nil {
+				result = append(result, fmt.Sprintf("%s %s %g", m.Type, m.ID, *m.Value))
+			} else {
+				result = append(result, fmt.Sprintf("%s %s %d", m.Type, m.ID, *m.Delta))
+			}
+		}
+	}
+	return result
+}
+
+func TestServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graphite.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"default_type": "drop",
+		"rules": [
+			{"match": "servers.*.requests", "type": "counter"},
+			{"match": "servers.*.*", "type": "gauge"}
+		],
+		"max_connections": 1,
+		"max_line_length": 64,
+		"flush_interval": "10ms"
+	}`), 0644))
+
+	cfg, err := graphite.Load(path)
+	require.NoError(t, err)
+
+	updater := &updaterMock{}
+	server, err := graphite.Listen("127.0.0.1:0", cfg, updater)
+	require.NoError(t, err)
+	go server.Serve()
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = fmt.Fprint(conn, "servers.a.requests 3 1704067200\n"+
+		"servers.a.load 0.5\n"+
+		"servers.a.requests 1.5\n"+
+		"invalid\n"+
+		"other.metric 1\n"+
+		"servers.a.requests 2\n")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return len(updater.values()) == 3 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"counter servers.a.requests 3", "gauge servers.a.load 0.5", "counter servers.a.requests 2"}, updater.values())
+
+	// the timestamps of the lines are kept, the others are written now
+	updater.mu.Lock()
+	assert.Equal(t, []time.Time{time.Unix(1704067200, 0).UTC(), {}, {}}, updater.times)
+	updater.mu.Unlock()
+
+	t.Run("Max connections", func(t *testing.T) {
+		second, err := net.Dial("tcp", server.Addr().String())
+		require.NoError(t, err)
+		defer second.Close()
+
+		require.NoError(t, second.SetReadDeadline(time.Now().Add(time.Second)))
+		_, err = second.Read(make([]byte, 1))
+		assert.Error(t, err)
+	})
+
+	t.Run("UDP", func(t *testing.T) {
+		udp, err := net.Dial("udp", server.Addr().String())
+		require.NoError(t, err)
+		defer udp.Close()
+
+		_, err = fmt.Fprint(udp, "servers.b.load 1\nservers.b.requests 4\n")
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool { return len(updater.values()) == 5 }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, []string{"gauge servers.b.load 1", "counter servers.b.requests 4"}, updater.values()[3:])
+	})
+
+	t.Run("Too long line", func(t *testing.T) {
+		_, err := fmt.Fprintf(conn, "servers.c.%0100d 1\n", 0)
+		require.NoError(t, err)
+
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		_, err = conn.Read(make([]byte, 1))
+		assert.Error(t, err)
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		conn, err := net.Dial("tcp", server.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		_, err = fmt.Fprint(conn, "servers.d.load 7\n")
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return len(updater.values()) == 6 }, time.Second, 5*time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NoError(t, server.Stop(ctx))
+
+		_, err = net.Dial("tcp", server.Addr().String())
+		assert.Error(t, err)
+	})
+}
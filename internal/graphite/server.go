@@ -0,0 +1,282 @@
+package graphite
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c2pc/go-musthave-metrics/internal/logger"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+)
+
+const maxDatagramSize = 64 * 1024
+
+// Updater stores the metrics, times[i] is the time of metrics[i] or zero when
+// its line had no timestamp.
+type Updater interface {
+	Update(ctx context.Context, metrics []model.Metrics, times []time.Time) error
+}
+
+// point is a received metric with the time of its line.
+type point struct {
+	metric model.Metrics
+	time   time.Time
+}
+
+// Server receives the plaintext protocol over TCP and UDP on the same
+// address and writes the metrics to the updater in batches, with the
+// timestamps of their lines.
+type Server struct {
+	cfg      *Config
+	updater  Updater
+	tcp      net.Listener
+	udp      net.PacketConn
+	metrics  chan point
+	slots    chan struct{}
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	stopping bool
+	readers  sync.WaitGroup
+	flushed  chan struct{}
+}
+
+// Listen binds the TCP and UDP sockets and starts receiving the datagrams,
+// the TCP connections are accepted by Serve.
+func Listen(address string, cfg *Config, updater Updater) (*Server, error) {
+	tcp, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	udp, err := net.ListenPacket("udp", tcp.Addr().String())
+	if err != nil {
+		_ = tcp.Close()
+		return nil, err
+	}
+
+	s := &Server{
+		cfg:     cfg,
+		updater: updater,
+		tcp:     tcp,
+		udp:     udp,
+		metrics: make(chan point, cfg.BatchSize),
+		slots:   make(chan struct{}, cfg.MaxConnections),
+		conns:   make(map[net.Conn]struct{}),
+		flushed: make(chan struct{}),
+	}
+
+	go s.write()
+
+	s.readers.Add(1)
+	go func() {
+		defer s.readers.Done()
+		s.readUDP()
+	}()
+
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.tcp.Addr()
+}
+
+// Serve accepts the TCP connections until the server is stopped.
+func (s *Server) Serve() {
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logger.Log.Info("Failed to accept graphite connection", logger.Error(err))
+			}
+			return
+		}
+
+		select {
+		case s.slots <- struct{}{}:
+		default:
+			logger.Log.Info("Too many graphite connections", logger.Any("remote", conn.RemoteAddr().String()))
+			_ = conn.Close()
+			continue
+		}
+
+		if !s.track(conn) {
+			<-s.slots
+			_ = conn.Close()
+			return
+		}
+
+		go func() {
+			defer s.readers.Done()
+			defer s.untrack(conn)
+			s.readTCP(conn)
+		}()
+	}
+}
+
+// Stop closes the listeners and the connections and waits until the
+// received metrics are written.
+func (s *Server) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopping = true
+	for conn := range s.conns {
+		// stop the reads, the lines already read are still written
+		_ = conn.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	_ = s.tcp.Close()
+	_ = s.udp.Close()
+
+	readersDone := make(chan struct{})
+	go func() {
+		s.readers.Wait()
+		close(s.metrics)
+		close(readersDone)
+	}()
+
+	select {
+	case <-readersDone:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-s.flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopping {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.readers.Add(1)
+	return true
+}
+
+// untrack frees the slot of the connection before closing it, so that the
+// client can connect again once it sees the connection closed.
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+
+	<-s.slots
+	_ = conn.Close()
+}
+
+func (s *Server) readTCP(conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	// the limit is the larger of the capacity and the maximum
+	scanner.Buffer(make([]byte, 0, min(4096, s.cfg.MaxLineLength)), s.cfg.MaxLineLength)
+
+	for {
+		s.mu.Lock()
+		if !s.stopping {
+			_ = conn.SetReadDeadline(time.Now().Add(s.cfg.IdleTimeout.Duration))
+		}
+		s.mu.Unlock()
+
+		if !scanner.Scan() {
+			break
+		}
+		s.receive(scanner.Text())
+	}
+
+	var netErr net.Error
+	if err := scanner.Err(); err != nil && !(errors.As(err, &netErr) && netErr.Timeout()) {
+		logger.Log.Info("Graphite connection failed", logger.Any("remote", conn.RemoteAddr().String()), logger.Error(err))
+	}
+}
+
+func (s *Server) readUDP() {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, _, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logger.Log.Info("Failed to read graphite datagram", logger.Error(err))
+			}
+			return
+		}
+
+		for _, line := range bytes.Split(buf[:n], []byte("\n")) {
+			if len(line) > s.cfg.MaxLineLength {
+				logger.Log.Info("Graphite line is too long", logger.Any("length", len(line)))
+				continue
+			}
+			s.receive(string(line))
+		}
+	}
+}
+
+func (s *Server) receive(text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+
+	line, err := Parse(text)
+	if err != nil {
+		logger.Log.Info("Invalid graphite line", logger.Any("line", text), logger.Error(err))
+		return
+	}
+
+	metric, ok, err := s.cfg.metric(line)
+	if err != nil {
+		logger.Log.Info("Invalid graphite line", logger.Any("line", text), logger.Error(err))
+		return
+	}
+	if ok {
+		s.metrics <- point{metric: metric, time: line.Time}
+	}
+}
+
+// write batches the received metrics until the channel is closed.
+func (s *Server) write() {
+	defer close(s.flushed)
+
+	ticker := time.NewTicker(s.cfg.FlushInterval.Duration)
+	defer ticker.Stop()
+
+	batch := make([]model.Metrics, 0, s.cfg.BatchSize)
+	times := make([]time.Time, 0, s.cfg.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.updater.Update(context.Background(), batch, times); err != nil {
+			logger.Log.Info("Failed to write graphite metrics", logger.Any("metrics", len(batch)), logger.Error(err))
+		}
+		batch = make([]model.Metrics, 0, s.cfg.BatchSize)
+		times = make([]time.Time, 0, s.cfg.BatchSize)
+	}
+
+	for {
+		select {
+		case p, ok := <-s.metrics:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, p.metric)
+			times = append(times, p.time)
+			if len(batch) >= s.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/c2pc/go-musthave-metrics/internal/auth"
+	"github.com/c2pc/go-musthave-metrics/internal/handler/middleware"
+)
+
+// WithAuth requires a bearer token on every route but /ping.
+func WithAuth(a *middleware.Auth) Option {
+	return func(h *Handler) {
+		h.auth = a
+	}
+}
+
+func forbiddenMetric(id string) string {
+	return "The token does not allow the metric " + id
+}
+
+// allowedKeys drops the metrics outside of the prefix of the request token.
+func allowedKeys[T any](ctx context.Context, values map[string]T) map[string]T {
+	token := auth.FromContext(ctx)
+	if token.Prefix == "" {
+		return values
+	}
+
+	allowed := make(map[string]T, len(values))
+	for key, value := range values {
+		if token.Allows(key) {
+			allowed[key] = value
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,91 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/auth"
+	"github.com/c2pc/go-musthave-metrics/internal/handler"
+	"github.com/c2pc/go-musthave-metrics/internal/handler/middleware"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+func TestMetricHandler_Auth(t *testing.T) {
+	ctx := context.Background()
+
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, gaugeStorage.Set(ctx,
+		storage.Value[float64]{Key: "host1.load", Value: 1},
+		storage.Value[float64]{Key: "host2.load", Value: 2},
+	))
+
+	authenticator := auth.New([]auth.Token{
+		{ID: "reader", Secret: "read-secret", Scope: auth.ScopeRead},
+		{ID: "host1", Secret: "host1-secret", Scope: auth.ScopeWrite, Prefix: "host1."},
+		{ID: "ops", Secret: "admin-secret", Scope: auth.ScopeAdmin},
+	}, nil)
+	handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil, handler.WithAuth(middleware.NewAuth(authenticator)))
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		token          string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"Missing token", http.MethodGet, "/value/gauge/host1.load", "", "", http.StatusUnauthorized, `{"error":"The bearer token is missing"}`},
+		{"Invalid token", http.MethodGet, "/value/gauge/host1.load", "wrong", "", http.StatusUnauthorized, `{"error":"Invalid token"}`},
+		{"Read", http.MethodGet, "/value/gauge/host2.load", "read-secret", "", http.StatusOK, ""},
+		{"Read can't write", http.MethodPost, "/update/gauge/host2.load/3", "read-secret", "", http.StatusForbidden, `{"error":"The token scope does not allow this request"}`},
+		{"Read can't stream batches", http.MethodGet, "/api/v1/agents/stream", "read-secret", "", http.StatusForbidden, `{"error":"The token scope does not allow this request"}`},
+		{"Write in prefix", http.MethodPost, "/update/gauge/host1.load/3", "host1-secret", "", http.StatusOK, ""},
+		{"Write outside prefix", http.MethodPost, "/update/gauge/host2.load/3", "host1-secret", "", http.StatusForbidden, `{"error":"The token does not allow the metric host2.load"}`},
+		{
+			name:           "Batch outside prefix",
+			method:         http.MethodPost,
+			url:            "/updates/",
+			token:          "host1-secret",
+			body:           `[{"id":"host1.load","type":"gauge","value":4},{"id":"host2.load","type":"gauge","value":4}]`,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"The token does not allow the metric host2.load"}`,
+		},
+		{
+			name:           "Export in prefix",
+			method:         http.MethodGet,
+			url:            "/api/v1/export",
+			token:          "host1-secret",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"id":"host1.load","type":"gauge","value":3}]`,
+		},
+		{"Write can't delete", http.MethodDelete, "/value/gauge/host1.load", "host1-secret", "", http.StatusForbidden, ""},
+		{"Admin deletes", http.MethodDelete, "/value/gauge/host2.load", "admin-secret", "", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if tt.token != "" {
+				request.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler2.ServeHTTP(w, request)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
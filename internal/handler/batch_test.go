@@ -0,0 +1,63 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/handler"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+func TestMetricHandler_HandleUpdatesJSON_Batch(t *testing.T) {
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	batchStorage, err := storage.NewBatchStorage(storage.TypeMemory, nil, time.Minute, gaugeStorage, counterStorage)
+	require.NoError(t, err)
+
+	handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil, handler.WithBatchRegistry(batchStorage))
+
+	tests := []struct {
+		name           string
+		agentID        string
+		sequence       string
+		expectedStatus int
+		expectedValue  int64
+	}{
+		{"Without sequence", "", "", http.StatusOK, 5},
+		{"New batch", "agent1", "1", http.StatusOK, 10},
+		{"Retried batch", "agent1", "1", http.StatusOK, 10},
+		{"Next batch", "agent1", "2", http.StatusOK, 15},
+		{"Other agent", "agent2", "1", http.StatusOK, 20},
+		{"Invalid sequence", "agent1", "invalid", http.StatusBadRequest, 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(`[{"id":"requests","type":"counter","delta":5}]`))
+			request.Header.Set("Content-Type", "application/json")
+			request.Header.Set(model.AgentIDHeader, tt.agentID)
+			request.Header.Set(model.BatchSequenceHeader, tt.sequence)
+			w := httptest.NewRecorder()
+
+			handler2.ServeHTTP(w, request)
+
+			result := w.Result()
+			assert.Equal(t, tt.expectedStatus, result.StatusCode)
+			result.Body.Close()
+
+			value, err := counterStorage.Get(context.Background(), "requests")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedValue, value)
+		})
+	}
+}
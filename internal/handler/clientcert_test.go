@@ -0,0 +1,59 @@
+package handler_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/handler"
+	"github.com/c2pc/go-musthave-metrics/internal/server"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+func TestMetricHandler_ClientCertAgentID(t *testing.T) {
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+
+	cert, err := server.SelfSignedCertificate("agent1")
+	require.NoError(t, err)
+
+	settings := agentSettings{
+		"agent1": {PollInterval: 1},
+		"agent2": {PollInterval: 2},
+	}
+	handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil,
+		handler.WithAgentSettings(settings),
+		handler.WithClientCertAgentID(),
+	)
+
+	tests := []struct {
+		name           string
+		id             string
+		withCert       bool
+		expectedStatus int
+	}{
+		{"Own settings", "agent1", true, http.StatusOK},
+		{"Settings of another agent", "agent2", true, http.StatusForbidden},
+		{"Without certificate", "agent2", false, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/api/v1/agents/"+tt.id+"/config", nil)
+			if tt.withCert {
+				request.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert.Leaf}}
+			}
+			w := httptest.NewRecorder()
+			handler2.ServeHTTP(w, request)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
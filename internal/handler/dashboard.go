@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"embed"
+	"errors"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/c2pc/go-musthave-metrics/internal/logger"
+	"github.com/c2pc/go-musthave-metrics/internal/retry"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+const (
+	defaultDashboardRefresh = 10 * time.Second
+	// maxDashboardRefresh keeps the reload delay of the page within the
+	// 32-bit timers of the browsers
+	maxDashboardRefresh = time.Hour
+	sparklineRange      = time.Hour
+	sparklineStep       = time.Minute
+	sparklineWidth      = 120
+	sparklineHeight     = 20
+	// sparklineLimit bounds the metrics of the history queries of a page,
+	// the sparklines are left out when there are more metrics
+	sparklineLimit = 200
+)
+
+//go:embed templates/dashboard.html
+var templates embed.FS
+
+var dashboardTemplate = template.Must(template.ParseFS(templates, "templates/dashboard.html"))
+
+type dashboardRow struct {
+	ID          string
+	DisplayName string
+	Value       string
+	Unit        string
+	Description string
+	Updated     time.Time
+	Sparkline   string
+}
+
+// Search is the lowercase text matched by the search box.
+func (r dashboardRow) Search() string {
+	return strings.ToLower(r.ID + " " + r.DisplayName + " " + r.Description)
+}
+
+// UpdatedKey sorts the rows by the time of their last update.
+func (r dashboardRow) UpdatedKey() string {
+	if r.Updated.IsZero() {
+		return ""
+	}
+	return r.Updated.UTC().Format(time.RFC3339)
+}
+
+type dashboardTable struct {
+	Type  string
+	Title string
+	Rows  []dashboardRow
+}
+
+type dashboardPage struct {
+	Tables          []dashboardTable
+	Generated       time.Time
+	Refresh         int
+	AutoRefresh     bool
+	History         bool
+	SparklineWidth  int
+	SparklineHeight int
+}
+
+// handleHTML renders the dashboard. The refresh query parameter sets the
+// auto-refresh interval in seconds, 0 turns it off.
+func (h *Handler) handleHTML(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	page := dashboardPage{
+		Generated:       time.Now(),
+		Refresh:         int(defaultDashboardRefresh / time.Second),
+		AutoRefresh:     true,
+		SparklineWidth:  sparklineWidth,
+		SparklineHeight: sparklineHeight,
+	}
+	if value := c.Query("refresh"); value != "" {
+		refresh, err := strconv.Atoi(value)
+		if err != nil || refresh < 0 || refresh > int(maxDashboardRefresh/time.Second) {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		if refresh == 0 {
+			page.AutoRefresh = false
+		} else {
+			page.Refresh = refresh
+		}
+	}
+
+	metas := h.metaIndex(ctx)
+
+	gauges, err := dashboardRows(ctx, h.gaugeStorage, metas[h.gaugeStorage.GetName()])
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	counters, err := dashboardRows(ctx, h.counterStorage, metas[h.counterStorage.GetName()])
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	page.Tables = []dashboardTable{
+		{Type: h.gaugeStorage.GetName(), Title: "Gauges", Rows: gauges},
+		{Type: h.counterStorage.GetName(), Title: "Counters", Rows: counters},
+	}
+
+	if h.history != nil {
+		page.History = true
+		if len(gauges)+len(counters) <= sparklineLimit {
+			for _, table := range page.Tables {
+				h.addSparklines(ctx, table, page.Generated)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := dashboardTemplate.Execute(&buf, page); err != nil {
+		logger.Log.Info("Failed to render dashboard", logger.Error(err))
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// dashboardRows returns the rows of the metrics ordered by name.
+func dashboardRows[T int64 | float64](ctx context.Context, s Storager[T], metas map[string]storage.Meta) ([]dashboardRow, error) {
+	var values map[string]string
+	if err := retry.Retry(
+		func() (err error) {
+			values, err = s.GetAllString(ctx)
+			return
+		},
+		func(err error) bool {
+			return errors.Is(err, driver.ErrBadConn)
+		},
+		[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+	); err != nil {
+		return nil, err
+	}
+
+	var updated map[string]time.Time
+	if err := retry.Retry(
+		func() (err error) {
+			updated, err = s.GetAllUpdated(ctx)
+			return
+		},
+		func(err error) bool {
+			return errors.Is(err, driver.ErrBadConn)
+		},
+		[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+	); err != nil {
+		return nil, err
+	}
+
+	values = allowedKeys(ctx, values)
+
+	rows := make([]dashboardRow, 0, len(values))
+	for id, value := range values {
+		meta := metas[id]
+		rows = append(rows, dashboardRow{
+			ID:          id,
+			DisplayName: meta.DisplayName,
+			Value:       value,
+			Unit:        meta.Unit,
+			Description: meta.Description,
+			Updated:     updated[id],
+		})
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].ID < rows[j].ID
+	})
+
+	return rows, nil
+}
+
+// addSparklines draws the last hour of every metric of the table, gauges
+// by their average and counters by their increase per step. The history of
+// the table is read in one query.
+func (h *Handler) addSparklines(ctx context.Context, table dashboardTable, now time.Time) {
+	if len(table.Rows) == 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(table.Rows))
+	for _, row := range table.Rows {
+		ids = append(ids, row.ID)
+	}
+
+	from := now.Add(-sparklineRange)
+	buckets, err := h.history.QueryMany(ctx, table.Type, ids, from, now, sparklineStep)
+	if err != nil {
+		logger.Log.Info("Failed to get history for dashboard", logger.Any("type", table.Type), logger.Error(err))
+		return
+	}
+
+	for i := range table.Rows {
+		points := downsample(buckets[table.Rows[i].ID], from, sparklineStep, table.Type == h.counterStorage.GetName())
+		values := make([]float64, 0, len(points))
+		for _, point := range points {
+			values = append(values, point.Value)
+		}
+		table.Rows[i].Sparkline = sparkline(values, sparklineWidth, sparklineHeight)
+	}
+}
+
+// sparkline returns the points of an SVG polyline scaled to the box, it is
+// empty when there are less than two values.
+func sparkline(values []float64, width float64, height float64) string {
+	if len(values) < 2 {
+		return ""
+	}
+
+	low, high := values[0], values[0]
+	for _, value := range values {
+		low, high = min(low, value), max(high, value)
+	}
+
+	points := make([]string, 0, len(values))
+	for i, value := range values {
+		x := float64(i) * width / float64(len(values)-1)
+		y := height / 2
+		if high > low {
+			// the line is kept inside the box so that it isn't clipped
+			y = height - 1 - (value-low)/(high-low)*(height-2)
+		}
+		points = append(points, strconv.FormatFloat(x, 'f', 1, 64)+","+strconv.FormatFloat(y, 'f', 1, 64))
+	}
+	return strings.Join(points, " ")
+}
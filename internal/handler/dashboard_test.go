@@ -0,0 +1,89 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/handler"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+func TestMetricHandler_HandleHTML(t *testing.T) {
+	ctx := context.Background()
+
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	historyStorage, err := storage.NewHistoryStorage(storage.TypeMemory, nil, 100, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, gaugeStorage.Set(ctx,
+		storage.Value[float64]{Key: "b", Value: 2},
+		storage.Value[float64]{Key: "<script>alert(1)</script>", Value: 1},
+		storage.Value[float64]{Key: "a", Value: 3},
+	))
+	require.NoError(t, counterStorage.Set(ctx, storage.Value[int64]{Key: "requests", Value: 5}))
+
+	now := time.Now()
+	require.NoError(t, historyStorage.Add(ctx,
+		storage.Point{Type: "gauge", ID: "a", Time: now.Add(-2 * time.Minute), Value: 1},
+		storage.Point{Type: "gauge", ID: "a", Time: now.Add(-time.Minute), Value: 3},
+	))
+
+	handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil, handler.WithHistory(historyStorage))
+
+	do := func(url string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+		handler2.ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+
+	t.Run("Escaping", func(t *testing.T) {
+		status, body := do("/")
+		require.Equal(t, http.StatusOK, status)
+		assert.NotContains(t, body, "<script>alert(1)</script>")
+		assert.Contains(t, body, "&lt;script&gt;alert(1)&lt;/script&gt;")
+	})
+
+	t.Run("Sorted", func(t *testing.T) {
+		_, body := do("/")
+		first := strings.Index(body, `<td data-key="&lt;script&gt;alert(1)&lt;/script&gt;">`)
+		second := strings.Index(body, `<td data-key="a">`)
+		third := strings.Index(body, `<td data-key="b">`)
+		counter := strings.Index(body, `<td data-key="requests">`)
+		assert.True(t, first >= 0 && first < second && second < third && third < counter)
+	})
+
+	t.Run("Last updated and sparklines", func(t *testing.T) {
+		_, body := do("/")
+		assert.Contains(t, body, "<th>Last hour</th>")
+		assert.Equal(t, 4, strings.Count(body, "<time datetime="))
+		assert.Equal(t, 1, strings.Count(body, `<svg class="sparkline"`))
+	})
+
+	t.Run("Refresh", func(t *testing.T) {
+		_, body := do("/")
+		assert.Contains(t, body, `id="auto-refresh" type="checkbox" checked`)
+
+		_, body = do("/?refresh=0")
+		assert.NotContains(t, body, `id="auto-refresh" type="checkbox" checked`)
+
+		status, _ := do("/?refresh=soon")
+		assert.Equal(t, http.StatusBadRequest, status)
+
+		status, _ = do("/?refresh=3600")
+		assert.Equal(t, http.StatusOK, status)
+
+		status, _ = do("/?refresh=3000000")
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/c2pc/go-musthave-metrics/internal/auth"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+	"github.com/c2pc/go-musthave-metrics/internal/retry"
+)
+
+func (h *Handler) handleDelete(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var metricType string
+	if metricType = c.Param("type"); metricType == "" {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	var metricName string
+	if metricName = c.Param("name"); metricName == "" {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	var deleted int64
+	switch metricType {
+	case h.gaugeStorage.GetName():
+		var err error
+		if deleted, err = deleteMetrics(ctx, h.gaugeStorage.Delete, metricName); err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+
+	case h.counterStorage.GetName():
+		var err error
+		if deleted, err = deleteMetrics(ctx, h.counterStorage.Delete, metricName); err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+
+	default:
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	if deleted == 0 {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) handleDeletesJSON(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var metrics []model.Metrics
+	message, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	err = json.Unmarshal(message, &metrics)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to unmarshal request body"})
+		return
+	}
+
+	var gauges, counters []string
+	for _, metric := range metrics {
+		if metric.ID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The metric id is empty"})
+			return
+		}
+
+		if !auth.FromContext(ctx).Allows(metric.ID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": forbiddenMetric(metric.ID)})
+			return
+		}
+
+		switch metric.Type {
+		case h.gaugeStorage.GetName():
+			gauges = append(gauges, metric.ID)
+		case h.counterStorage.GetName():
+			counters = append(counters, metric.ID)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid metrics type"})
+			return
+		}
+	}
+
+	deletedGauges, err := deleteMetrics(ctx, h.gaugeStorage.Delete, gauges...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete metrics"})
+		return
+	}
+
+	deletedCounters, err := deleteMetrics(ctx, h.counterStorage.Delete, counters...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete metrics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": deletedGauges + deletedCounters})
+}
+
+func deleteMetrics(ctx context.Context, deleteFn func(ctx context.Context, keys ...string) (int64, error), keys ...string) (int64, error) {
+	var deleted int64
+	err := retry.Retry(
+		func() (err error) {
+			deleted, err = deleteFn(ctx, keys...)
+			return
+		},
+		func(err error) bool {
+			return errors.Is(err, driver.ErrBadConn)
+		},
+		[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+	)
+	return deleted, err
+}
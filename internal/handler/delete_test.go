@@ -0,0 +1,81 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/handler"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+func TestMetricHandler_HandleDelete(t *testing.T) {
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, gaugeStorage.Set(ctx,
+		storage.Value[float64]{Key: "Alloc", Value: 1},
+		storage.Value[float64]{Key: "HeapAlloc", Value: 2},
+	))
+	require.NoError(t, counterStorage.Set(ctx,
+		storage.Value[int64]{Key: "PollCount", Value: 3},
+		storage.Value[int64]{Key: "Requests", Value: 4},
+	))
+
+	handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil)
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"Gauge", http.MethodDelete, "/value/gauge/Alloc", "", http.StatusOK, ""},
+		{"Gauge again", http.MethodDelete, "/value/gauge/Alloc", "", http.StatusNotFound, ""},
+		{"Counter", http.MethodDelete, "/value/counter/PollCount", "", http.StatusOK, ""},
+		{"Invalid type", http.MethodDelete, "/value/invalid/Alloc", "", http.StatusBadRequest, ""},
+		{
+			name:           "Bulk",
+			method:         http.MethodDelete,
+			url:            "/api/v1/metrics",
+			body:           `[{"id":"HeapAlloc","type":"gauge"},{"id":"Requests","type":"counter"},{"id":"Unknown","type":"counter"}]`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"deleted":2}`,
+		},
+		{"Bulk invalid type", http.MethodDelete, "/api/v1/metrics", `[{"id":"Alloc","type":"invalid"}]`, http.StatusBadRequest, ""},
+		{"Bulk empty id", http.MethodDelete, "/api/v1/metrics", `[{"type":"gauge"}]`, http.StatusBadRequest, ""},
+		{"Bulk invalid body", http.MethodDelete, "/api/v1/metrics", `{`, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler2.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tt.expectedStatus, result.StatusCode)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+
+	gauges, err := gaugeStorage.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, gauges)
+	counters, err := counterStorage.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, counters)
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/c2pc/go-musthave-metrics/internal/auth"
+	"github.com/c2pc/go-musthave-metrics/internal/broadcast"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+)
+
+const (
+	// eventBuffer is the number of events kept for a client, the stream is
+	// closed when the client falls further behind
+	eventBuffer    = 256
+	eventKeepAlive = 15 * time.Second
+)
+
+// publishUpdates sends the accepted updates to the event streams, times[i]
+// is the time of metrics[i].
+func (h *Handler) publishUpdates(times []time.Time, totals map[string]int64, metrics ...model.Metrics) {
+	if h.events.Len() == 0 {
+		return
+	}
+
+	events := make([]model.MetricEvent, 0, len(metrics))
+	for i, metric := range metrics {
+		event := model.MetricEvent{ID: metric.ID, Type: metric.Type, Value: metric.Value, Delta: metric.Delta, Time: times[i]}
+		if total, ok := totals[metric.ID]; ok && metric.Delta != nil {
+			event.Total = &total
+		}
+		events = append(events, event)
+	}
+
+	h.events.Publish(events...)
+}
+
+// Close ends the event streams, the server can't shut down while they are
+// open.
+func (h *Handler) Close() {
+	h.events.Close()
+}
+
+// handleEvents streams the updates as Server-Sent Events. The updates can be
+// filtered by the type and name query parameters, both can be repeated and
+// the names are patterns as in path.Match.
+func (h *Handler) handleEvents(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	types := c.QueryArray("type")
+	for _, metricType := range types {
+		if metricType != h.gaugeStorage.GetName() && metricType != h.counterStorage.GetName() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid metrics type"})
+			return
+		}
+	}
+
+	names := c.QueryArray("name")
+	for _, pattern := range names {
+		if _, err := path.Match(pattern, ""); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid name pattern"})
+			return
+		}
+	}
+
+	token := auth.FromContext(ctx)
+	subscription := h.events.Subscribe(eventBuffer, func(event model.MetricEvent) bool {
+		return token.Allows(event.ID) && matchEvent(types, names, event)
+	})
+	defer h.events.Unsubscribe(subscription)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(c.Writer, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-subscription.Events():
+			if !ok {
+				if subscription.Err() == broadcast.ErrSlowSubscriber {
+					_, _ = fmt.Fprint(c.Writer, "event: error\ndata: {\"error\":\"The client is too slow\"}\n\n")
+					c.Writer.Flush()
+				}
+				return
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(c.Writer, "event: update\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+func matchEvent(types []string, names []string, event model.MetricEvent) bool {
+	if len(types) > 0 && !contains(types, func(metricType string) bool { return metricType == event.Type }) {
+		return false
+	}
+	if len(names) > 0 && !contains(names, func(pattern string) bool {
+		matched, _ := path.Match(pattern, event.ID)
+		return matched
+	}) {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, match func(string) bool) bool {
+	for _, value := range values {
+		if match(value) {
+			return true
+		}
+	}
+	return false
+}
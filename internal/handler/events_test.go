@@ -0,0 +1,99 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/handler"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+func TestMetricHandler_HandleEvents(t *testing.T) {
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+
+	handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil)
+	server := httptest.NewServer(handler2)
+	defer server.Close()
+
+	t.Run("Invalid filter", func(t *testing.T) {
+		for _, query := range []string{"type=histogram", "name=[a"} {
+			resp, err := http.Get(server.URL + "/api/v1/stream?" + query)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, query)
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/stream?type=counter&name=requests.*&name=errors", nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	for _, update := range []string{
+		"/update/counter/requests.get/2",
+		"/update/gauge/requests.load/1",
+		"/update/counter/other/1",
+		"/update/counter/requests.get/3",
+	} {
+		resp, err := http.Post(server.URL+update, "text/plain", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	resp2, err := http.Post(server.URL+"/updates/", "application/json", strings.NewReader(`[{"id":"errors","type":"counter","delta":1}]`))
+	require.NoError(t, err)
+	resp2.Body.Close()
+
+	var events []model.MetricEvent
+	scanner := bufio.NewScanner(resp.Body)
+	for len(events) < 3 && scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var event model.MetricEvent
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+		events = append(events, event)
+	}
+	require.Len(t, events, 3)
+
+	var got []string
+	for _, event := range events {
+		assert.Nil(t, event.Value)
+		got = append(got, event.Type+" "+event.ID+" "+jsonString(t, event.Delta)+" "+jsonString(t, event.Total))
+	}
+	assert.Equal(t, []string{"counter requests.get 2 2", "counter requests.get 3 5", "counter errors 1 1"}, got)
+
+	t.Run("Close", func(t *testing.T) {
+		handler2.Close()
+		for scanner.Scan() {
+		}
+		assert.NoError(t, scanner.Err())
+	})
+}
+
+func jsonString(t *testing.T, v any) string {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(data)
+}
@@ -0,0 +1,338 @@
+package handler
+
+import (
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/c2pc/go-musthave-metrics/internal/auth"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+	"github.com/c2pc/go-musthave-metrics/internal/retry"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+const (
+	exportFormatJSON = "json"
+	exportFormatCSV  = "csv"
+
+	importModeOverwrite = "overwrite"
+	importModeAdd       = "add"
+)
+
+var exportCSVHeader = []string{"type", "id", "value"}
+
+// handleExport writes every metric as a JSON array of model.Metrics, the
+// body of /updates/, or as CSV rows of type, id and value. Counters hold
+// their total.
+func (h *Handler) handleExport(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	format := c.DefaultQuery("format", exportFormatJSON)
+	if format != exportFormatJSON && format != exportFormatCSV {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format"})
+		return
+	}
+
+	var gauges map[string]float64
+	if err := retry.Retry(
+		func() (err error) {
+			gauges, err = h.gaugeStorage.GetAll(ctx)
+			return
+		},
+		func(err error) bool {
+			return errors.Is(err, driver.ErrBadConn)
+		},
+		[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get metrics"})
+		return
+	}
+
+	var counters map[string]int64
+	if err := retry.Retry(
+		func() (err error) {
+			counters, err = h.counterStorage.GetAll(ctx)
+			return
+		},
+		func(err error) bool {
+			return errors.Is(err, driver.ErrBadConn)
+		},
+		[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get metrics"})
+		return
+	}
+
+	gauges, counters = allowedKeys(ctx, gauges), allowedKeys(ctx, counters)
+
+	metrics := make([]model.Metrics, 0, len(gauges)+len(counters))
+	for _, id := range sortedKeys(gauges) {
+		value := gauges[id]
+		metrics = append(metrics, model.Metrics{ID: id, Type: h.gaugeStorage.GetName(), Value: &value})
+	}
+	for _, id := range sortedKeys(counters) {
+		delta := counters[id]
+		metrics = append(metrics, model.Metrics{ID: id, Type: h.counterStorage.GetName(), Delta: &delta})
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=metrics.%s", format))
+
+	if format == exportFormatCSV {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+
+		w := csv.NewWriter(c.Writer)
+		_ = w.Write(exportCSVHeader)
+		for _, metric := range metrics {
+			_ = w.Write([]string{metric.Type, metric.ID, metricValue(metric)})
+		}
+		w.Flush()
+		return
+	}
+
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	// the metrics are written one by one instead of building the whole body
+	_, _ = io.WriteString(c.Writer, "[")
+	for i, metric := range metrics {
+		data, err := json.Marshal(metric)
+		if err != nil {
+			return
+		}
+		if i > 0 {
+			_, _ = io.WriteString(c.Writer, ",")
+		}
+		if _, err := c.Writer.Write(data); err != nil {
+			return
+		}
+	}
+	_, _ = io.WriteString(c.Writer, "]")
+}
+
+// handleImport loads a dump of handleExport, the format is taken from the
+// format query parameter or from the content type. The mode query
+// parameter tells whether the counters are overwritten, the default, or
+// added to. The imported values are recorded as updates, an overwritten
+// counter as the change of its total.
+func (h *Handler) handleImport(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	mode := c.DefaultQuery("mode", importModeOverwrite)
+	if mode != importModeOverwrite && mode != importModeAdd {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mode"})
+		return
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		format = exportFormatJSON
+		if strings.HasPrefix(c.ContentType(), "text/csv") {
+			format = exportFormatCSV
+		}
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	var metrics []model.Metrics
+	switch format {
+	case exportFormatJSON:
+		err = json.Unmarshal(body, &metrics)
+	case exportFormatCSV:
+		metrics, err = h.parseImportCSV(string(body))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse request body: " + err.Error()})
+		return
+	}
+
+	var gauges []storage.Valuer[float64]
+	var counters []storage.Valuer[int64]
+	for i, metric := range metrics {
+		if metric.ID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Metric %d: the id is empty", i+1)})
+			return
+		}
+		if !auth.FromContext(ctx).Allows(metric.ID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": forbiddenMetric(metric.ID)})
+			return
+		}
+
+		switch {
+		case metric.Type == h.gaugeStorage.GetName() && metric.Value != nil:
+			gauges = append(gauges, storage.Value[float64]{Key: metric.ID, Value: *metric.Value})
+		case metric.Type == h.counterStorage.GetName() && metric.Delta != nil:
+			counters = append(counters, storage.Value[int64]{Key: metric.ID, Value: *metric.Delta})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Metric %d: invalid type or value", i+1)})
+			return
+		}
+	}
+
+	setCounters := h.counterStorage.Replace
+	if mode == importModeAdd {
+		setCounters = h.counterStorage.Set
+	}
+
+	var previous map[string]int64
+	if mode == importModeOverwrite && len(counters) > 0 {
+		if err := retry.Retry(
+			func() (err error) {
+				previous, err = h.counterStorage.GetAll(ctx)
+				return
+			},
+			func(err error) bool {
+				return errors.Is(err, driver.ErrBadConn)
+			},
+			[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+		); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import metrics"})
+			return
+		}
+	}
+
+	if err := retry.Retry(
+		func() error {
+			return h.gaugeStorage.Set(ctx, gauges...)
+		},
+		func(err error) bool {
+			return errors.Is(err, driver.ErrBadConn)
+		},
+		[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import metrics"})
+		return
+	}
+
+	// the gauges and the counters are separate storages, the gauges stay
+	// imported when the counters fail and the response tells so
+	h.recordUpdates(ctx, importedGauges(h.gaugeStorage.GetName(), gauges)...)
+
+	if err := retry.Retry(
+		func() error {
+			return setCounters(ctx, counters...)
+		},
+		func(err error) bool {
+			return errors.Is(err, driver.ErrBadConn)
+		},
+		[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":    "Failed to import counters, the gauges were imported",
+			"imported": len(gauges),
+		})
+		return
+	}
+
+	h.recordUpdates(ctx, importedCounters(h.counterStorage.GetName(), counters, previous)...)
+
+	c.JSON(http.StatusOK, gin.H{"imported": len(gauges) + len(counters)})
+}
+
+func importedGauges(metricType string, gauges []storage.Valuer[float64]) []model.Metrics {
+	metrics := make([]model.Metrics, 0, len(gauges))
+	for _, gauge := range gauges {
+		value := gauge.GetValue()
+		metrics = append(metrics, model.Metrics{ID: gauge.GetKey(), Type: metricType, Value: &value})
+	}
+	return metrics
+}
+
+// importedCounters turns the imported counters into updates. With the
+// previous totals of an overwrite the delta is the change of the total and
+// the unchanged counters are left out.
+func importedCounters(metricType string, counters []storage.Valuer[int64], previous map[string]int64) []model.Metrics {
+	metrics := make([]model.Metrics, 0, len(counters))
+	for _, counter := range counters {
+		delta := counter.GetValue()
+		if previous != nil {
+			delta -= previous[counter.GetKey()]
+			if delta == 0 {
+				continue
+			}
+		}
+		metrics = append(metrics, model.Metrics{ID: counter.GetKey(), Type: metricType, Delta: &delta})
+	}
+	return metrics
+}
+
+// parseImportCSV reads the rows written by handleExport, the header is
+// required.
+func (h *Handler) parseImportCSV(body string) ([]model.Metrics, error) {
+	r := csv.NewReader(strings.NewReader(body))
+	r.FieldsPerRecord = len(exportCSVHeader)
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	if strings.Join(header, ",") != strings.Join(exportCSVHeader, ",") {
+		return nil, errors.New("the header must be " + strings.Join(exportCSVHeader, ","))
+	}
+
+	var metrics []model.Metrics
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := r.FieldPos(0)
+		metric := model.Metrics{Type: record[0], ID: record[1]}
+		switch metric.Type {
+		case h.gaugeStorage.GetName():
+			value, err := strconv.ParseFloat(record[2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid value", line)
+			}
+			metric.Value = &value
+		case h.counterStorage.GetName():
+			delta, err := strconv.ParseInt(record[2], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid value", line)
+			}
+			metric.Delta = &delta
+		default:
+			return nil, fmt.Errorf("line %d: invalid type", line)
+		}
+		metrics = append(metrics, metric)
+	}
+
+	return metrics, nil
+}
+
+func metricValue(metric model.Metrics) string {
+	if metric.Value != nil {
+		return strconv.FormatFloat(*metric.Value, 'g', -1, 64)
+	}
+	return strconv.FormatInt(*metric.Delta, 10)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
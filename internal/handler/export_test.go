@@ -0,0 +1,255 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/database"
+	"github.com/c2pc/go-musthave-metrics/internal/handler"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+func TestMetricHandler_HandleExport(t *testing.T) {
+	ctx := context.Background()
+
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, gaugeStorage.Set(ctx,
+		storage.Value[float64]{Key: "load", Value: 0.1},
+		storage.Value[float64]{Key: "alloc", Value: 1e21},
+	))
+	require.NoError(t, counterStorage.Set(ctx, storage.Value[int64]{Key: "requests", Value: 5}))
+
+	handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil)
+
+	tests := []struct {
+		name            string
+		url             string
+		expectedStatus  int
+		expectedBody    string
+		wantContentType string
+	}{
+		{
+			name:            "JSON",
+			url:             "/api/v1/export",
+			expectedStatus:  http.StatusOK,
+			expectedBody:    `[{"id":"alloc","type":"gauge","value":1e+21},{"id":"load","type":"gauge","value":0.1},{"id":"requests","type":"counter","delta":5}]`,
+			wantContentType: "application/json; charset=utf-8",
+		},
+		{
+			name:            "CSV",
+			url:             "/api/v1/export?format=csv",
+			expectedStatus:  http.StatusOK,
+			expectedBody:    "type,id,value\ngauge,alloc,1e+21\ngauge,load,0.1\ncounter,requests,5\n",
+			wantContentType: "text/csv; charset=utf-8",
+		},
+		{
+			name:           "Invalid format",
+			url:            "/api/v1/export?format=xml",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			handler2.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.wantContentType, w.Header().Get("Content-Type"))
+			if tt.wantContentType == "text/csv; charset=utf-8" {
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+			} else {
+				assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestMetricHandler_HandleImport(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		contentType    string
+		body           string
+		expectedStatus int
+		wantGauges     map[string]float64
+		wantCounters   map[string]int64
+	}{
+		{
+			name:           "JSON overwrite",
+			url:            "/api/v1/import",
+			contentType:    "application/json",
+			body:           `[{"id":"load","type":"gauge","value":0.5},{"id":"requests","type":"counter","delta":5}]`,
+			expectedStatus: http.StatusOK,
+			wantGauges:     map[string]float64{"load": 0.5, "existing": 1},
+			wantCounters:   map[string]int64{"requests": 5},
+		},
+		{
+			name:           "CSV add",
+			url:            "/api/v1/import?mode=add",
+			contentType:    "text/csv",
+			body:           "type,id,value\ngauge,load,0.5\ncounter,requests,5\n",
+			expectedStatus: http.StatusOK,
+			wantGauges:     map[string]float64{"load": 0.5, "existing": 1},
+			wantCounters:   map[string]int64{"requests": 15},
+		},
+		{
+			name:           "CSV format parameter",
+			url:            "/api/v1/import?format=csv",
+			body:           "type,id,value\ncounter,requests,5\n",
+			expectedStatus: http.StatusOK,
+			wantGauges:     map[string]float64{"existing": 1},
+			wantCounters:   map[string]int64{"requests": 5},
+		},
+		{
+			name:           "Invalid mode",
+			url:            "/api/v1/import?mode=merge",
+			body:           `[]`,
+			expectedStatus: http.StatusBadRequest,
+			wantGauges:     map[string]float64{"existing": 1},
+			wantCounters:   map[string]int64{"requests": 10},
+		},
+		{
+			name:           "Invalid CSV header",
+			url:            "/api/v1/import?format=csv",
+			body:           "id,type,value\nrequests,counter,5\n",
+			expectedStatus: http.StatusBadRequest,
+			wantGauges:     map[string]float64{"existing": 1},
+			wantCounters:   map[string]int64{"requests": 10},
+		},
+		{
+			name:           "Invalid CSV value",
+			url:            "/api/v1/import?format=csv",
+			body:           "type,id,value\ngauge,load,0.5\ncounter,requests,1.5\n",
+			expectedStatus: http.StatusBadRequest,
+			wantGauges:     map[string]float64{"existing": 1},
+			wantCounters:   map[string]int64{"requests": 10},
+		},
+		{
+			name:           "Missing value",
+			url:            "/api/v1/import",
+			body:           `[{"id":"load","type":"gauge","value":0.5},{"id":"requests","type":"counter","value":5}]`,
+			expectedStatus: http.StatusBadRequest,
+			wantGauges:     map[string]float64{"existing": 1},
+			wantCounters:   map[string]int64{"requests": 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+			require.NoError(t, err)
+			counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+			require.NoError(t, err)
+
+			require.NoError(t, gaugeStorage.Set(ctx, storage.Value[float64]{Key: "existing", Value: 1}))
+			require.NoError(t, counterStorage.Set(ctx, storage.Value[int64]{Key: "requests", Value: 10}))
+
+			handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil)
+
+			req := httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			handler2.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			gauges, err := gaugeStorage.GetAll(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantGauges, gauges)
+
+			counters, err := counterStorage.GetAll(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCounters, counters)
+		})
+	}
+}
+
+func TestMetricHandler_HandleImport_History(t *testing.T) {
+	ctx := context.Background()
+
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	historyStorage, err := storage.NewHistoryStorage(storage.TypeMemory, nil, 100, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, counterStorage.Set(ctx,
+		storage.Value[int64]{Key: "requests", Value: 10},
+		storage.Value[int64]{Key: "errors", Value: 2},
+	))
+
+	handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil, handler.WithHistory(historyStorage))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/import", strings.NewReader(
+		`[{"id":"load","type":"gauge","value":0.5},{"id":"requests","type":"counter","delta":25},{"id":"errors","type":"counter","delta":2}]`))
+	w := httptest.NewRecorder()
+	handler2.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	now := time.Now()
+
+	buckets, err := historyStorage.Query(ctx, "gauge", "load", now.Add(-time.Minute), now.Add(time.Minute), 0)
+	require.NoError(t, err)
+	require.Len(t, buckets, 1)
+	assert.Equal(t, 0.5, buckets[0].Last)
+
+	// the overwritten counter is recorded as the change of its total
+	buckets, err = historyStorage.Query(ctx, "counter", "requests", now.Add(-time.Minute), now.Add(time.Minute), 0)
+	require.NoError(t, err)
+	require.Len(t, buckets, 1)
+	assert.Equal(t, 15.0, buckets[0].Sum)
+
+	buckets, err = historyStorage.Query(ctx, "counter", "errors", now.Add(-time.Minute), now.Add(time.Minute), 0)
+	require.NoError(t, err)
+	assert.Empty(t, buckets)
+}
+
+func TestMetricHandler_HandleImport_Partial(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	db := &database.DB{DB: mockDB}
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeDB, db)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeDB, db)
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO gauges (.+) VALUES (.+) ON CONFLICT (.+) DO UPDATE SET (.+)$").
+		WithArgs("load", 0.5).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin().WillReturnError(errors.New("some error"))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/import?mode=add", strings.NewReader(
+		`[{"id":"load","type":"gauge","value":0.5},{"id":"requests","type":"counter","delta":5}]`))
+	w := httptest.NewRecorder()
+	handler.NewHandler(gaugeStorage, counterStorage, db).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"error":"Failed to import counters, the gauges were imported","imported":1}`, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
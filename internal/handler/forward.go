@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/c2pc/go-musthave-metrics/internal/logger"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+// Forwarder passes the accepted updates to external systems, it must not
+// block.
+type Forwarder interface {
+	Forward(points ...storage.Point)
+}
+
+func WithForwarder(forwarder Forwarder) Option {
+	return func(h *Handler) {
+		h.forwarder = forwarder
+	}
+}
+
+// forwardUpdates forwards the counters with their total value, the external
+// systems expect cumulative counters. times[i] is the time of metrics[i].
+func (h *Handler) forwardUpdates(times []time.Time, totals map[string]int64, metrics ...model.Metrics) {
+	if h.forwarder == nil {
+		return
+	}
+
+	points := make([]storage.Point, 0, len(metrics))
+	for i, metric := range metrics {
+		point := storage.Point{Type: metric.Type, ID: metric.ID, Time: times[i]}
+		switch {
+		case metric.Value != nil:
+			point.Value = *metric.Value
+		case metric.Delta != nil:
+			total, ok := totals[metric.ID]
+			if !ok {
+				continue
+			}
+			point.Value = float64(total)
+		default:
+			continue
+		}
+		points = append(points, point)
+	}
+
+	h.forwarder.Forward(points...)
+}
+
+// counterTotals reads the value of the updated counters, the counters that
+// can't be read are left out.
+func (h *Handler) counterTotals(ctx context.Context, metrics ...model.Metrics) map[string]int64 {
+	totals := make(map[string]int64)
+	for _, metric := range metrics {
+		if metric.Delta == nil {
+			continue
+		}
+		if _, ok := totals[metric.ID]; ok {
+			continue
+		}
+
+		total, err := h.counterStorage.Get(ctx, metric.ID)
+		if err != nil {
+			logger.Log.Info("Failed to get counter total", logger.Any("id", metric.ID), logger.Error(err))
+			continue
+		}
+		totals[metric.ID] = total
+	}
+	return totals
+}
@@ -0,0 +1,68 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/handler"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+type forwarderMock struct {
+	points []storage.Point
+}
+
+func (f *forwarderMock) Forward(points ...storage.Point) {
+	f.points = append(f.points, points...)
+}
+
+func TestMetricHandler_Forward(t *testing.T) {
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+
+	forwarder := &forwarderMock{}
+	handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil, handler.WithForwarder(forwarder))
+
+	requests := []struct {
+		url  string
+		body string
+	}{
+		{url: "/update/counter/PollCount/2"},
+		{url: "/update/gauge/Alloc/1.5"},
+		{url: "/updates/", body: `[{"id":"PollCount","type":"counter","delta":3},{"id":"PollCount","type":"counter","delta":4},{"id":"Alloc","type":"gauge","value":2}]`},
+		{url: "/update/", body: `{"id":"Alloc","type":"gauge","value":"invalid"}`},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest(http.MethodPost, r.url, strings.NewReader(r.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler2.ServeHTTP(w, req)
+	}
+
+	type forwarded struct {
+		Type  string
+		ID    string
+		Value float64
+	}
+	var got []forwarded
+	for _, point := range forwarder.points {
+		assert.False(t, point.Time.IsZero())
+		got = append(got, forwarded{Type: point.Type, ID: point.ID, Value: point.Value})
+	}
+
+	// counters are forwarded with their total value
+	assert.Equal(t, []forwarded{
+		{"counter", "PollCount", 2},
+		{"gauge", "Alloc", 1.5},
+		{"counter", "PollCount", 9},
+		{"counter", "PollCount", 9},
+		{"gauge", "Alloc", 2},
+	}, got)
+}
@@ -8,14 +8,19 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/c2pc/go-musthave-metrics/internal/retry"
 	"github.com/c2pc/go-musthave-metrics/internal/storage"
 	"github.com/gin-gonic/gin"
 
+	"github.com/c2pc/go-musthave-metrics/internal/auth"
+	"github.com/c2pc/go-musthave-metrics/internal/broadcast"
+	"github.com/c2pc/go-musthave-metrics/internal/codec"
 	"github.com/c2pc/go-musthave-metrics/internal/handler/middleware"
 	"github.com/c2pc/go-musthave-metrics/internal/model"
+	"github.com/c2pc/go-musthave-metrics/internal/otlp"
 )
 
 type Storager[T int64 | float64] interface {
@@ -25,21 +30,74 @@ type Storager[T int64 | float64] interface {
 	GetAll(ctx context.Context) (map[string]T, error)
 	GetAllString(ctx context.Context) (map[string]string, error)
 	Set(ctx context.Context, values ...storage.Valuer[T]) error
+	Replace(ctx context.Context, values ...storage.Valuer[T]) error
 	SetString(ctx context.Context, values ...storage.Valuer[string]) error
+	Delete(ctx context.Context, keys ...string) (int64, error)
+	Aggregate(ctx context.Context, selector storage.Selector, aggregation storage.Aggregation, k int) (storage.AggregateResult, error)
+	GetAllUpdated(ctx context.Context) (map[string]time.Time, error)
 }
 
 type Pinger interface {
 	Ping() error
 }
 
+type AgentSettingsProvider interface {
+	Get(agentID string) model.AgentSettings
+}
+
+// BatchRegistry applies the batches of the agents and deduplicates the
+// batches retried by them.
+type BatchRegistry interface {
+	Apply(ctx context.Context, agentID string, sequence int64, gauges []storage.Valuer[float64], counters []storage.Valuer[int64]) (bool, error)
+}
+
 type Handler struct {
 	http.Handler
 	gaugeStorage   Storager[float64]
 	counterStorage Storager[int64]
 	db             Pinger
+	agentSettings  AgentSettingsProvider
+	rateLimiter    *middleware.RateLimiter
+	batches        BatchRegistry
+	history        HistoryStorage
+	meta           MetaStorage
+	forwarder      Forwarder
+	otlp           *otlp.Converter
+	events         *broadcast.Broadcaster[model.MetricEvent]
+	auth           *middleware.Auth
+	// clientCertAgentID takes the agent ids from the client certificates
+	clientCertAgentID bool
+}
+
+type Option func(*Handler)
+
+func WithAgentSettings(provider AgentSettingsProvider) Option {
+	return func(h *Handler) {
+		h.agentSettings = provider
+	}
+}
+
+func WithRateLimiter(limiter *middleware.RateLimiter) Option {
+	return func(h *Handler) {
+		h.rateLimiter = limiter
+	}
+}
+
+func WithBatchRegistry(registry BatchRegistry) Option {
+	return func(h *Handler) {
+		h.batches = registry
+	}
+}
+
+// WithClientCertAgentID identifies the agents by the common name of their
+// client certificate.
+func WithClientCertAgentID() Option {
+	return func(h *Handler) {
+		h.clientCertAgentID = true
+	}
 }
 
-func NewHandler(gaugeStorage Storager[float64], counterStorage Storager[int64], db Pinger) http.Handler {
+func NewHandler(gaugeStorage Storager[float64], counterStorage Storager[int64], db Pinger, opts ...Option) *Handler {
 	gin.SetMode(gin.ReleaseMode)
 	handlers := gin.New()
 
@@ -52,6 +110,12 @@ func NewHandler(gaugeStorage Storager[float64], counterStorage Storager[int64],
 		gaugeStorage:   gaugeStorage,
 		counterStorage: counterStorage,
 		db:             db,
+		otlp:           otlp.NewConverter(),
+		events:         broadcast.New[model.MetricEvent](),
+	}
+
+	for _, opt := range opts {
+		opt(h)
 	}
 
 	h.Init(handlers)
@@ -60,15 +124,49 @@ func NewHandler(gaugeStorage Storager[float64], counterStorage Storager[int64],
 }
 
 func (h *Handler) Init(engine *gin.Engine) {
+	if h.rateLimiter != nil {
+		engine.Use(h.rateLimiter.Handle)
+	}
+	if h.clientCertAgentID {
+		engine.Use(middleware.ClientCertAgentID)
+	}
+
+	read := h.auth.Require(auth.ScopeRead)
+	write := h.auth.Require(auth.ScopeWrite)
+	admin := h.auth.Require(auth.ScopeAdmin)
+
+	// the agent stream hijacks the connection and the event stream never
+	// ends, so they skip the body middlewares
+	engine.GET("/api/v1/agents/stream", write, h.handleStream)
+	engine.GET("/api/v1/stream", read, h.handleEvents)
+	// scrapes are not logged, the response holds every metric
+	engine.GET("/metrics", read, middleware.GzipCompressor, h.handleMetrics)
+	// dumps hold every metric, so they are not logged either
+	engine.GET("/api/v1/export", read, middleware.GzipCompressor, h.handleExport)
+	engine.POST("/api/v1/import", admin, middleware.GzipDecompressor, h.handleImport)
+
 	api := engine.Group("", middleware.GzipDecompressor, middleware.GzipCompressor, middleware.Logger)
 	{
-		api.GET("/", h.handleHTML)
+		api.GET("/", read, h.handleHTML)
 		api.GET("/ping", h.ping)
-		api.POST("/update/", h.handleUpdateJSON)
-		api.POST("/updates/", h.handleUpdatesJSON)
-		api.POST("/update/:type/:name/:value", h.handleUpdate)
-		api.GET("/value/:type/:name", h.handleValue)
-		api.POST("/value/", h.handleValueJSON)
+		api.POST("/update/", write, h.handleUpdateJSON)
+		api.POST("/updates/", write, h.handleUpdatesJSON)
+		api.POST("/write", write, h.handleWrite)
+		api.POST("/v1/metrics", write, h.handleOTLP)
+		api.POST("/update/:type/:name/:value", write, h.handleUpdate)
+		api.GET("/value/:type/:name", read, h.handleValue)
+		api.POST("/value/", read, h.handleValueJSON)
+		api.DELETE("/value/:type/:name", admin, h.handleDelete)
+		api.DELETE("/api/v1/metrics", admin, h.handleDeletesJSON)
+		api.GET("/api/v1/agents/:id/config", read, h.handleAgentSettings)
+		api.GET("/api/v1/history", read, h.handleHistory)
+		api.POST("/api/v1/query", read, h.handleQuery)
+		api.GET("/api/v1/meta", read, h.handleListMeta)
+		api.PUT("/api/v1/meta", write, h.handlePutMetas)
+		api.POST("/api/v1/meta/defaults", write, h.handlePostMetaDefaults)
+		api.GET("/api/v1/meta/:type/:name", read, h.handleGetMeta)
+		api.PUT("/api/v1/meta/:type/:name", write, h.handlePutMeta)
+		api.DELETE("/api/v1/meta/:type/:name", admin, h.handleDeleteMeta)
 	}
 }
 
@@ -121,6 +219,16 @@ func (h *Handler) handleUpdate(c *gin.Context) {
 		return
 	}
 
+	metric := model.Metrics{ID: metricName, Type: metricType}
+	if metricType == h.gaugeStorage.GetName() {
+		value, _ := strconv.ParseFloat(metricValue, 64)
+		metric.Value = &value
+	} else {
+		delta, _ := strconv.ParseInt(metricValue, 10, 64)
+		metric.Delta = &delta
+	}
+	h.recordUpdates(ctx, metric)
+
 	c.Status(http.StatusOK)
 }
 
@@ -151,6 +259,11 @@ func (h *Handler) handleUpdateJSON(c *gin.Context) {
 		return
 	}
 
+	if !auth.FromContext(ctx).Allows(metric.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": forbiddenMetric(metric.ID)})
+		return
+	}
+
 	var metricRequest *model.Metrics
 
 	switch metric.Type {
@@ -231,6 +344,8 @@ func (h *Handler) handleUpdateJSON(c *gin.Context) {
 		return
 	}
 
+	h.recordUpdates(ctx, metric)
+
 	c.JSON(http.StatusOK, metricRequest)
 }
 
@@ -244,44 +359,124 @@ func (h *Handler) handleUpdatesJSON(c *gin.Context) {
 		return
 	}
 
-	err = json.Unmarshal(message, &metrics)
+	// an empty body is a valid protobuf message, reject it for every encoding
+	if len(message) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The request body is empty"})
+		return
+	}
+
+	err = codec.ByContentType(c.GetHeader("Content-Type")).Unmarshal(message, &metrics)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to unmarshal request body"})
 		return
 	}
 
+	agentID, sequence, err := batchKey(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch sequence"})
+		return
+	}
+
+	if status, message := h.updateBatch(ctx, agentID, sequence, metrics); status != http.StatusOK {
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// Update validates and stores the metrics received by the other listeners of
+// the server, times[i] is the time of metrics[i] and a zero time is now.
+func (h *Handler) Update(ctx context.Context, metrics []model.Metrics, times []time.Time) error {
+	if status, message := h.updateTimedBatch(ctx, "", 0, metrics, times); status != http.StatusOK {
+		return errors.New(message)
+	}
+	return nil
+}
+
+// updateBatch validates and stores a batch of metrics. It returns the HTTP
+// status of the result and an error message for the client.
+func (h *Handler) updateBatch(ctx context.Context, agentID string, sequence int64, metrics []model.Metrics) (int, string) {
+	return h.updateTimedBatch(ctx, agentID, sequence, metrics, nil)
+}
+
+// updateTimedBatch is updateBatch for the metrics that carry their own
+// time, times[i] is the time of metrics[i].
+func (h *Handler) updateTimedBatch(ctx context.Context, agentID string, sequence int64, metrics []model.Metrics, times []time.Time) (int, string) {
 	var gauges []storage.Valuer[float64]
 	var counters []storage.Valuer[int64]
 	for _, metric := range metrics {
 		if metric.Type == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "The metric type is empty"})
-			return
+			return http.StatusBadRequest, "The metric type is empty"
 		}
 
 		if metric.ID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "The metric id is empty"})
-			return
+			return http.StatusBadRequest, "The metric id is empty"
+		}
+
+		if !auth.FromContext(ctx).Allows(metric.ID) {
+			return http.StatusForbidden, forbiddenMetric(metric.ID)
 		}
 
 		switch metric.Type {
 		case h.gaugeStorage.GetName():
 			if metric.Value == nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "The metric value is empty"})
-				return
+				return http.StatusBadRequest, "The metric value is empty"
 			}
 			gauges = append(gauges, storage.Value[float64]{Key: metric.ID, Value: *metric.Value})
 		case h.counterStorage.GetName():
 			if metric.Delta == nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "The metric delta is empty"})
-				return
+				return http.StatusBadRequest, "The metric delta is empty"
 			}
 			counters = append(counters, storage.Value[int64]{Key: metric.ID, Value: *metric.Delta})
 		default:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid metrics type"})
-			return
+			return http.StatusBadRequest, "Invalid metrics type"
+		}
+	}
+
+	if h.batches != nil && agentID != "" {
+		var applied bool
+		if err := retry.Retry(
+			func() (err error) {
+				applied, err = h.batches.Apply(ctx, agentID, sequence, gauges, counters)
+				return
+			},
+			func(err error) bool {
+				return errors.Is(err, driver.ErrBadConn)
+			},
+			[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+		); err != nil {
+			return http.StatusInternalServerError, "Failed to set metric value"
+		}
+		if !applied {
+			// the batch has already been applied, the agent missed the answer
+			return http.StatusOK, ""
 		}
+	} else if err := h.applyBatch(ctx, gauges, counters); err != nil {
+		return http.StatusInternalServerError, "Failed to set metric value"
 	}
 
+	h.recordTimedUpdates(ctx, times, metrics)
+
+	return http.StatusOK, ""
+}
+
+func batchKey(c *gin.Context) (string, int64, error) {
+	agentID := c.GetHeader(model.AgentIDHeader)
+	value := c.GetHeader(model.BatchSequenceHeader)
+	if agentID == "" || value == "" {
+		return "", 0, nil
+	}
+
+	sequence, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return agentID, sequence, nil
+}
+
+func (h *Handler) applyBatch(ctx context.Context, gauges []storage.Valuer[float64], counters []storage.Valuer[int64]) error {
 	if len(gauges) > 0 {
 		if err := retry.Retry(
 			func() error {
@@ -292,10 +487,8 @@ func (h *Handler) handleUpdatesJSON(c *gin.Context) {
 			},
 			[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
 		); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set metric value"})
-			return
+			return err
 		}
-
 	}
 
 	if len(counters) > 0 {
@@ -308,12 +501,11 @@ func (h *Handler) handleUpdatesJSON(c *gin.Context) {
 			},
 			[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
 		); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set metric value"})
-			return
+			return err
 		}
 	}
 
-	c.Status(http.StatusOK)
+	return nil
 }
 
 func (h *Handler) handleValue(c *gin.Context) {
@@ -411,6 +603,11 @@ func (h *Handler) handleValueJSON(c *gin.Context) {
 		return
 	}
 
+	if !auth.FromContext(ctx).Allows(metric.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": forbiddenMetric(metric.ID)})
+		return
+	}
+
 	switch metric.Type {
 	case h.gaugeStorage.GetName():
 		var value float64
@@ -464,82 +661,6 @@ func (h *Handler) handleValueJSON(c *gin.Context) {
 	c.JSON(http.StatusOK, metric)
 }
 
-func (h *Handler) handleHTML(c *gin.Context) {
-	ctx := c.Request.Context()
-
-	var gaugesStats, counterStats map[string]string
-	if err := retry.Retry(
-		func() (err error) {
-			gaugesStats, err = h.gaugeStorage.GetAllString(ctx)
-			return
-		},
-		func(err error) bool {
-			return errors.Is(err, driver.ErrBadConn)
-		},
-		[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
-	); err != nil {
-		c.Status(http.StatusInternalServerError)
-		return
-	}
-
-	if err := retry.Retry(
-		func() (err error) {
-			counterStats, err = h.counterStorage.GetAllString(ctx)
-			return
-		},
-		func(err error) bool {
-			return errors.Is(err, driver.ErrBadConn)
-		},
-		[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
-	); err != nil {
-		c.Status(http.StatusInternalServerError)
-		return
-	}
-
-	tmpl := `<html>
-		<head>
-		<title></title>
-		</head>
-		<body>
-			<table border="1" cellpadding="1" cellspacing="1" style="width: 600px">
-				<thead>
-					<tr>
-						<th scope="col" style="width: 300px">Gauge View</th>
-						<th scope="col" style="width: 300px">Value</th>
-					</tr>
-				</thead>
-				<tbody>
-					%s
-				</tbody>
-			</table>
-			<table border="1" cellpadding="1" cellspacing="1" style="width: 600px; margin-top: 30px;">
-				<thead>
-					<tr>
-						<th scope="col" style="width: 300px">Counter View</th>
-						<th scope="col" style="width: 300px">Value</th>
-					</tr>
-				</thead>
-				<tbody>
-					%s
-				</tbody>
-			</table>
-		</body>
-	</html>`
-
-	gaugesView := h.mapToHTML(gaugesStats)
-	counterView := h.mapToHTML(counterStats)
-
-	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(fmt.Sprintf(tmpl, gaugesView, counterView)))
-}
-
-func (h *Handler) mapToHTML(m map[string]string) string {
-	output := ""
-	for k, v := range m {
-		output += fmt.Sprintf("<tr><th>%v</th><th>%v</th></tr>", k, v)
-	}
-	return output
-}
-
 func (h *Handler) ping(c *gin.Context) {
 	if h.db == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
@@ -18,7 +18,9 @@ import (
 	"github.com/c2pc/go-musthave-metrics/internal/database"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
 
+	"github.com/c2pc/go-musthave-metrics/internal/codec"
 	"github.com/c2pc/go-musthave-metrics/internal/handler"
 	"github.com/c2pc/go-musthave-metrics/internal/model"
 	"github.com/c2pc/go-musthave-metrics/internal/storage"
@@ -252,27 +254,68 @@ func TestMetricHandler_HandleUpdatesJSON(t *testing.T) {
 		{"Success Gauge", http.MethodPost, []data{{ID: "id2", Type: "gauge", Value: defaultValue2}}, http.StatusOK},
 		{"Success Counter", http.MethodPost, []data{{ID: "id3", Type: "counter", Delta: defaultDelta2}}, http.StatusOK},
 	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			var body io.Reader
-			if tt.data != nil {
-				out, err := json.Marshal(tt.data)
-				if err != nil {
-					log.Fatal(err)
+	for _, c := range []codec.Codec{codec.JSON{}, codec.Protobuf{}, codec.Msgpack{}} {
+		for _, tt := range tests {
+			t.Run(c.Name()+"/"+tt.name, func(t *testing.T) {
+				var body io.Reader
+				if tt.data != nil {
+					out, err := encodeData(c, tt.data)
+					if err != nil {
+						log.Fatal(err)
+					}
+					body = bytes.NewReader(out)
 				}
-				body = bytes.NewReader(out)
-			}
 
-			request := httptest.NewRequest(tt.method, "/updates/", body)
-			request.Header.Set("Content-Type", "application/json")
-			w := httptest.NewRecorder()
+				request := httptest.NewRequest(tt.method, "/updates/", body)
+				request.Header.Set("Content-Type", c.ContentType())
+				w := httptest.NewRecorder()
 
-			handler2.ServeHTTP(w, request)
+				handler2.ServeHTTP(w, request)
 
-			result := w.Result()
-			assert.Equal(t, tt.expectedStatus, result.StatusCode)
-			result.Body.Close()
-		})
+				result := w.Result()
+				assert.Equal(t, tt.expectedStatus, result.StatusCode)
+				result.Body.Close()
+			})
+		}
+	}
+}
+
+// encodeData encodes loosely typed test data. Protobuf cannot carry values of
+// a wrong type, so such fields are left out and the metric stays incomplete.
+func encodeData[T any](c codec.Codec, data []T) ([]byte, error) {
+	switch c.(type) {
+	case codec.Msgpack:
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		enc.SetCustomStructTag("json")
+		err := enc.Encode(data)
+		return buf.Bytes(), err
+	case codec.Protobuf:
+		out, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+
+		var items []map[string]interface{}
+		if err := json.Unmarshal(out, &items); err != nil {
+			return nil, err
+		}
+
+		metrics := make([]model.Metrics, len(items))
+		for i, item := range items {
+			metrics[i].ID, _ = item["id"].(string)
+			metrics[i].Type, _ = item["type"].(string)
+			if delta, ok := item["delta"].(float64); ok {
+				d := int64(delta)
+				metrics[i].Delta = &d
+			}
+			if value, ok := item["value"].(float64); ok {
+				metrics[i].Value = &value
+			}
+		}
+		return c.Marshal(metrics)
+	default:
+		return json.Marshal(data)
 	}
 }
 
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/c2pc/go-musthave-metrics/internal/logger"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+	"github.com/c2pc/go-musthave-metrics/internal/retry"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+const defaultHistoryRange = time.Hour
+
+type HistoryStorage interface {
+	Add(ctx context.Context, points ...storage.Point) error
+	Query(ctx context.Context, metricType string, id string, from time.Time, to time.Time, step time.Duration) ([]storage.Bucket, error)
+	QueryMany(ctx context.Context, metricType string, ids []string, from time.Time, to time.Time, step time.Duration) (map[string][]storage.Bucket, error)
+}
+
+func WithHistory(history HistoryStorage) Option {
+	return func(h *Handler) {
+		h.history = history
+	}
+}
+
+// recordUpdates is called with every update accepted by the storages.
+func (h *Handler) recordUpdates(ctx context.Context, metrics ...model.Metrics) {
+	h.recordTimedUpdates(ctx, nil, metrics)
+}
+
+// recordTimedUpdates records the updates at the given times, times[i] is
+// the time of metrics[i]. The updates without a time are recorded now.
+func (h *Handler) recordTimedUpdates(ctx context.Context, times []time.Time, metrics []model.Metrics) {
+	if len(metrics) == 0 {
+		return
+	}
+
+	now := time.Now()
+	at := make([]time.Time, len(metrics))
+	for i := range metrics {
+		at[i] = now
+		if i < len(times) && !times[i].IsZero() {
+			at[i] = times[i]
+		}
+	}
+
+	var totals map[string]int64
+	if h.forwarder != nil || h.events.Len() > 0 {
+		totals = h.counterTotals(ctx, metrics...)
+	}
+	h.forwardUpdates(at, totals, metrics...)
+	h.publishUpdates(at, totals, metrics...)
+
+	if h.history == nil {
+		return
+	}
+
+	points := make([]storage.Point, 0, len(metrics))
+	for i, metric := range metrics {
+		point := storage.Point{Type: metric.Type, ID: metric.ID, Time: at[i]}
+		switch {
+		case metric.Value != nil:
+			point.Value = *metric.Value
+		case metric.Delta != nil:
+			point.Value = float64(*metric.Delta)
+		default:
+			continue
+		}
+		points = append(points, point)
+	}
+
+	// the update is already stored, a missing history point is not worth
+	// failing the request
+	if err := h.history.Add(ctx, points...); err != nil {
+		logger.Log.Info("Failed to record history", logger.Error(err))
+	}
+}
+
+func (h *Handler) handleHistory(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if h.history == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "History is not configured"})
+		return
+	}
+
+	metricType := c.Query("type")
+	if metricType != h.gaugeStorage.GetName() && metricType != h.counterStorage.GetName() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid metrics type"})
+		return
+	}
+
+	id := c.Query("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The metric id is empty"})
+		return
+	}
+
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		var err error
+		if to, err = parseTime(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to"})
+			return
+		}
+	}
+
+	from := to.Add(-defaultHistoryRange)
+	if value := c.Query("from"); value != "" {
+		var err error
+		if from, err = parseTime(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from"})
+			return
+		}
+	}
+
+	if from.After(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The from is after the to"})
+		return
+	}
+
+	var step time.Duration
+	if value := c.Query("step"); value != "" {
+		var err error
+		if step, err = parseDuration(value); err != nil || step <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid step"})
+			return
+		}
+	}
+
+	var buckets []storage.Bucket
+	if err := retry.Retry(
+		func() (err error) {
+			buckets, err = h.history.Query(ctx, metricType, id, from, to, step)
+			return
+		},
+		func(err error) bool {
+			return errors.Is(err, driver.ErrBadConn)
+		},
+		[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.History{
+		Type:   metricType,
+		ID:     id,
+		Points: downsample(buckets, from, step, metricType == h.counterStorage.GetName()),
+	})
+}
+
+// downsample merges the buckets, ordered by time as the history storage
+// returns them, into buckets of the given step starting at from. Counter
+// deltas are summed, gauge values are averaged.
+func downsample(buckets []storage.Bucket, from time.Time, step time.Duration, sum bool) []model.HistoryPoint {
+	var merged []storage.Bucket
+	for _, bucket := range buckets {
+		if step > 0 {
+			start := from
+			if bucket.Time.After(from) {
+				start = from.Add(bucket.Time.Sub(from) / step * step)
+			}
+			if len(merged) > 0 && merged[len(merged)-1].Time.Equal(start) {
+				merged[len(merged)-1].Merge(bucket)
+				continue
+			}
+			bucket.Time = start
+		}
+		merged = append(merged, bucket)
+	}
+
+	result := make([]model.HistoryPoint, 0, len(merged))
+	for _, bucket := range merged {
+		if sum {
+			result = append(result, model.HistoryPoint{Time: bucket.Time, Value: bucket.Sum})
+			continue
+		}
+
+		result = append(result, model.HistoryPoint{
+			Time:  bucket.Time,
+			Value: bucket.Sum / float64(bucket.Count),
+			Min:   &bucket.Min,
+			Max:   &bucket.Max,
+			Last:  &bucket.Last,
+		})
+	}
+
+	return result
+}
+
+// parseTime accepts RFC 3339 or Unix seconds.
+func parseTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseDuration accepts a Go duration or seconds.
+func parseDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
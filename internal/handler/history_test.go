@@ -0,0 +1,126 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/handler"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+func TestMetricHandler_HandleHistory(t *testing.T) {
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	historyStorage, err := storage.NewHistoryStorage(storage.TypeMemory, nil, 100, nil)
+	require.NoError(t, err)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+
+	require.NoError(t, historyStorage.Add(context.Background(),
+		storage.Point{Type: "gauge", ID: "Alloc", Time: at(0), Value: 1},
+		storage.Point{Type: "gauge", ID: "Alloc", Time: at(30), Value: 3},
+		storage.Point{Type: "gauge", ID: "Alloc", Time: at(70), Value: 5},
+		storage.Point{Type: "counter", ID: "PollCount", Time: at(10), Value: 2},
+		storage.Point{Type: "counter", ID: "PollCount", Time: at(50), Value: 3},
+		storage.Point{Type: "counter", ID: "PollCount", Time: at(130), Value: 4},
+	))
+
+	gauge := func(t time.Time, value, min, max, last float64) model.HistoryPoint {
+		return model.HistoryPoint{Time: t, Value: value, Min: &min, Max: &max, Last: &last}
+	}
+
+	handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil, handler.WithHistory(historyStorage))
+
+	from, to := start.Format(time.RFC3339), at(180).Format(time.RFC3339)
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		want           []model.HistoryPoint
+	}{
+		{
+			name:           "Raw",
+			query:          "type=gauge&id=Alloc&from=" + from + "&to=" + to,
+			expectedStatus: http.StatusOK,
+			want:           []model.HistoryPoint{gauge(at(0), 1, 1, 1, 1), gauge(at(30), 3, 3, 3, 3), gauge(at(70), 5, 5, 5, 5)},
+		},
+		{
+			name:           "Gauge average",
+			query:          "type=gauge&id=Alloc&step=1m&from=" + from + "&to=" + to,
+			expectedStatus: http.StatusOK,
+			want:           []model.HistoryPoint{gauge(at(0), 2, 1, 3, 3), gauge(at(60), 5, 5, 5, 5)},
+		},
+		{
+			name:           "Counter sum",
+			query:          "type=counter&id=PollCount&step=60&from=" + from + "&to=" + to,
+			expectedStatus: http.StatusOK,
+			want:           []model.HistoryPoint{{Time: at(0), Value: 5}, {Time: at(120), Value: 4}},
+		},
+		{
+			name:           "Unix range",
+			query:          "type=counter&id=PollCount&from=" + "1704067220" + "&to=" + "1704067300",
+			expectedStatus: http.StatusOK,
+			want:           []model.HistoryPoint{{Time: at(50), Value: 3}},
+		},
+		{
+			name:           "Unknown metric",
+			query:          "type=gauge&id=Unknown&from=" + from + "&to=" + to,
+			expectedStatus: http.StatusOK,
+			want:           []model.HistoryPoint{},
+		},
+		{"Invalid type", "type=invalid&id=Alloc", http.StatusBadRequest, nil},
+		{"Empty id", "type=gauge", http.StatusBadRequest, nil},
+		{"Invalid from", "type=gauge&id=Alloc&from=yesterday", http.StatusBadRequest, nil},
+		{"Reversed range", "type=gauge&id=Alloc&from=" + to + "&to=" + from, http.StatusBadRequest, nil},
+		{"Invalid step", "type=gauge&id=Alloc&step=-1m", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/api/v1/history?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			handler2.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+			require.Equal(t, tt.expectedStatus, result.StatusCode)
+
+			if tt.expectedStatus == http.StatusOK {
+				var got model.History
+				require.NoError(t, json.NewDecoder(result.Body).Decode(&got))
+				for i := range got.Points {
+					got.Points[i].Time = got.Points[i].Time.UTC()
+				}
+				assert.Equal(t, tt.want, got.Points)
+			}
+		})
+	}
+
+	t.Run("Updates are recorded", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(`[{"id":"Recorded","type":"gauge","value":7}]`))
+		w := httptest.NewRecorder()
+		handler2.ServeHTTP(w, request)
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		w.Result().Body.Close()
+
+		buckets, err := historyStorage.Query(context.Background(), "gauge", "Recorded", time.Now().Add(-time.Minute), time.Now(), 0)
+		require.NoError(t, err)
+		require.Len(t, buckets, 1)
+		assert.Equal(t, 7.0, buckets[0].Last)
+	})
+}
@@ -0,0 +1,291 @@
+package handler
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/c2pc/go-musthave-metrics/internal/auth"
+	"github.com/c2pc/go-musthave-metrics/internal/logger"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+	"github.com/c2pc/go-musthave-metrics/internal/retry"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+type MetaStorage interface {
+	Get(ctx context.Context, metricType string, id string) (storage.Meta, error)
+	GetAll(ctx context.Context) ([]storage.Meta, error)
+	Set(ctx context.Context, metas ...storage.Meta) error
+	SetDefaults(ctx context.Context, metas ...storage.Meta) error
+	Delete(ctx context.Context, metricType string, id string) (bool, error)
+}
+
+func WithMeta(meta MetaStorage) Option {
+	return func(h *Handler) {
+		h.meta = meta
+	}
+}
+
+func (h *Handler) handleGetMeta(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if h.meta == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Metadata is not configured"})
+		return
+	}
+
+	metricType, id := c.Param("type"), c.Param("name")
+	if !h.isMetricType(metricType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid metrics type"})
+		return
+	}
+
+	var meta storage.Meta
+	if err := retry.Retry(
+		func() (err error) {
+			meta, err = h.meta.Get(ctx, metricType, id)
+			return
+		},
+		func(err error) bool {
+			return errors.Is(err, driver.ErrBadConn)
+		},
+		[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+	); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Metadata not found"})
+			return
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get metadata"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toModelMeta(meta))
+}
+
+func (h *Handler) handleListMeta(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if h.meta == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Metadata is not configured"})
+		return
+	}
+
+	var metas []storage.Meta
+	if err := retry.Retry(
+		func() (err error) {
+			metas, err = h.meta.GetAll(ctx)
+			return
+		},
+		func(err error) bool {
+			return errors.Is(err, driver.ErrBadConn)
+		},
+		[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get metadata"})
+		return
+	}
+
+	token := auth.FromContext(ctx)
+	response := make([]model.MetricMeta, 0, len(metas))
+	for _, meta := range metas {
+		if token.Allows(meta.ID) {
+			response = append(response, toModelMeta(meta))
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// handlePutMeta sets the metadata of the metric in the path. The type and
+// the id of the body, if any, must match the path.
+func (h *Handler) handlePutMeta(c *gin.Context) {
+	var meta model.MetricMeta
+	message, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	err = json.Unmarshal(message, &meta)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to unmarshal request body"})
+		return
+	}
+
+	metricType, id := c.Param("type"), c.Param("name")
+	if (meta.Type != "" && meta.Type != metricType) || (meta.ID != "" && meta.ID != id) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The metric in the body does not match the path"})
+		return
+	}
+	meta.Type, meta.ID = metricType, id
+
+	h.putMeta(c, false, meta)
+}
+
+// handlePutMetas sets the metadata of a list of metrics.
+func (h *Handler) handlePutMetas(c *gin.Context) {
+	h.handleMetas(c, false)
+}
+
+// handlePostMetaDefaults sets the metadata of the metrics that have none,
+// the agents send the metadata of their built-in metrics with it so that
+// the metadata edited by the users is kept.
+func (h *Handler) handlePostMetaDefaults(c *gin.Context) {
+	h.handleMetas(c, true)
+}
+
+func (h *Handler) handleMetas(c *gin.Context, defaults bool) {
+	var metas []model.MetricMeta
+	message, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	err = json.Unmarshal(message, &metas)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to unmarshal request body"})
+		return
+	}
+
+	h.putMeta(c, defaults, metas...)
+}
+
+// putMeta replaces the metadata of the metrics, or only adds the missing
+// metadata when defaults is set.
+func (h *Handler) putMeta(c *gin.Context, defaults bool, metas ...model.MetricMeta) {
+	ctx := c.Request.Context()
+
+	if h.meta == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Metadata is not configured"})
+		return
+	}
+
+	values := make([]storage.Meta, len(metas))
+	for i, meta := range metas {
+		if !h.isMetricType(meta.Type) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid metrics type"})
+			return
+		}
+		if meta.ID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The metric id is empty"})
+			return
+		}
+		if !auth.FromContext(ctx).Allows(meta.ID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": forbiddenMetric(meta.ID)})
+			return
+		}
+
+		values[i] = storage.Meta{
+			Type:        meta.Type,
+			ID:          meta.ID,
+			Unit:        meta.Unit,
+			Description: meta.Description,
+			Owner:       meta.Owner,
+			DisplayName: meta.DisplayName,
+		}
+	}
+
+	set := h.meta.Set
+	if defaults {
+		set = h.meta.SetDefaults
+	}
+
+	if err := retry.Retry(
+		func() error {
+			return set(ctx, values...)
+		},
+		func(err error) bool {
+			return errors.Is(err, driver.ErrBadConn)
+		},
+		[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set metadata"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) handleDeleteMeta(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if h.meta == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Metadata is not configured"})
+		return
+	}
+
+	metricType, id := c.Param("type"), c.Param("name")
+	if !h.isMetricType(metricType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid metrics type"})
+		return
+	}
+
+	var deleted bool
+	if err := retry.Retry(
+		func() (err error) {
+			deleted, err = h.meta.Delete(ctx, metricType, id)
+			return
+		},
+		func(err error) bool {
+			return errors.Is(err, driver.ErrBadConn)
+		},
+		[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete metadata"})
+		return
+	}
+
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Metadata not found"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// metaIndex returns the metadata of every metric by type and id. Pages
+// still render without it, so a failure is only logged.
+func (h *Handler) metaIndex(ctx context.Context) map[string]map[string]storage.Meta {
+	index := map[string]map[string]storage.Meta{}
+	if h.meta == nil {
+		return index
+	}
+
+	metas, err := h.meta.GetAll(ctx)
+	if err != nil {
+		logger.Log.Info("Failed to get metadata", logger.Error(err))
+		return index
+	}
+
+	for _, meta := range metas {
+		if _, ok := index[meta.Type]; !ok {
+			index[meta.Type] = map[string]storage.Meta{}
+		}
+		index[meta.Type][meta.ID] = meta
+	}
+
+	return index
+}
+
+func (h *Handler) isMetricType(metricType string) bool {
+	return metricType == h.gaugeStorage.GetName() || metricType == h.counterStorage.GetName()
+}
+
+func toModelMeta(meta storage.Meta) model.MetricMeta {
+	return model.MetricMeta{
+		Type:        meta.Type,
+		ID:          meta.ID,
+		Unit:        meta.Unit,
+		Description: meta.Description,
+		Owner:       meta.Owner,
+		DisplayName: meta.DisplayName,
+	}
+}
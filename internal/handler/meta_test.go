@@ -0,0 +1,127 @@
+package handler_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/handler"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+func TestMetricHandler_HandleMeta(t *testing.T) {
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	metaStorage, err := storage.NewMetaStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, gaugeStorage.Set(context.Background(), storage.Value[float64]{Key: "MSpanSys", Value: 16}))
+
+	handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil, handler.WithMeta(metaStorage))
+
+	do := func(method string, url string, body string) (int, string) {
+		request := httptest.NewRequest(method, url, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler2.ServeHTTP(w, request)
+
+		result := w.Result()
+		defer result.Body.Close()
+		data, err := io.ReadAll(result.Body)
+		require.NoError(t, err)
+		return result.StatusCode, string(data)
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Put",
+			method:         http.MethodPut,
+			url:            "/api/v1/meta/gauge/MSpanSys",
+			body:           `{"unit":"bytes","description":"Memory <obtained> for mspans","owner":"runtime","display_name":"MSpan obtained"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Get",
+			method:         http.MethodGet,
+			url:            "/api/v1/meta/gauge/MSpanSys",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"type":"gauge","id":"MSpanSys","unit":"bytes","description":"Memory <obtained> for mspans","owner":"runtime","display_name":"MSpan obtained"}`,
+		},
+		{
+			name:           "Put list",
+			method:         http.MethodPut,
+			url:            "/api/v1/meta",
+			body:           `[{"type":"counter","id":"PollCount","unit":"polls"}]`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Post defaults",
+			method:         http.MethodPost,
+			url:            "/api/v1/meta/defaults",
+			body:           `[{"type":"gauge","id":"MSpanSys","unit":"bytes"},{"type":"gauge","id":"HeapSys","unit":"bytes"}]`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "List",
+			method:         http.MethodGet,
+			url:            "/api/v1/meta",
+			expectedStatus: http.StatusOK,
+			expectedBody: `[{"type":"counter","id":"PollCount","unit":"polls"},` +
+				`{"type":"gauge","id":"HeapSys","unit":"bytes"},` +
+				`{"type":"gauge","id":"MSpanSys","unit":"bytes","description":"Memory <obtained> for mspans","owner":"runtime","display_name":"MSpan obtained"}]`,
+		},
+		{"Body does not match", http.MethodPut, "/api/v1/meta/gauge/MSpanSys", `{"id":"Alloc"}`, http.StatusBadRequest, ""},
+		{"Invalid type", http.MethodPut, "/api/v1/meta/invalid/MSpanSys", `{}`, http.StatusBadRequest, ""},
+		{"Empty id in list", http.MethodPut, "/api/v1/meta", `[{"type":"gauge"}]`, http.StatusBadRequest, ""},
+		{"Invalid body", http.MethodPut, "/api/v1/meta", `{`, http.StatusBadRequest, ""},
+		{"Not found", http.MethodGet, "/api/v1/meta/counter/MSpanSys", "", http.StatusNotFound, ""},
+		{"Delete", http.MethodDelete, "/api/v1/meta/counter/PollCount", "", http.StatusOK, ""},
+		{"Delete again", http.MethodDelete, "/api/v1/meta/counter/PollCount", "", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := do(tt.method, tt.url, tt.body)
+			require.Equal(t, tt.expectedStatus, status)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, body)
+			}
+		})
+	}
+
+	t.Run("HTML", func(t *testing.T) {
+		status, body := do(http.MethodGet, "/", "")
+		require.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, `<td data-key="MSpanSys">MSpan obtained <span class="id">(MSpanSys)</span></td>`)
+		assert.Contains(t, body, `<td>bytes</td>`)
+		assert.Contains(t, body, `<td>Memory &lt;obtained&gt; for mspans</td>`)
+	})
+
+	t.Run("Prometheus", func(t *testing.T) {
+		status, body := do(http.MethodGet, "/metrics", "")
+		require.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, "# HELP MSpanSys Memory <obtained> for mspans (bytes)\n")
+	})
+
+	t.Run("Not configured", func(t *testing.T) {
+		handler3 := handler.NewHandler(gaugeStorage, counterStorage, nil)
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/meta", nil)
+		w := httptest.NewRecorder()
+		handler3.ServeHTTP(w, request)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/c2pc/go-musthave-metrics/internal/auth"
+	"github.com/c2pc/go-musthave-metrics/internal/logger"
+)
+
+// TokenIDKey holds the id of the token of the request in the gin context,
+// it is logged instead of the token.
+const TokenIDKey = "token_id"
+
+// Auth checks the bearer token of the requests. A nil Auth lets every
+// request through.
+type Auth struct {
+	authenticator *auth.Authenticator
+}
+
+func NewAuth(authenticator *auth.Authenticator) *Auth {
+	return &Auth{authenticator: authenticator}
+}
+
+// Require rejects the requests without a token of the scope, and the ones
+// naming a metric, in the path or in the id query parameter, outside of the
+// token prefix. The token is stored in the request context.
+func (a *Auth) Require(scope auth.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a == nil {
+			c.Next()
+			return
+		}
+
+		secret, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "The bearer token is missing"})
+			return
+		}
+
+		token, err := a.authenticator.Authenticate(c.Request.Context(), secret)
+		if err != nil {
+			if errors.Is(err, auth.ErrInvalidToken) {
+				c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+				return
+			}
+			logger.Log.Info("Failed to authenticate token", logger.Error(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check token"})
+			return
+		}
+		c.Set(TokenIDKey, token.ID)
+
+		if !token.Scope.Includes(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "The token scope does not allow this request"})
+			return
+		}
+
+		for _, id := range []string{c.Param("name"), c.Query("id")} {
+			if id != "" && !token.Allows(id) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "The token does not allow the metric " + id})
+				return
+			}
+		}
+
+		c.Request = c.Request.WithContext(auth.NewContext(c.Request.Context(), token))
+		c.Next()
+	}
+}
+
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+)
+
+// ClientCertAgentID takes the agent id from the common name of the client
+// certificate instead of the X-Agent-ID header, so agents can't report or
+// read settings as another agent. Requests without a certificate pass
+// unchanged.
+func ClientCertAgentID(c *gin.Context) {
+	if c.Request.TLS == nil || len(c.Request.TLS.PeerCertificates) == 0 {
+		c.Next()
+		return
+	}
+
+	agentID := c.Request.TLS.PeerCertificates[0].Subject.CommonName
+	if id := c.Param("id"); id != "" && id != agentID {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "The client certificate does not allow the agent " + id})
+		return
+	}
+
+	c.Request.Header.Set(model.AgentIDHeader, agentID)
+	c.Next()
+}
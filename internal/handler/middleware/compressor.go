@@ -47,7 +47,10 @@ func GzipDecompressor(c *gin.Context) {
 		c.Request.Body = io.NopCloser(bytes.NewReader(decompressedData))
 		c.Request.ContentLength = int64(len(decompressedData))
 		c.Request.Header.Set("Content-Encoding", "")
-		c.Request.Header.Set("Content-Type", http.DetectContentType(decompressedData))
+		// keep the declared type of the payload, a gzip type says nothing about it
+		if contentType := c.Request.Header.Get("Content-Type"); contentType == "" || strings.Contains(contentType, "gzip") {
+			c.Request.Header.Set("Content-Type", http.DetectContentType(decompressedData))
+		}
 	}
 
 	c.Next()
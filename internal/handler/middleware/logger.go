@@ -42,6 +42,10 @@ func Logger(c *gin.Context) {
 		logger.Any("request", string(body)),
 		logger.Any("response", blw.body.String()),
 	}
+	// the token itself is never logged, only its id
+	if tokenID := c.GetString(TokenIDKey); tokenID != "" {
+		fields = append(fields, logger.Any("token_id", tokenID))
+	}
 
 	logger.Log.Info(c.Request.RequestURI, fields...)
 }
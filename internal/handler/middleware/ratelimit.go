@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimiter is a token bucket shared by all clients. A limit of 0 lets
+// every request through.
+type RateLimiter struct {
+	mu     sync.Mutex
+	limit  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(limit float64) *RateLimiter {
+	r := &RateLimiter{}
+	r.SetLimit(limit)
+	return r
+}
+
+// SetLimit changes the number of requests allowed per second.
+func (r *RateLimiter) SetLimit(limit float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.limit = limit
+	r.tokens = burst(limit)
+	r.last = time.Now()
+}
+
+func (r *RateLimiter) Handle(c *gin.Context) {
+	if !r.allow() {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
+
+func (r *RateLimiter) allow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.limit == 0 {
+		return true
+	}
+
+	now := time.Now()
+	r.tokens = math.Min(burst(r.limit), r.tokens+now.Sub(r.last).Seconds()*r.limit)
+	r.last = now
+
+	if r.tokens < 1 {
+		return false
+	}
+
+	r.tokens--
+	return true
+}
+
+func burst(limit float64) float64 {
+	return math.Max(1, limit)
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+	"github.com/c2pc/go-musthave-metrics/internal/otlp"
+)
+
+const (
+	otlpJSONContentType     = "application/json"
+	otlpProtobufContentType = "application/x-protobuf"
+)
+
+// handleOTLP stores the metrics of an OTLP/HTTP export request, encoded in
+// protobuf or in JSON depending on its content type. The points that can't
+// be stored are reported in the partial success of the response. The times of
+// the points are their times in the history, the forwarded points and the
+// events.
+func (h *Handler) handleOTLP(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	isJSON := strings.HasPrefix(c.ContentType(), otlpJSONContentType)
+
+	var req *otlp.Request
+	if isJSON {
+		req, err = otlp.UnmarshalJSON(body)
+	} else {
+		req, err = otlp.UnmarshalProtobuf(body)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid OTLP request"})
+		return
+	}
+
+	status, message := http.StatusOK, ""
+	result, stored := h.otlp.Write(req, func(metrics []model.Metrics, times []time.Time) bool {
+		if len(metrics) > 0 {
+			status, message = h.updateTimedBatch(ctx, "", 0, metrics, times)
+		}
+		return status == http.StatusOK
+	})
+	if !stored {
+		// the series keep their state, the retried request gives the same
+		// deltas
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	if isJSON {
+		data, _ := json.Marshal(result)
+		c.Data(http.StatusOK, otlpJSONContentType, data)
+		return
+	}
+	c.Data(http.StatusOK, otlpProtobufContentType, result.MarshalProtobuf())
+}
@@ -0,0 +1,197 @@
+package handler_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/handler"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+func TestMetricHandler_HandleOTLP(t *testing.T) {
+	tests := []struct {
+		name            string
+		contentType     string
+		body            string
+		expectedStatus  int
+		expectedBody    string
+		wantContentType string
+		wantGauges      map[string]float64
+		wantCounters    map[string]int64
+	}{
+		{
+			name:        "JSON",
+			contentType: "application/json",
+			body: `{"resourceMetrics": [{
+				"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "api"}}]},
+				"scopeMetrics": [{"metrics": [
+					{"name": "requests", "sum": {"aggregationTemporality": 1, "isMonotonic": true, "dataPoints": [{"asInt": "3"}]}},
+					{"name": "load", "gauge": {"dataPoints": [{"asDouble": 0.5, "attributes": [{"key": "host", "value": {"stringValue": "a"}}]}]}}
+				]}]
+			}]}`,
+			expectedStatus:  http.StatusOK,
+			expectedBody:    `{}`,
+			wantContentType: "application/json",
+			wantGauges:      map[string]float64{"api.load.a": 0.5},
+			wantCounters:    map[string]int64{"api.requests": 3},
+		},
+		{
+			name:        "Partial success",
+			contentType: "application/json",
+			body: `{"resourceMetrics": [{"scopeMetrics": [{"metrics": [
+				{"name": "load", "gauge": {"dataPoints": [{"asDouble": 1}]}},
+				{"name": "latency", "exponentialHistogram": {"dataPoints": [{}, {}]}}
+			]}]}]}`,
+			expectedStatus:  http.StatusOK,
+			expectedBody:    `{"partialSuccess": {"rejectedDataPoints": "2", "errorMessage": "unsupported type of latency"}}`,
+			wantContentType: "application/json",
+			wantGauges:      map[string]float64{"load": 1},
+			wantCounters:    map[string]int64{},
+		},
+		{
+			name:            "Empty protobuf",
+			contentType:     "application/x-protobuf",
+			expectedStatus:  http.StatusOK,
+			wantContentType: "application/x-protobuf",
+			wantGauges:      map[string]float64{},
+			wantCounters:    map[string]int64{},
+		},
+		{
+			name:           "Invalid protobuf",
+			contentType:    "application/x-protobuf",
+			body:           "\x0a\x05",
+			expectedStatus: http.StatusBadRequest,
+			wantGauges:     map[string]float64{},
+			wantCounters:   map[string]int64{},
+		},
+		{
+			name:           "Invalid JSON",
+			contentType:    "application/json",
+			body:           `{"resourceMetrics":`,
+			expectedStatus: http.StatusBadRequest,
+			wantGauges:     map[string]float64{},
+			wantCounters:   map[string]int64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+			require.NoError(t, err)
+			counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+			require.NoError(t, err)
+
+			handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/metrics", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			handler2.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.wantContentType != "" {
+				assert.Equal(t, tt.wantContentType, w.Header().Get("Content-Type"))
+			}
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, w.Body.String())
+			}
+
+			gauges, err := gaugeStorage.GetAll(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantGauges, gauges)
+
+			counters, err := counterStorage.GetAll(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCounters, counters)
+		})
+	}
+}
+
+func TestMetricHandler_HandleOTLP_Concurrent(t *testing.T) {
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+
+	handler2 := handler.NewHandler(gaugeStorage, slowCounters{counterStorage}, nil)
+
+	export := func(value int) int {
+		body := fmt.Sprintf(`{"resourceMetrics": [{"scopeMetrics": [{"metrics": [
+			{"name": "requests", "sum": {"aggregationTemporality": 2, "isMonotonic": true, "dataPoints": [{"startTimeUnixNano": "1", "asInt": "%d"}]}}
+		]}]}]}`, value)
+		req := httptest.NewRequest(http.MethodPost, "/v1/metrics", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler2.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// the first point of the series is the baseline
+	require.Equal(t, http.StatusOK, export(5))
+
+	// the same total sent concurrently is counted once
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, http.StatusOK, export(20))
+		}()
+	}
+	wg.Wait()
+
+	total, err := counterStorage.Get(context.Background(), "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(15), total)
+}
+
+// slowCounters widens the window between the conversion and the commit of
+// the concurrent exports.
+type slowCounters struct {
+	*storage.CounterStorage
+}
+
+func (s slowCounters) Set(ctx context.Context, values ...storage.Valuer[int64]) error {
+	time.Sleep(5 * time.Millisecond)
+	return s.CounterStorage.Set(ctx, values...)
+}
+
+func TestMetricHandler_HandleOTLP_Times(t *testing.T) {
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	historyStorage, err := storage.NewHistoryStorage(storage.TypeMemory, nil, 100, nil)
+	require.NoError(t, err)
+
+	handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil, handler.WithHistory(historyStorage))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/metrics", strings.NewReader(`{"resourceMetrics": [{"scopeMetrics": [{"metrics": [
+		{"name": "load", "gauge": {"dataPoints": [{"asDouble": 0.5, "timeUnixNano": "1704067200000000000"}]}},
+		{"name": "latency", "histogram": {"dataPoints": [{"count": "2", "timeUnixNano": "1704067260000000000"}]}}
+	]}]}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler2.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	start := time.Unix(1704067200, 0)
+	buckets, err := historyStorage.Query(context.Background(), "gauge", "load", start, start.Add(time.Hour), 0)
+	require.NoError(t, err)
+	require.Len(t, buckets, 1)
+	assert.True(t, start.Equal(buckets[0].Time))
+
+	buckets, err = historyStorage.Query(context.Background(), "gauge", "latency.count", start, start.Add(time.Hour), 0)
+	require.NoError(t, err)
+	require.Len(t, buckets, 1)
+	assert.True(t, start.Add(time.Minute).Equal(buckets[0].Time))
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/c2pc/go-musthave-metrics/internal/prometheus"
+	"github.com/c2pc/go-musthave-metrics/internal/retry"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type prometheusSample struct {
+	name       string
+	help       string
+	metricType string
+	value      string
+}
+
+// handleMetrics renders the stored metrics in the Prometheus text format.
+func (h *Handler) handleMetrics(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var gauges map[string]float64
+	if err := retry.Retry(
+		func() (err error) {
+			gauges, err = h.gaugeStorage.GetAll(ctx)
+			return
+		},
+		func(err error) bool {
+			return errors.Is(err, driver.ErrBadConn)
+		},
+		[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+	); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	var counters map[string]int64
+	if err := retry.Retry(
+		func() (err error) {
+			counters, err = h.counterStorage.GetAll(ctx)
+			return
+		},
+		func(err error) bool {
+			return errors.Is(err, driver.ErrBadConn)
+		},
+		[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+	); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	gauges, counters = allowedKeys(ctx, gauges), allowedKeys(ctx, counters)
+	metas := h.metaIndex(ctx)
+
+	samples := make([]prometheusSample, 0, len(gauges)+len(counters))
+	for key, value := range gauges {
+		samples = append(samples, prometheusSample{
+			name:       prometheus.MetricName(h.gaugeStorage.GetName(), key),
+			help:       metricHelp("Gauge", key, metas[h.gaugeStorage.GetName()][key]),
+			metricType: "gauge",
+			value:      strconv.FormatFloat(value, 'g', -1, 64),
+		})
+	}
+	for key, value := range counters {
+		samples = append(samples, prometheusSample{
+			name:       prometheus.MetricName(h.counterStorage.GetName(), key),
+			help:       metricHelp("Counter", key, metas[h.counterStorage.GetName()][key]),
+			metricType: "counter",
+			value:      strconv.FormatInt(value, 10),
+		})
+	}
+
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].name != samples[j].name {
+			return samples[i].name < samples[j].name
+		}
+		return samples[i].help < samples[j].help
+	})
+
+	disambiguateNames(samples)
+
+	var buf bytes.Buffer
+	for _, sample := range samples {
+		fmt.Fprintf(&buf, "# HELP %s %s\n", sample.name, escapeHelp(sample.help))
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", sample.name, sample.metricType)
+		fmt.Fprintf(&buf, "%s %s\n", sample.name, sample.value)
+	}
+
+	c.Data(http.StatusOK, prometheusContentType, buf.Bytes())
+}
+
+// disambiguateNames renames the samples whose names collide after
+// sanitizing: the first of the sorted samples keeps the name, the others get
+// the first free _2, _3, ... suffix, before _total for the counters.
+func disambiguateNames(samples []prometheusSample) {
+	used := make(map[string]bool, len(samples))
+	for _, sample := range samples {
+		used[sample.name] = true
+	}
+
+	seen := make(map[string]bool, len(samples))
+	for i, sample := range samples {
+		if !seen[sample.name] {
+			seen[sample.name] = true
+			continue
+		}
+
+		base, suffix := sample.name, ""
+		if sample.metricType == "counter" {
+			base, suffix = strings.TrimSuffix(base, "_total"), "_total"
+		}
+		for n := 2; ; n++ {
+			name := base + "_" + strconv.Itoa(n) + suffix
+			if !used[name] {
+				used[name] = true
+				samples[i].name = name
+				break
+			}
+		}
+	}
+}
+
+// metricHelp prefers the description of the metric, the unit is appended
+// since the text format has no place of its own for it.
+func metricHelp(kind string, key string, meta storage.Meta) string {
+	help := kind + " " + key
+	if meta.Description != "" {
+		help = meta.Description
+	}
+	if meta.Unit != "" {
+		help += " (" + meta.Unit + ")"
+	}
+	return help
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
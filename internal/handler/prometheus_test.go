@@ -0,0 +1,122 @@
+package handler_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/database"
+	"github.com/c2pc/go-musthave-metrics/internal/handler"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+const expectedMetrics = `# HELP Alloc Gauge Alloc
+# TYPE Alloc gauge
+Alloc 1.5
+# HELP PollCount_total Counter PollCount
+# TYPE PollCount_total counter
+PollCount_total 3
+# HELP _5xx_total Counter 5xx
+# TYPE _5xx_total counter
+_5xx_total 7
+# HELP probe_api_latency_ms Gauge probe.api.latency-ms
+# TYPE probe_api_latency_ms gauge
+probe_api_latency_ms 12
+`
+
+func TestMetricHandler_HandleMetrics(t *testing.T) {
+	get := func(h http.Handler) (int, string, string) {
+		request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, request)
+
+		result := w.Result()
+		defer result.Body.Close()
+		body, err := io.ReadAll(result.Body)
+		require.NoError(t, err)
+		return result.StatusCode, result.Header.Get("Content-Type"), string(body)
+	}
+
+	t.Run("Memory", func(t *testing.T) {
+		gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+		require.NoError(t, err)
+		counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		require.NoError(t, gaugeStorage.Set(ctx,
+			storage.Value[float64]{Key: "Alloc", Value: 1.5},
+			storage.Value[float64]{Key: "probe.api.latency-ms", Value: 12},
+		))
+		require.NoError(t, counterStorage.Set(ctx,
+			storage.Value[int64]{Key: "PollCount", Value: 3},
+			storage.Value[int64]{Key: "5xx", Value: 7},
+		))
+
+		status, contentType, body := get(handler.NewHandler(gaugeStorage, counterStorage, nil))
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", contentType)
+		assert.Equal(t, expectedMetrics, body)
+	})
+
+	t.Run("Collisions", func(t *testing.T) {
+		gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+		require.NoError(t, err)
+		counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		require.NoError(t, gaugeStorage.Set(ctx,
+			storage.Value[float64]{Key: "requests.total", Value: 1},
+			storage.Value[float64]{Key: "requests-total", Value: 2},
+		))
+		require.NoError(t, counterStorage.Set(ctx,
+			storage.Value[int64]{Key: "requests", Value: 3},
+			storage.Value[int64]{Key: "requests_2", Value: 4},
+		))
+
+		status, _, body := get(handler.NewHandler(gaugeStorage, counterStorage, nil))
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, `# HELP requests_2_total Counter requests_2
+# TYPE requests_2_total counter
+requests_2_total 4
+# HELP requests_total Counter requests
+# TYPE requests_total counter
+requests_total 3
+# HELP requests_total_2 Gauge requests-total
+# TYPE requests_total_2 gauge
+requests_total_2 2
+# HELP requests_total_3 Gauge requests.total
+# TYPE requests_total_3 gauge
+requests_total_3 1
+`, body)
+	})
+
+	t.Run("DB", func(t *testing.T) {
+		mockDB, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer mockDB.Close()
+
+		db := &database.DB{DB: mockDB}
+		gaugeStorage, err := storage.NewGaugeStorage(storage.TypeDB, db)
+		require.NoError(t, err)
+		counterStorage, err := storage.NewCounterStorage(storage.TypeDB, db)
+		require.NoError(t, err)
+
+		mock.ExpectQuery("^SELECT key, value FROM gauges$").
+			WillReturnRows(sqlmock.NewRows([]string{"key", "value"}).AddRow("Alloc", 1.5).AddRow("probe.api.latency-ms", 12.0))
+		mock.ExpectQuery("^SELECT key, value FROM counters$").
+			WillReturnRows(sqlmock.NewRows([]string{"key", "value"}).AddRow("PollCount", 3).AddRow("5xx", 7))
+
+		status, _, body := get(handler.NewHandler(gaugeStorage, counterStorage, db))
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, expectedMetrics, body)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/c2pc/go-musthave-metrics/internal/auth"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+	"github.com/c2pc/go-musthave-metrics/internal/retry"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+const defaultTopK = 10
+
+func (h *Handler) handleQuery(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var query model.Query
+	message, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	err = json.Unmarshal(message, &query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to unmarshal request body"})
+		return
+	}
+
+	selector := storage.Selector{Glob: query.Name, Regex: query.Regex, Labels: queryLabels(query.Labels)}
+	if err := selector.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid selector"})
+		return
+	}
+
+	// a token restricted to a prefix may only query globs starting with it,
+	// regexes can't be checked
+	if token := auth.FromContext(ctx); token.Prefix != "" {
+		literal := query.Name
+		if i := strings.IndexAny(literal, "*?"); i >= 0 {
+			literal = literal[:i]
+		}
+		if query.Regex != "" || !token.Allows(literal) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "The token does not allow the selector"})
+			return
+		}
+	}
+
+	aggregation := storage.Aggregation(query.Aggregation)
+	if !aggregation.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid aggregation"})
+		return
+	}
+
+	if query.K < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid k"})
+		return
+	}
+	if query.K == 0 {
+		query.K = defaultTopK
+	}
+
+	var aggregate func() (storage.AggregateResult, error)
+	switch query.Type {
+	case h.gaugeStorage.GetName():
+		aggregate = func() (storage.AggregateResult, error) {
+			return h.gaugeStorage.Aggregate(ctx, selector, aggregation, query.K)
+		}
+	case h.counterStorage.GetName():
+		aggregate = func() (storage.AggregateResult, error) {
+			return h.counterStorage.Aggregate(ctx, selector, aggregation, query.K)
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid metrics type"})
+		return
+	}
+
+	var result storage.AggregateResult
+	if err := retry.Retry(
+		func() (err error) {
+			result, err = aggregate()
+			return
+		},
+		func(err error) bool {
+			return errors.Is(err, driver.ErrBadConn)
+		},
+		[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run query"})
+		return
+	}
+
+	response := model.QueryResult{Aggregation: query.Aggregation, Value: result.Value}
+	if aggregation == storage.AggregationTopK {
+		response.Metrics = make([]model.Metrics, 0, len(result.Top))
+		for _, top := range result.Top {
+			metric := model.Metrics{ID: top.Key, Type: query.Type}
+			if query.Type == h.counterStorage.GetName() {
+				delta := int64(top.Value)
+				metric.Delta = &delta
+			} else {
+				value := top.Value
+				metric.Value = &value
+			}
+			response.Metrics = append(response.Metrics, metric)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// queryLabels returns the label values as the writes put them in the metric
+// names, ordered by key. The names keep the values only, the keys are not
+// matched.
+func queryLabels(labels map[string]string) []string {
+	values := make([]string, 0, len(labels))
+	for _, key := range sortedKeys(labels) {
+		values = append(values, metricNameReplacer.Replace(labels[key]))
+	}
+	return values
+}
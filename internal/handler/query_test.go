@@ -0,0 +1,119 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/handler"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+func TestMetricHandler_HandleQuery(t *testing.T) {
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, gaugeStorage.Set(ctx,
+		storage.Value[float64]{Key: "HeapAlloc", Value: 10},
+		storage.Value[float64]{Key: "HeapInuse", Value: 7},
+		storage.Value[float64]{Key: "mem.web_1.HeapAlloc", Value: 3},
+		storage.Value[float64]{Key: "mem.web_2.HeapAlloc", Value: 5},
+		storage.Value[float64]{Key: "mem.web_2.HeapInuse", Value: 4},
+	))
+	require.NoError(t, counterStorage.Set(ctx,
+		storage.Value[int64]{Key: "requests_ok", Value: 4},
+		storage.Value[int64]{Key: "requests_failed", Value: 1},
+		storage.Value[int64]{Key: "PollCount", Value: 9},
+	))
+
+	handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil)
+
+	value := func(v float64) *float64 {
+		return &v
+	}
+	delta := func(d int64) *int64 {
+		return &d
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		want           model.QueryResult
+	}{
+		{
+			name:           "Sum of counters",
+			body:           `{"type":"counter","name":"requests_*","aggregation":"sum"}`,
+			expectedStatus: http.StatusOK,
+			want:           model.QueryResult{Aggregation: "sum", Value: value(5)},
+		},
+		{
+			name:           "Max gauge",
+			body:           `{"type":"gauge","regex":"Heap.*","aggregation":"max"}`,
+			expectedStatus: http.StatusOK,
+			want:           model.QueryResult{Aggregation: "max", Value: value(10)},
+		},
+		{
+			name:           "Labels",
+			body:           `{"type":"gauge","name":"mem.*","labels":{"host":"web 2"},"aggregation":"sum"}`,
+			expectedStatus: http.StatusOK,
+			want:           model.QueryResult{Aggregation: "sum", Value: value(9)},
+		},
+		{
+			name:           "Labels are whole segments",
+			body:           `{"type":"gauge","labels":{"host":"web"},"aggregation":"count"}`,
+			expectedStatus: http.StatusOK,
+			want:           model.QueryResult{Aggregation: "count", Value: value(0)},
+		},
+		{
+			name:           "Min of nothing",
+			body:           `{"type":"gauge","name":"Unknown","aggregation":"min"}`,
+			expectedStatus: http.StatusOK,
+			want:           model.QueryResult{Aggregation: "min"},
+		},
+		{
+			name:           "TopK counters",
+			body:           `{"type":"counter","aggregation":"topk","k":2}`,
+			expectedStatus: http.StatusOK,
+			want: model.QueryResult{Aggregation: "topk", Metrics: []model.Metrics{
+				{ID: "PollCount", Type: "counter", Delta: delta(9)},
+				{ID: "requests_ok", Type: "counter", Delta: delta(4)},
+			}},
+		},
+		{"Invalid type", `{"type":"invalid","aggregation":"sum"}`, http.StatusBadRequest, model.QueryResult{}},
+		{"Invalid aggregation", `{"type":"gauge","aggregation":"median"}`, http.StatusBadRequest, model.QueryResult{}},
+		{"Invalid regex", `{"type":"gauge","regex":"(","aggregation":"sum"}`, http.StatusBadRequest, model.QueryResult{}},
+		{"Glob and regex", `{"type":"gauge","name":"*","regex":".*","aggregation":"sum"}`, http.StatusBadRequest, model.QueryResult{}},
+		{"Invalid label", `{"type":"gauge","labels":{"host":""},"aggregation":"sum"}`, http.StatusBadRequest, model.QueryResult{}},
+		{"Invalid k", `{"type":"gauge","aggregation":"topk","k":-1}`, http.StatusBadRequest, model.QueryResult{}},
+		{"Invalid body", `{`, http.StatusBadRequest, model.QueryResult{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/v1/query", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler2.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+			require.Equal(t, tt.expectedStatus, result.StatusCode)
+
+			if tt.expectedStatus == http.StatusOK {
+				var got model.QueryResult
+				require.NoError(t, json.NewDecoder(result.Body).Decode(&got))
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/handler"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+type agentSettings map[string]model.AgentSettings
+
+func (s agentSettings) Get(agentID string) model.AgentSettings {
+	if settings, ok := s[agentID]; ok {
+		return settings
+	}
+	return s["default"]
+}
+
+func TestMetricHandler_HandleAgentSettings(t *testing.T) {
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+
+	settings := agentSettings{
+		"default": {PollInterval: 5, ReportInterval: 20},
+		"agent1":  {PollInterval: 1, Collectors: []string{"runtime"}, Exclude: []string{"Random*"}},
+	}
+
+	handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil, handler.WithAgentSettings(settings))
+
+	get := func(h http.Handler, id string, etag string) *http.Response {
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/agents/"+id+"/config", nil)
+		if etag != "" {
+			request.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, request)
+		return w.Result()
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		want model.AgentSettings
+	}{
+		{"Agent settings", "agent1", settings["agent1"]},
+		{"Default settings", "agent2", settings["default"]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := get(handler2, tt.id, "")
+			defer result.Body.Close()
+			require.Equal(t, http.StatusOK, result.StatusCode)
+
+			var got model.AgentSettings
+			require.NoError(t, json.NewDecoder(result.Body).Decode(&got))
+			assert.Equal(t, tt.want, got)
+
+			etag := result.Header.Get("ETag")
+			require.NotEmpty(t, etag)
+
+			cached := get(handler2, tt.id, etag)
+			defer cached.Body.Close()
+			assert.Equal(t, http.StatusNotModified, cached.StatusCode)
+
+			stale := get(handler2, tt.id, `"stale"`)
+			defer stale.Body.Close()
+			assert.Equal(t, http.StatusOK, stale.StatusCode)
+		})
+	}
+
+	t.Run("Not configured", func(t *testing.T) {
+		result := get(handler.NewHandler(gaugeStorage, counterStorage, nil), "agent1", "")
+		defer result.Body.Close()
+		assert.Equal(t, http.StatusNotFound, result.StatusCode)
+	})
+}
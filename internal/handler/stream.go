@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"github.com/c2pc/go-musthave-metrics/internal/codec"
+	"github.com/c2pc/go-musthave-metrics/internal/logger"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+)
+
+var upgrader = websocket.Upgrader{
+	Subprotocols: []string{codec.NameJSON, codec.NameProtobuf, codec.NameMsgpack},
+}
+
+// handleStream keeps a WebSocket connection with an agent. Every binary
+// message is a batch framed with codec.EncodeFrame and encoded with the
+// negotiated subprotocol, every batch is answered with a model.BatchAck.
+func (h *Handler) handleStream(c *gin.Context) {
+	ctx := c.Request.Context()
+	agentID := c.GetHeader(model.AgentIDHeader)
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader has already answered the request
+		return
+	}
+	defer conn.Close()
+
+	payloadCodec, ok := codec.ByName(conn.Subprotocol())
+	if !ok {
+		payloadCodec = codec.JSON{}
+	}
+
+	logger.Log.Info("Agent stream opened", logger.Any("agent_id", agentID), logger.Any("encoding", payloadCodec.Name()))
+
+	for {
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				logger.Log.Info("Agent stream failed", logger.Any("agent_id", agentID), logger.Error(err))
+			}
+			return
+		}
+
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+
+		ack := h.streamBatch(ctx, agentID, payloadCodec, message)
+		if err := conn.WriteJSON(ack); err != nil {
+			logger.Log.Info("Failed to acknowledge batch", logger.Any("agent_id", agentID), logger.Error(err))
+			return
+		}
+	}
+}
+
+func (h *Handler) streamBatch(ctx context.Context, agentID string, payloadCodec codec.Codec, message []byte) model.BatchAck {
+	sequence, payload, err := codec.DecodeFrame(message)
+	if err != nil {
+		return model.BatchAck{Status: http.StatusBadRequest, Error: "Invalid frame"}
+	}
+
+	ack := model.BatchAck{Sequence: sequence}
+	if len(payload) == 0 {
+		ack.Status, ack.Error = http.StatusBadRequest, "The request body is empty"
+		return ack
+	}
+
+	var metrics []model.Metrics
+	if err := payloadCodec.Unmarshal(payload, &metrics); err != nil {
+		ack.Status, ack.Error = http.StatusBadRequest, "Failed to unmarshal request body"
+		return ack
+	}
+
+	ack.Status, ack.Error = h.updateBatch(ctx, agentID, sequence, metrics)
+	return ack
+}
@@ -0,0 +1,81 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/codec"
+	"github.com/c2pc/go-musthave-metrics/internal/handler"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+func TestMetricHandler_HandleStream(t *testing.T) {
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	batchStorage, err := storage.NewBatchStorage(storage.TypeMemory, nil, time.Minute, gaugeStorage, counterStorage)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(handler.NewHandler(gaugeStorage, counterStorage, nil, handler.WithBatchRegistry(batchStorage)))
+	defer server.Close()
+
+	var delta int64 = 5
+
+	for _, c := range []codec.Codec{codec.JSON{}, codec.Protobuf{}, codec.Msgpack{}} {
+		t.Run(c.Name(), func(t *testing.T) {
+			dialer := websocket.Dialer{Subprotocols: []string{c.Name()}}
+			header := http.Header{}
+			header.Set(model.AgentIDHeader, "agent-"+c.Name())
+
+			conn, response, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/v1/agents/stream", header)
+			require.NoError(t, err)
+			defer conn.Close()
+			response.Body.Close()
+			assert.Equal(t, c.Name(), conn.Subprotocol())
+
+			payload, err := c.Marshal([]model.Metrics{{ID: "streamed", Type: "counter", Delta: &delta}})
+			require.NoError(t, err)
+			invalid, err := c.Marshal([]model.Metrics{{ID: "streamed", Type: "counter"}})
+			require.NoError(t, err)
+
+			before, _ := counterStorage.Get(context.Background(), "streamed")
+
+			tests := []struct {
+				name     string
+				frame    []byte
+				ack      model.BatchAck
+				increase int64
+			}{
+				{"New batch", codec.EncodeFrame(1, payload), model.BatchAck{Sequence: 1, Status: http.StatusOK}, 5},
+				{"Replayed batch", codec.EncodeFrame(1, payload), model.BatchAck{Sequence: 1, Status: http.StatusOK}, 5},
+				{"Next batch", codec.EncodeFrame(2, payload), model.BatchAck{Sequence: 2, Status: http.StatusOK}, 10},
+				{"Invalid batch", codec.EncodeFrame(3, invalid), model.BatchAck{Sequence: 3, Status: http.StatusBadRequest, Error: "The metric delta is empty"}, 10},
+				{"Invalid frame", []byte{1}, model.BatchAck{Status: http.StatusBadRequest, Error: "Invalid frame"}, 10},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, tt.frame))
+
+					var ack model.BatchAck
+					require.NoError(t, conn.ReadJSON(&ack))
+					assert.Equal(t, tt.ack, ack)
+
+					value, err := counterStorage.Get(context.Background(), "streamed")
+					require.NoError(t, err)
+					assert.Equal(t, before+tt.increase, value)
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/c2pc/go-musthave-metrics/internal/auth"
+	"github.com/c2pc/go-musthave-metrics/internal/lineprotocol"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+	"github.com/c2pc/go-musthave-metrics/internal/retry"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+// lineValueField is left out of the metric names.
+const lineValueField = "value"
+
+var metricNameReplacer = strings.NewReplacer("/", "_", " ", "_")
+
+// handleWrite stores the metrics sent in the InfluxDB line protocol. Every
+// field is a metric named measurement.tag values ordered by tag key.field,
+// e.g. cpu,host=a usage=0.5 is the metric cpu.a.usage. Floats and booleans
+// are gauges, integers are counters and strings are not supported. The
+// producers of the protocol send the totals of their counters, so the
+// counters are replaced rather than added to. The lines are written in the
+// order of their timestamps, which are the times of their points in the
+// history, the forwarded points and the events; a line without a timestamp
+// is written at the time of the request.
+func (h *Handler) handleWrite(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	precision, err := lineprotocol.ParsePrecision(c.Query("precision"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid precision"})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	type timedMetrics struct {
+		time    time.Time
+		metrics []model.Metrics
+	}
+
+	now := time.Now()
+	var lines []timedMetrics
+	var lineErrors []model.LineError
+	for i, text := range strings.Split(string(body), "\n") {
+		text = strings.TrimSpace(text)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		line, err := lineprotocol.Parse(text, precision)
+		if err == nil {
+			var metrics []model.Metrics
+			metrics, err = h.lineMetrics(line)
+			if err == nil {
+				if line.Time.IsZero() {
+					line.Time = now
+				}
+				lines = append(lines, timedMetrics{time: line.Time, metrics: metrics})
+			}
+		}
+		if err != nil {
+			lineErrors = append(lineErrors, model.LineError{Line: i + 1, Error: err.Error()})
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].time.Before(lines[j].time)
+	})
+
+	var metrics []model.Metrics
+	var times []time.Time
+	for _, line := range lines {
+		metrics = append(metrics, line.metrics...)
+		for range line.metrics {
+			times = append(times, line.time)
+		}
+	}
+
+	if len(metrics) > 0 {
+		if status, message := h.writeLines(ctx, metrics, times); status != http.StatusOK {
+			c.JSON(status, gin.H{"error": message})
+			return
+		}
+	}
+
+	if len(lineErrors) > 0 {
+		c.JSON(http.StatusBadRequest, model.WriteResult{
+			Error:   fmt.Sprintf("Failed to parse %d lines", len(lineErrors)),
+			Written: len(metrics),
+			Lines:   lineErrors,
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// lineMetrics maps the fields of the line to metrics, the line is rejected
+// as a whole when a field is not supported.
+func (h *Handler) lineMetrics(line lineprotocol.Line) ([]model.Metrics, error) {
+	parts := []string{line.Measurement}
+	for _, tag := range line.Tags {
+		parts = append(parts, tag.Value)
+	}
+
+	prefix := strings.Join(parts, ".")
+
+	metrics := make([]model.Metrics, 0, len(line.Fields))
+	for _, field := range line.Fields {
+		id := prefix
+		if field.Key != lineValueField {
+			id += "." + field.Key
+		}
+
+		metric := model.Metrics{ID: metricNameReplacer.Replace(id)}
+		switch field.Type {
+		case lineprotocol.FieldFloat, lineprotocol.FieldBoolean:
+			value := field.Float
+			metric.Type = h.gaugeStorage.GetName()
+			metric.Value = &value
+		case lineprotocol.FieldInteger, lineprotocol.FieldUnsigned:
+			// the delta holds the total until writeLines replaces the counter
+			total := field.Integer
+			metric.Type = h.counterStorage.GetName()
+			metric.Delta = &total
+		default:
+			return nil, fmt.Errorf("field %s: string fields are not supported", field.Key)
+		}
+		metrics = append(metrics, metric)
+	}
+
+	return metrics, nil
+}
+
+// writeLines stores the metrics of the lines, ordered by time, times[i] is
+// the time of metrics[i]. The gauges are set and the counters replaced by
+// their totals, the updates are recorded with the change of the totals.
+func (h *Handler) writeLines(ctx context.Context, metrics []model.Metrics, times []time.Time) (int, string) {
+	var gauges []storage.Valuer[float64]
+	var counters []storage.Valuer[int64]
+	for _, metric := range metrics {
+		if !auth.FromContext(ctx).Allows(metric.ID) {
+			return http.StatusForbidden, forbiddenMetric(metric.ID)
+		}
+
+		if metric.Delta != nil {
+			counters = append(counters, storage.Value[int64]{Key: metric.ID, Value: *metric.Delta})
+		} else {
+			gauges = append(gauges, storage.Value[float64]{Key: metric.ID, Value: *metric.Value})
+		}
+	}
+
+	var totals map[string]int64
+	if len(counters) > 0 {
+		if err := retry.Retry(
+			func() (err error) {
+				totals, err = h.counterStorage.GetAll(ctx)
+				return
+			},
+			func(err error) bool {
+				return errors.Is(err, driver.ErrBadConn)
+			},
+			[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+		); err != nil {
+			return http.StatusInternalServerError, "Failed to set metric value"
+		}
+	}
+	if totals == nil {
+		totals = make(map[string]int64)
+	}
+
+	if len(gauges) > 0 {
+		if err := retry.Retry(
+			func() error {
+				return h.gaugeStorage.Set(ctx, gauges...)
+			},
+			func(err error) bool {
+				return errors.Is(err, driver.ErrBadConn)
+			},
+			[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+		); err != nil {
+			return http.StatusInternalServerError, "Failed to set metric value"
+		}
+	}
+
+	// the counters are in time order, the latest total is the one kept
+	if len(counters) > 0 {
+		if err := retry.Retry(
+			func() error {
+				return h.counterStorage.Replace(ctx, counters...)
+			},
+			func(err error) bool {
+				return errors.Is(err, driver.ErrBadConn)
+			},
+			[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
+		); err != nil {
+			return http.StatusInternalServerError, "Failed to set metric value"
+		}
+	}
+
+	updates := make([]model.Metrics, 0, len(metrics))
+	updateTimes := make([]time.Time, 0, len(metrics))
+	for i, metric := range metrics {
+		if metric.Delta != nil {
+			total := *metric.Delta
+			// a total below the previous one is a restarted producer
+			delta := total
+			if previous := totals[metric.ID]; total >= previous {
+				delta = total - previous
+			}
+			totals[metric.ID] = total
+			if delta == 0 {
+				continue
+			}
+			metric.Delta = &delta
+		}
+		updates = append(updates, metric)
+		updateTimes = append(updateTimes, times[i])
+	}
+
+	h.recordTimedUpdates(ctx, updateTimes, updates)
+
+	return http.StatusOK, ""
+}
@@ -0,0 +1,184 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/handler"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+func TestMetricHandler_HandleWrite(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		body           string
+		expectedStatus int
+		wantResult     *model.WriteResult
+		wantGauges     map[string]float64
+		wantCounters   map[string]int64
+	}{
+		{
+			name: "Valid",
+			url:  "/write",
+			body: "# telegraf\n" +
+				"cpu,host=a,cpu=cpu0 usage_idle=90.5,usage_user=9.5 1704067201000000000\n" +
+				"\n" +
+				"cpu,host=a,cpu=cpu0 usage_idle=80 1704067200000000000\r\n" +
+				"requests,path=/api value=3i\n" +
+				"requests,path=/api value=4i\n" +
+				"up value=true\n",
+			expectedStatus: http.StatusNoContent,
+			wantGauges:     map[string]float64{"cpu.cpu0.a.usage_idle": 90.5, "cpu.cpu0.a.usage_user": 9.5, "up": 1},
+			wantCounters:   map[string]int64{"requests._api": 4},
+		},
+		{
+			name:           "Precision",
+			url:            "/write?precision=s",
+			body:           "mem free=1 1704067200",
+			expectedStatus: http.StatusNoContent,
+			wantGauges:     map[string]float64{"mem.free": 1},
+			wantCounters:   map[string]int64{},
+		},
+		{
+			name:           "Invalid precision",
+			url:            "/write?precision=h",
+			body:           "mem free=1",
+			expectedStatus: http.StatusBadRequest,
+			wantGauges:     map[string]float64{},
+			wantCounters:   map[string]int64{},
+		},
+		{
+			name:           "Errors per line",
+			url:            "/write",
+			body:           "mem free=1\nmem free=\nmem state=\"ok\",used=2\nmem used=3i",
+			expectedStatus: http.StatusBadRequest,
+			wantResult: &model.WriteResult{
+				Error:   "Failed to parse 2 lines",
+				Written: 2,
+				Lines: []model.LineError{
+					{Line: 2, Error: "field free: value is empty"},
+					{Line: 3, Error: "field state: string fields are not supported"},
+				},
+			},
+			wantGauges:   map[string]float64{"mem.free": 1},
+			wantCounters: map[string]int64{"mem.used": 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+			require.NoError(t, err)
+			counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+			require.NoError(t, err)
+
+			handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil)
+
+			req := httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			handler2.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			if tt.wantResult != nil {
+				var got model.WriteResult
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+				assert.Equal(t, *tt.wantResult, got)
+			}
+
+			gauges, err := gaugeStorage.GetAll(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantGauges, gauges)
+
+			counters, err := counterStorage.GetAll(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCounters, counters)
+		})
+	}
+}
+
+func TestMetricHandler_HandleWrite_Timestamps(t *testing.T) {
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	historyStorage, err := storage.NewHistoryStorage(storage.TypeMemory, nil, 100, nil)
+	require.NoError(t, err)
+
+	handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil, handler.WithHistory(historyStorage))
+
+	req := httptest.NewRequest(http.MethodPost, "/write?precision=s", strings.NewReader(
+		"net bytes_recv=200i 1704067260\nnet bytes_recv=100i 1704067200\n"))
+	w := httptest.NewRecorder()
+	handler2.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	total, err := counterStorage.Get(context.Background(), "net.bytes_recv")
+	require.NoError(t, err)
+	assert.Equal(t, int64(200), total)
+
+	// the totals are recorded as their changes
+	start := time.Unix(1704067200, 0)
+	buckets, err := historyStorage.Query(context.Background(), "counter", "net.bytes_recv", start, start.Add(time.Hour), 0)
+	require.NoError(t, err)
+	require.Len(t, buckets, 2)
+	assert.True(t, start.Equal(buckets[0].Time))
+	assert.Equal(t, 100.0, buckets[0].Sum)
+	assert.True(t, start.Add(time.Minute).Equal(buckets[1].Time))
+	assert.Equal(t, 100.0, buckets[1].Sum)
+}
+
+func TestMetricHandler_HandleWrite_Totals(t *testing.T) {
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	historyStorage, err := storage.NewHistoryStorage(storage.TypeMemory, nil, 100, nil)
+	require.NoError(t, err)
+
+	handler2 := handler.NewHandler(gaugeStorage, counterStorage, nil, handler.WithHistory(historyStorage))
+
+	start := time.Unix(1704067200, 0)
+	for i, body := range []string{
+		"requests value=9007199254740993i 1704067200",
+		"requests value=9007199254740995i 1704067260",
+		"requests value=9007199254740995i 1704067320",
+		"requests value=5i 1704067380",
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/write?precision=s", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler2.ServeHTTP(w, req)
+		require.Equal(t, http.StatusNoContent, w.Code, i)
+	}
+
+	total, err := counterStorage.Get(context.Background(), "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+
+	// an unchanged total is not recorded, a lower one is a restart
+	buckets, err := historyStorage.Query(context.Background(), "counter", "requests", start, start.Add(time.Hour), 0)
+	require.NoError(t, err)
+	require.Len(t, buckets, 3)
+	assert.Equal(t, 2.0, buckets[1].Sum)
+	assert.True(t, start.Add(3*time.Minute).Equal(buckets[2].Time))
+	assert.Equal(t, 5.0, buckets[2].Sum)
+
+	req := httptest.NewRequest(http.MethodPost, "/write", strings.NewReader("requests value=9007199254740993i"))
+	w := httptest.NewRecorder()
+	handler2.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	total, err = counterStorage.Get(context.Background(), "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(9007199254740993), total)
+}
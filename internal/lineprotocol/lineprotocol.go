@@ -0,0 +1,240 @@
+// Package lineprotocol parses the InfluxDB line protocol:
+//
+//	measurement[,tag=value...] field=value[,field=value...] [timestamp]
+package lineprotocol
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type FieldType int
+
+const (
+	FieldFloat FieldType = iota
+	FieldInteger
+	FieldUnsigned
+	FieldString
+	FieldBoolean
+)
+
+type Tag struct {
+	Key   string
+	Value string
+}
+
+type Field struct {
+	Key  string
+	Type FieldType
+	// Float holds the float and boolean values, 1 for true
+	Float   float64
+	Integer int64
+	String  string
+}
+
+type Line struct {
+	Measurement string
+	// Tags are sorted by key
+	Tags   []Tag
+	Fields []Field
+	// Time is zero when the line has no timestamp
+	Time time.Time
+}
+
+// ParsePrecision parses the precision of the timestamps, an empty precision
+// means nanoseconds.
+func ParsePrecision(precision string) (time.Duration, error) {
+	switch precision {
+	case "", "ns", "n":
+		return time.Nanosecond, nil
+	case "us", "u":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	default:
+		return 0, fmt.Errorf("invalid precision: %s", precision)
+	}
+}
+
+// Parse parses one line, the comments and the empty lines must be skipped by
+// the caller.
+func Parse(line string, precision time.Duration) (Line, error) {
+	var result Line
+
+	measurement, rest, sep, err := scanKey(line, ", ")
+	if err != nil {
+		return Line{}, err
+	}
+	if measurement == "" {
+		return Line{}, errors.New("measurement is empty")
+	}
+	result.Measurement = measurement
+
+	for sep == ',' {
+		var tag Tag
+		tag.Key, rest, sep, err = scanKey(rest, "=")
+		if err != nil {
+			return Line{}, err
+		}
+		if tag.Key == "" || sep != '=' {
+			return Line{}, errors.New("invalid tag")
+		}
+		tag.Value, rest, sep, err = scanKey(rest, ", ")
+		if err != nil {
+			return Line{}, err
+		}
+		if tag.Value == "" {
+			return Line{}, fmt.Errorf("tag %s: value is empty", tag.Key)
+		}
+		result.Tags = append(result.Tags, tag)
+	}
+	sort.SliceStable(result.Tags, func(i, j int) bool {
+		return result.Tags[i].Key < result.Tags[j].Key
+	})
+
+	rest = strings.TrimLeft(rest, " ")
+	if rest == "" {
+		return Line{}, errors.New("no fields")
+	}
+
+	for {
+		var field Field
+		field.Key, rest, sep, err = scanKey(rest, "=")
+		if err != nil {
+			return Line{}, err
+		}
+		if field.Key == "" || sep != '=' {
+			return Line{}, errors.New("invalid field")
+		}
+
+		var value string
+		value, rest, sep, err = scanFieldValue(rest)
+		if err != nil {
+			return Line{}, fmt.Errorf("field %s: %s", field.Key, err)
+		}
+		if err := parseFieldValue(&field, value); err != nil {
+			return Line{}, fmt.Errorf("field %s: %s", field.Key, err)
+		}
+		result.Fields = append(result.Fields, field)
+
+		if sep != ',' {
+			break
+		}
+	}
+
+	rest = strings.TrimSpace(rest)
+	if rest != "" {
+		timestamp, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil {
+			return Line{}, fmt.Errorf("invalid timestamp: %s", rest)
+		}
+		result.Time = time.Unix(0, timestamp*int64(precision)).UTC()
+	}
+
+	return result, nil
+}
+
+// scanKey reads an escaped measurement, tag or field key up to one of the
+// separators. It returns the unescaped key, the rest after the separator and
+// the separator, 0 at the end of the line.
+func scanKey(s string, separators string) (string, string, byte, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(`,= \`, s[i+1]) >= 0:
+			i++
+			b.WriteByte(s[i])
+		case strings.IndexByte(separators, c) >= 0:
+			return b.String(), s[i+1:], c, nil
+		case c == ',' || c == ' ':
+			return "", "", 0, fmt.Errorf("unexpected %q", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), "", 0, nil
+}
+
+// scanFieldValue reads a field value up to a comma or a space, the string
+// values keep their quotes.
+func scanFieldValue(s string) (string, string, byte, error) {
+	if strings.HasPrefix(s, `"`) {
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				if i+1 == len(s) {
+					return s, "", 0, nil
+				}
+				if s[i+1] != ',' && s[i+1] != ' ' {
+					return "", "", 0, errors.New("invalid string")
+				}
+				return s[:i+1], s[i+2:], s[i+1], nil
+			}
+		}
+		return "", "", 0, errors.New("unterminated string")
+	}
+
+	i := strings.IndexAny(s, ", ")
+	if i < 0 {
+		return s, "", 0, nil
+	}
+	return s[:i], s[i+1:], s[i], nil
+}
+
+func parseFieldValue(field *Field, value string) error {
+	if value == "" {
+		return errors.New("value is empty")
+	}
+
+	switch {
+	case value[0] == '"':
+		field.Type = FieldString
+		field.String = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(value[1 : len(value)-1])
+		return nil
+	case strings.HasSuffix(value, "i"):
+		v, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+		if err != nil {
+			return errors.New("invalid integer")
+		}
+		field.Type = FieldInteger
+		field.Integer = v
+		return nil
+	case strings.HasSuffix(value, "u"):
+		v, err := strconv.ParseUint(value[:len(value)-1], 10, 63)
+		if err != nil {
+			return errors.New("invalid unsigned integer")
+		}
+		field.Type = FieldUnsigned
+		field.Integer = int64(v)
+		return nil
+	}
+
+	switch value {
+	case "t", "T", "true", "True", "TRUE":
+		field.Type = FieldBoolean
+		field.Float = 1
+		return nil
+	case "f", "F", "false", "False", "FALSE":
+		field.Type = FieldBoolean
+		field.Float = 0
+		return nil
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return errors.New("invalid float")
+	}
+	field.Type = FieldFloat
+	field.Float = v
+	return nil
+}
@@ -0,0 +1,105 @@
+package lineprotocol_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c2pc/go-musthave-metrics/internal/lineprotocol"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		precision time.Duration
+		want      lineprotocol.Line
+		wantErr   bool
+	}{
+		{
+			name: "Minimal",
+			line: "cpu value=0.5",
+			want: lineprotocol.Line{Measurement: "cpu", Fields: []lineprotocol.Field{{Key: "value", Type: lineprotocol.FieldFloat, Float: 0.5}}},
+		},
+		{
+			name:      "Tags fields and timestamp",
+			line:      "cpu,region=eu,host=a usage=1e2,count=3i,free=4u,up=t,state=\"ok \\\"fine\\\"\" 1704067200",
+			precision: time.Second,
+			want: lineprotocol.Line{
+				Measurement: "cpu",
+				Tags:        []lineprotocol.Tag{{Key: "host", Value: "a"}, {Key: "region", Value: "eu"}},
+				Fields: []lineprotocol.Field{
+					{Key: "usage", Type: lineprotocol.FieldFloat, Float: 100},
+					{Key: "count", Type: lineprotocol.FieldInteger, Integer: 3},
+					{Key: "free", Type: lineprotocol.FieldUnsigned, Integer: 4},
+					{Key: "up", Type: lineprotocol.FieldBoolean, Float: 1},
+					{Key: "state", Type: lineprotocol.FieldString, String: `ok "fine"`},
+				},
+				Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:      "Escapes",
+			line:      `disk\ io,path=/var\,log used\=bytes=-1 1704067200000000000`,
+			precision: time.Nanosecond,
+			want: lineprotocol.Line{
+				Measurement: "disk io",
+				Tags:        []lineprotocol.Tag{{Key: "path", Value: "/var,log"}},
+				Fields:      []lineprotocol.Field{{Key: "used=bytes", Type: lineprotocol.FieldFloat, Float: -1}},
+				Time:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{name: "No fields", line: "cpu,host=a", wantErr: true},
+		{name: "Empty measurement", line: ",host=a value=1", wantErr: true},
+		{name: "Empty tag value", line: "cpu,host= value=1", wantErr: true},
+		{name: "Invalid tag", line: "cpu,host value=1", wantErr: true},
+		{name: "Invalid field", line: "cpu value", wantErr: true},
+		{name: "Empty field value", line: "cpu value=", wantErr: true},
+		{name: "Invalid float", line: "cpu value=abc", wantErr: true},
+		{name: "NaN", line: "cpu value=NaN", wantErr: true},
+		{name: "Invalid integer", line: "cpu value=1.5i", wantErr: true},
+		{name: "Negative unsigned", line: "cpu value=-1u", wantErr: true},
+		{name: "Unterminated string", line: `cpu value="abc`, wantErr: true},
+		{name: "Invalid timestamp", line: "cpu value=1 yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lineprotocol.Parse(tt.line, tt.precision)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParsePrecision(t *testing.T) {
+	tests := []struct {
+		precision string
+		want      time.Duration
+		wantErr   bool
+	}{
+		{precision: "", want: time.Nanosecond},
+		{precision: "ns", want: time.Nanosecond},
+		{precision: "us", want: time.Microsecond},
+		{precision: "ms", want: time.Millisecond},
+		{precision: "s", want: time.Second},
+		{precision: "h", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.precision, func(t *testing.T) {
+			got, err := lineprotocol.ParsePrecision(tt.precision)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
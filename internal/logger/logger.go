@@ -4,7 +4,10 @@ import (
 	"go.uber.org/zap"
 )
 
-var Log = Logger{zap.NewNop()}
+var (
+	Log         = Logger{zap.NewNop()}
+	atomicLevel = zap.NewAtomicLevel()
+)
 
 type Logger struct {
 	logger *zap.Logger
@@ -18,7 +21,8 @@ func Initialize(level string) error {
 
 	cfg := zap.NewProductionConfig()
 
-	cfg.Level = lvl
+	atomicLevel.SetLevel(lvl.Level())
+	cfg.Level = atomicLevel
 
 	zl, err := cfg.Build()
 	if err != nil {
@@ -30,6 +34,18 @@ func Initialize(level string) error {
 	return nil
 }
 
+// SetLevel changes the level of the initialized logger.
+func SetLevel(lvl string) error {
+	l, err := zap.ParseAtomicLevel(lvl)
+	if err != nil {
+		return err
+	}
+
+	atomicLevel.SetLevel(l.Level())
+
+	return nil
+}
+
 func (log *Logger) Sync() error {
 	return log.logger.Sync()
 }
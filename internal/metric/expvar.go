@@ -0,0 +1,207 @@
+package metric
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c2pc/go-musthave-metrics/internal/logger"
+	"github.com/c2pc/go-musthave-metrics/internal/reporter"
+)
+
+const (
+	expvarMemStatsKey     = "memstats"
+	expvarRequestTimeout  = 1 * time.Second
+	nameSeparator         = "."
+	expvarEndpointDivider = "="
+)
+
+type ExpvarEndpoint struct {
+	Name string
+	URL  string
+}
+
+// ParseExpvarEndpoint parses an endpoint given as "name=url" or just "url".
+// Without a name the host of the URL is used as the metric prefix.
+func ParseExpvarEndpoint(s string) (ExpvarEndpoint, error) {
+	var name, rawURL string
+	if i := strings.Index(s, expvarEndpointDivider); i > 0 && !strings.Contains(s[:i], "/") {
+		name, rawURL = s[:i], s[i+1:]
+	} else {
+		rawURL = s
+	}
+
+	if !strings.Contains(rawURL, "http") {
+		rawURL = "http://" + rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ExpvarEndpoint{}, err
+	}
+	if u.Host == "" {
+		return ExpvarEndpoint{}, fmt.Errorf("invalid expvar endpoint: %s", s)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/debug/vars"
+	}
+
+	if name == "" {
+		name = u.Host
+	}
+
+	return ExpvarEndpoint{Name: name, URL: u.String()}, nil
+}
+
+type ExpvarConfig struct {
+	Endpoints []ExpvarEndpoint
+	Include   []string
+	Exclude   []string
+}
+
+type ExpvarMetric struct {
+	mu     *sync.Mutex
+	stats  map[string]float64
+	cfg    ExpvarConfig
+	client *http.Client
+}
+
+func NewExpvarMetric(cfg ExpvarConfig) reporter.MetricReader[float64] {
+	return &ExpvarMetric{
+		mu:     &sync.Mutex{},
+		stats:  make(map[string]float64),
+		cfg:    cfg,
+		client: &http.Client{Timeout: expvarRequestTimeout},
+	}
+}
+
+func (m *ExpvarMetric) GetName() string {
+	return "gauge"
+}
+
+func (m *ExpvarMetric) GetStats() map[string]float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make(map[string]float64, len(m.stats))
+	for k, v := range m.stats {
+		stats[k] = v
+	}
+
+	return stats
+}
+
+func (m *ExpvarMetric) PollStats() {
+	stats := make(map[string]float64)
+	for _, endpoint := range m.cfg.Endpoints {
+		vars, err := m.fetch(endpoint.URL)
+		if err != nil {
+			logger.Log.Info("Error fetching expvar endpoint", logger.Any("url", endpoint.URL), logger.Error(err))
+			continue
+		}
+
+		for key, value := range m.flatten(vars) {
+			stats[endpoint.Name+nameSeparator+key] = value
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats = stats
+}
+
+func (m *ExpvarMetric) fetch(endpoint string) (map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), expvarRequestTimeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := m.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	var vars map[string]interface{}
+	if err := json.NewDecoder(response.Body).Decode(&vars); err != nil {
+		return nil, err
+	}
+
+	return vars, nil
+}
+
+// flatten walks the expvar document and returns its numeric leaves keyed by
+// their dotted path. The memstats section is named like GaugeMetric keys.
+func (m *ExpvarMetric) flatten(vars map[string]interface{}) map[string]float64 {
+	result := make(map[string]float64)
+
+	for key, value := range vars {
+		if key == expvarMemStatsKey {
+			memStats, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for name, v := range memStats {
+				number, ok := v.(float64)
+				if !ok || !m.isAllowed(key+nameSeparator+name) {
+					continue
+				}
+				result[name] = number
+			}
+			continue
+		}
+
+		m.walk(key, value, result)
+	}
+
+	return result
+}
+
+func (m *ExpvarMetric) walk(prefix string, value interface{}, result map[string]float64) {
+	switch v := value.(type) {
+	case float64:
+		if m.isAllowed(prefix) {
+			result[prefix] = v
+		}
+	case map[string]interface{}:
+		for key, child := range v {
+			m.walk(prefix+nameSeparator+key, child, result)
+		}
+	case []interface{}:
+		for i, child := range v {
+			m.walk(prefix+nameSeparator+strconv.Itoa(i), child, result)
+		}
+	}
+}
+
+func (m *ExpvarMetric) isAllowed(name string) bool {
+	if len(m.cfg.Include) > 0 && !matchAny(m.cfg.Include, name) {
+		return false
+	}
+
+	return !matchAny(m.cfg.Exclude, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
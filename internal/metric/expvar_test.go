@@ -0,0 +1,126 @@
+package metric_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/metric"
+)
+
+const expvarBody = `{
+	"cmdline": ["/bin/app", "-v"],
+	"requests": 42,
+	"handlers": {"users": {"hits": 7, "errors": 1}, "name": "api"},
+	"memstats": {"Alloc": 1024, "HeapAlloc": 2048, "PauseNs": [1, 2, 3]}
+}`
+
+func TestExpvarMetric_GetName(t *testing.T) {
+	expvarMetric := metric.NewExpvarMetric(metric.ExpvarConfig{})
+
+	if expvarMetric.GetName() != "gauge" {
+		t.Error("Expvar metric name not set properly")
+	}
+}
+
+func TestExpvarMetric_PollStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/debug/vars", r.URL.Path)
+		_, _ = w.Write([]byte(expvarBody))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    map[string]float64
+	}{
+		{
+			name: "All numeric leaves",
+			want: map[string]float64{
+				"app.requests":              42,
+				"app.handlers.users.hits":   7,
+				"app.handlers.users.errors": 1,
+				"app.Alloc":                 1024,
+				"app.HeapAlloc":             2048,
+			},
+		},
+		{
+			name:    "Include",
+			include: []string{"memstats.*"},
+			want: map[string]float64{
+				"app.Alloc":     1024,
+				"app.HeapAlloc": 2048,
+			},
+		},
+		{
+			name:    "Exclude",
+			exclude: []string{"handlers.*.*", "memstats.Alloc"},
+			want: map[string]float64{
+				"app.requests":  42,
+				"app.HeapAlloc": 2048,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint, err := metric.ParseExpvarEndpoint("app=" + server.URL)
+			require.NoError(t, err)
+
+			expvarMetric := metric.NewExpvarMetric(metric.ExpvarConfig{
+				Endpoints: []metric.ExpvarEndpoint{endpoint},
+				Include:   tt.include,
+				Exclude:   tt.exclude,
+			})
+
+			expvarMetric.PollStats()
+			assert.Equal(t, tt.want, expvarMetric.GetStats())
+		})
+	}
+}
+
+func TestExpvarMetric_PollStats_Unavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	endpoint, err := metric.ParseExpvarEndpoint(server.URL)
+	require.NoError(t, err)
+
+	expvarMetric := metric.NewExpvarMetric(metric.ExpvarConfig{Endpoints: []metric.ExpvarEndpoint{endpoint}})
+	expvarMetric.PollStats()
+
+	assert.Empty(t, expvarMetric.GetStats())
+}
+
+func TestParseExpvarEndpoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    metric.ExpvarEndpoint
+		wantErr bool
+	}{
+		{"Host only", "localhost:6060", metric.ExpvarEndpoint{Name: "localhost:6060", URL: "http://localhost:6060/debug/vars"}, false},
+		{"Named", "api=http://localhost:6060/vars", metric.ExpvarEndpoint{Name: "api", URL: "http://localhost:6060/vars"}, false},
+		{"Query", "http://localhost:6060/vars?a=b", metric.ExpvarEndpoint{Name: "localhost:6060", URL: "http://localhost:6060/vars?a=b"}, false},
+		{"Empty", "", metric.ExpvarEndpoint{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := metric.ParseExpvarEndpoint(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package metric
+
+import "os"
+
+// fileInode is not available on this platform, so rotation is detected by
+// truncation only.
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,403 @@
+package metric
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/c2pc/go-musthave-metrics/internal/logger"
+	"github.com/c2pc/go-musthave-metrics/internal/reporter"
+)
+
+const (
+	LogRuleCounter = "counter"
+	LogRuleGauge   = "gauge"
+)
+
+type LogRule struct {
+	Name    string
+	Pattern string
+	Type    string
+	Group   int
+}
+
+type LogFile struct {
+	Path  string
+	Rules []LogRule
+}
+
+type LogTailConfig struct {
+	Files     []LogFile
+	StatePath string
+}
+
+type logRule struct {
+	LogRule
+	regexp *regexp.Regexp
+}
+
+type logOffset struct {
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+}
+
+type tailedFile struct {
+	path   string
+	rules  []logRule
+	file   *os.File
+	inode  uint64
+	offset int64
+}
+
+// LogTail follows log files across rotation and truncation and turns the
+// lines matching its rules into counters and gauges. The read offsets are
+// saved to the state file once the counters have been reported, so a
+// restart neither recounts the reported lines nor drops the others.
+type LogTail struct {
+	mu        *sync.Mutex
+	files     []*tailedFile
+	statePath string
+	counters  map[string]int64
+	gauges    map[string]float64
+	// counting is set when a rule is a counter, the offsets are then saved
+	// when the counters are reset
+	counting bool
+}
+
+func NewLogTail(cfg LogTailConfig) (*LogTail, error) {
+	state, err := loadLogOffsets(cfg.StatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &LogTail{
+		mu:        &sync.Mutex{},
+		statePath: cfg.StatePath,
+		counters:  make(map[string]int64),
+		gauges:    make(map[string]float64),
+	}
+
+	for _, f := range cfg.Files {
+		rules := make([]logRule, len(f.Rules))
+		for i, rule := range f.Rules {
+			if rule.Name == "" {
+				return nil, fmt.Errorf("%s: rule %d has no name", f.Path, i)
+			}
+
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s: rule %s: %w", f.Path, rule.Name, err)
+			}
+
+			switch rule.Type {
+			case LogRuleCounter:
+				t.counting = true
+			case LogRuleGauge:
+				if rule.Group == 0 {
+					rule.Group = 1
+				}
+				if rule.Group > re.NumSubexp() {
+					return nil, fmt.Errorf("%s: rule %s: no capture group %d", f.Path, rule.Name, rule.Group)
+				}
+			default:
+				return nil, fmt.Errorf("%s: rule %s: invalid type %q", f.Path, rule.Name, rule.Type)
+			}
+
+			rules[i] = logRule{LogRule: rule, regexp: re}
+		}
+
+		path, err := filepath.Abs(f.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		tf := &tailedFile{path: path, rules: rules}
+		if err := tf.open(state[path], true); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		t.files = append(t.files, tf)
+	}
+
+	return t, nil
+}
+
+// Counters returns the reader that polls the files, its reset saves the
+// read offsets.
+func (t *LogTail) Counters() reporter.MetricReader[int64] {
+	return &logCounterMetric{tail: t}
+}
+
+// Gauges returns the reader of the gauges found by the polls of Counters,
+// both readers belong to the same collector.
+func (t *LogTail) Gauges() reporter.MetricReader[float64] {
+	return &logGaugeMetric{tail: t}
+}
+
+func (t *LogTail) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var errs []error
+	for _, f := range t.files {
+		if f.file != nil {
+			errs = append(errs, f.file.Close())
+			f.file = nil
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (t *LogTail) poll() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, f := range t.files {
+		if err := f.poll(t.apply); err != nil {
+			logger.Log.Info("Error tailing log file", logger.Any("path", f.path), logger.Error(err))
+		}
+	}
+
+	// the gauges are not lost when the lines are read again, so there is
+	// nothing to wait for without counters
+	if !t.counting {
+		t.saveOffsets()
+	}
+}
+
+func (t *LogTail) apply(rules []logRule, line string) {
+	for _, rule := range rules {
+		switch rule.Type {
+		case LogRuleCounter:
+			if rule.regexp.MatchString(line) {
+				t.counters[rule.Name]++
+			}
+		case LogRuleGauge:
+			match := rule.regexp.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			value, err := strconv.ParseFloat(match[rule.Group], 64)
+			if err != nil {
+				continue
+			}
+			t.gauges[rule.Name] = value
+		}
+	}
+}
+
+// saveOffsets writes the read offsets to the state file, a failure is only
+// logged and the offsets are saved again after the next report.
+func (t *LogTail) saveOffsets() {
+	if err := t.writeOffsets(); err != nil {
+		logger.Log.Info("Error saving log offsets", logger.Error(err))
+	}
+}
+
+func (t *LogTail) writeOffsets() error {
+	if t.statePath == "" {
+		return nil
+	}
+
+	state := make(map[string]logOffset, len(t.files))
+	for _, f := range t.files {
+		if f.file != nil {
+			state[f.path] = logOffset{Inode: f.inode, Offset: f.offset}
+		}
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp := t.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, t.statePath)
+}
+
+func loadLogOffsets(path string) (map[string]logOffset, error) {
+	state := make(map[string]logOffset)
+	if path == "" {
+		return state, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse log offsets: %w", err)
+	}
+
+	return state, nil
+}
+
+// open opens the file at its path. A saved offset is reused only when the
+// inode still matches; a file seen for the first time is read from the end
+// at startup and from the beginning after a rotation.
+func (f *tailedFile) open(saved logOffset, startup bool) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	f.file = file
+	f.inode = fileInode(info)
+	f.offset = 0
+
+	switch {
+	case saved.Inode != 0 && saved.Inode == f.inode && saved.Offset <= info.Size():
+		f.offset = saved.Offset
+	case startup && saved.Inode == 0:
+		f.offset = info.Size()
+	}
+
+	return nil
+}
+
+func (f *tailedFile) poll(apply func([]logRule, string)) error {
+	if f.file == nil {
+		if err := f.open(logOffset{}, false); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	info, err := f.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < f.offset {
+		// truncated in place
+		f.offset = 0
+	}
+
+	if err := f.read(apply); err != nil {
+		return err
+	}
+
+	current, err := os.Stat(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if fileInode(current) != f.inode {
+		// rotated: the rest of the old file has been read above
+		_ = f.file.Close()
+		f.file = nil
+		if err := f.open(logOffset{}, false); err != nil {
+			return err
+		}
+		return f.read(apply)
+	}
+
+	return nil
+}
+
+// read applies the rules to every complete line after the offset. An
+// incomplete last line is left for the next poll.
+func (f *tailedFile) read(apply func([]logRule, string)) error {
+	if _, err := f.file.Seek(f.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(f.file)
+	for {
+		line, err := reader.ReadString('\n')
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		f.offset += int64(len(line))
+		apply(f.rules, strings.TrimRight(line, "\r\n"))
+	}
+}
+
+type logCounterMetric struct {
+	tail *LogTail
+}
+
+func (m *logCounterMetric) GetName() string {
+	return "counter"
+}
+
+func (m *logCounterMetric) PollStats() {
+	m.tail.poll()
+}
+
+func (m *logCounterMetric) GetStats() map[string]int64 {
+	m.tail.mu.Lock()
+	defer m.tail.mu.Unlock()
+
+	stats := make(map[string]int64, len(m.tail.counters))
+	for k, v := range m.tail.counters {
+		stats[k] = v
+	}
+
+	return stats
+}
+
+// ResetStats is called once the counters have been delivered, the lines
+// counted so far are not read again after a restart.
+func (m *logCounterMetric) ResetStats() {
+	m.tail.mu.Lock()
+	defer m.tail.mu.Unlock()
+
+	m.tail.counters = make(map[string]int64)
+	m.tail.saveOffsets()
+}
+
+type logGaugeMetric struct {
+	tail *LogTail
+}
+
+func (m *logGaugeMetric) GetName() string {
+	return "gauge"
+}
+
+// PollStats does nothing, the files are polled once per tick by the
+// counter reader.
+func (m *logGaugeMetric) PollStats() {}
+
+func (m *logGaugeMetric) GetStats() map[string]float64 {
+	m.tail.mu.Lock()
+	defer m.tail.mu.Unlock()
+
+	stats := make(map[string]float64, len(m.tail.gauges))
+	for k, v := range m.tail.gauges {
+		stats[k] = v
+	}
+
+	return stats
+}
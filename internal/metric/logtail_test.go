@@ -0,0 +1,172 @@
+package metric_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/metric"
+	"github.com/c2pc/go-musthave-metrics/internal/reporter"
+)
+
+var logRules = []metric.LogRule{
+	{Name: "errors", Pattern: "level=error", Type: metric.LogRuleCounter},
+	{Name: "latency", Pattern: `took=(\d+(\.\d+)?)ms`, Type: metric.LogRuleGauge},
+}
+
+func appendLog(t *testing.T, path string, text string) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(text)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func newLogTail(t *testing.T, dir string) *metric.LogTail {
+	logTail, err := metric.NewLogTail(metric.LogTailConfig{
+		Files:     []metric.LogFile{{Path: filepath.Join(dir, "app.log"), Rules: logRules}},
+		StatePath: filepath.Join(dir, "state", "offsets.json"),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = logTail.Close() })
+	return logTail
+}
+
+func TestNewLogTail_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		rule metric.LogRule
+	}{
+		{"Empty name", metric.LogRule{Pattern: "x", Type: metric.LogRuleCounter}},
+		{"Invalid pattern", metric.LogRule{Name: "x", Pattern: "(", Type: metric.LogRuleCounter}},
+		{"Invalid type", metric.LogRule{Name: "x", Pattern: "x", Type: "histogram"}},
+		{"Missing group", metric.LogRule{Name: "x", Pattern: "x", Type: metric.LogRuleGauge}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := metric.NewLogTail(metric.LogTailConfig{
+				Files: []metric.LogFile{{Path: filepath.Join(t.TempDir(), "app.log"), Rules: []metric.LogRule{tt.rule}}},
+			})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLogTail_PollStats(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendLog(t, path, "level=error old line before start\n")
+
+	logTail := newLogTail(t, dir)
+	counters, gauges := logTail.Counters(), logTail.Gauges()
+
+	assert.Equal(t, "counter", counters.GetName())
+	assert.Equal(t, "gauge", gauges.GetName())
+
+	tests := []struct {
+		name     string
+		write    func()
+		reset    bool
+		counters map[string]int64
+		gauges   map[string]float64
+	}{
+		{
+			name:     "Existing content is skipped",
+			write:    func() {},
+			counters: map[string]int64{},
+			gauges:   map[string]float64{},
+		},
+		{
+			name: "New lines",
+			write: func() {
+				appendLog(t, path, "level=error a\nlevel=info took=12ms\nlevel=error took=3.5ms\n")
+			},
+			counters: map[string]int64{"errors": 2},
+			gauges:   map[string]float64{"latency": 3.5},
+		},
+		{
+			name: "Incomplete line is kept",
+			write: func() {
+				appendLog(t, path, "level=error partial")
+			},
+			counters: map[string]int64{"errors": 2},
+			gauges:   map[string]float64{"latency": 3.5},
+		},
+		{
+			name: "Completed line",
+			write: func() {
+				appendLog(t, path, " took=7ms\n")
+			},
+			reset:    true,
+			counters: map[string]int64{"errors": 1},
+			gauges:   map[string]float64{"latency": 7},
+		},
+		{
+			name: "Truncation",
+			write: func() {
+				require.NoError(t, os.Truncate(path, 0))
+				appendLog(t, path, "level=error after truncate\n")
+			},
+			reset:    true,
+			counters: map[string]int64{"errors": 1},
+			gauges:   map[string]float64{"latency": 7},
+		},
+		{
+			name: "Rotation",
+			write: func() {
+				appendLog(t, path, "level=error before rotate\n")
+				require.NoError(t, os.Rename(path, path+".1"))
+				appendLog(t, path, "level=error after rotate took=1ms\n")
+			},
+			reset:    true,
+			counters: map[string]int64{"errors": 2},
+			gauges:   map[string]float64{"latency": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.reset {
+				counters.(reporter.StatsResetter).ResetStats()
+			}
+
+			tt.write()
+			counters.PollStats()
+			gauges.PollStats()
+
+			assert.Equal(t, tt.counters, counters.GetStats())
+			assert.Equal(t, tt.gauges, gauges.GetStats())
+		})
+	}
+}
+
+func TestLogTail_Restart(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendLog(t, path, "")
+
+	logTail := newLogTail(t, dir)
+	appendLog(t, path, "level=error one\nlevel=error two\n")
+	logTail.Counters().PollStats()
+	assert.Equal(t, map[string]int64{"errors": 2}, logTail.Counters().GetStats())
+	logTail.Counters().(reporter.StatsResetter).ResetStats()
+	require.NoError(t, logTail.Close())
+
+	appendLog(t, path, "level=error three\n")
+
+	// the counts of the last poll were not reported before the restart
+	unreported := newLogTail(t, dir)
+	unreported.Counters().PollStats()
+	assert.Equal(t, map[string]int64{"errors": 1}, unreported.Counters().GetStats())
+	require.NoError(t, unreported.Close())
+
+	appendLog(t, path, "level=error four\n")
+
+	restarted := newLogTail(t, dir)
+	restarted.Counters().PollStats()
+	assert.Equal(t, map[string]int64{"errors": 2}, restarted.Counters().GetStats())
+}
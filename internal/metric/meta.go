@@ -0,0 +1,41 @@
+package metric
+
+import "github.com/c2pc/go-musthave-metrics/internal/model"
+
+// RuntimeMeta describes the metrics of the runtime collector, the agent
+// sends it to the server on start.
+var RuntimeMeta = []model.MetricMeta{
+	gaugeMeta(GaugeAllocKey, "Allocated", "bytes", "Bytes of allocated heap objects"),
+	gaugeMeta(GaugeBuckHashSysKey, "Profiling bucket hash table", "bytes", "Bytes of memory in profiling bucket hash tables"),
+	gaugeMeta(GaugeFreesKey, "Frees", "objects", "Cumulative count of heap objects freed"),
+	gaugeMeta(GaugeGCCPUFractionKey, "GC CPU fraction", "ratio", "Fraction of the available CPU time used by the GC since the program started"),
+	gaugeMeta(GaugeGCSysKey, "GC metadata", "bytes", "Bytes of memory in garbage collection metadata"),
+	gaugeMeta(GaugeHeapAllocKey, "Heap allocated", "bytes", "Bytes of allocated heap objects"),
+	gaugeMeta(GaugeHeapIdleKey, "Heap idle", "bytes", "Bytes in idle (unused) heap spans"),
+	gaugeMeta(GaugeHeapInuseKey, "Heap in use", "bytes", "Bytes in in-use heap spans"),
+	gaugeMeta(GaugeHeapObjectsKey, "Heap objects", "objects", "Number of allocated heap objects"),
+	gaugeMeta(GaugeHeapReleasedKey, "Heap released", "bytes", "Bytes of physical memory returned to the OS"),
+	gaugeMeta(GaugeHeapSysKey, "Heap obtained", "bytes", "Bytes of heap memory obtained from the OS"),
+	gaugeMeta(GaugeLastGCKey, "Last GC", "nanoseconds", "Time the last garbage collection finished, as nanoseconds since the Unix epoch"),
+	gaugeMeta(GaugeLookupsKey, "Pointer lookups", "lookups", "Number of pointer lookups performed by the runtime"),
+	gaugeMeta(GaugeMCacheInuseKey, "MCache in use", "bytes", "Bytes of allocated mcache structures"),
+	gaugeMeta(GaugeMCacheSysKey, "MCache obtained", "bytes", "Bytes of memory obtained from the OS for mcache structures"),
+	gaugeMeta(GaugeMSpanInuseKey, "MSpan in use", "bytes", "Bytes of allocated mspan structures"),
+	gaugeMeta(GaugeMSpanSysKey, "MSpan obtained", "bytes", "Bytes of memory obtained from the OS for mspan structures"),
+	gaugeMeta(GaugeMallocsKey, "Mallocs", "objects", "Cumulative count of heap objects allocated"),
+	gaugeMeta(GaugeNextGCKey, "Next GC target", "bytes", "Target heap size of the next GC cycle"),
+	gaugeMeta(GaugeNumForcedGCKey, "Forced GC cycles", "cycles", "Number of GC cycles forced by the application calling the GC function"),
+	gaugeMeta(GaugeNumGCKey, "GC cycles", "cycles", "Number of completed GC cycles"),
+	gaugeMeta(GaugeOtherSysKey, "Other runtime memory", "bytes", "Bytes of memory in miscellaneous off-heap runtime allocations"),
+	gaugeMeta(GaugePauseTotalNsKey, "GC pause total", "nanoseconds", "Cumulative nanoseconds in GC stop-the-world pauses"),
+	gaugeMeta(GaugeStackInuseKey, "Stack in use", "bytes", "Bytes in stack spans"),
+	gaugeMeta(GaugeStackSysKey, "Stack obtained", "bytes", "Bytes of stack memory obtained from the OS"),
+	gaugeMeta(GaugeSysKey, "Total obtained", "bytes", "Total bytes of memory obtained from the OS"),
+	gaugeMeta(GaugeTotalAllocKey, "Total allocated", "bytes", "Cumulative bytes allocated for heap objects"),
+	gaugeMeta(GaugeRandomValueKey, "Random value", "", "A random number in [0, 1) refreshed on every poll"),
+	{Type: "counter", ID: CounterPollCountKey, DisplayName: "Poll count", Unit: "polls", Description: "Number of times the agent polled the runtime metrics"},
+}
+
+func gaugeMeta(key string, displayName string, unit string, description string) model.MetricMeta {
+	return model.MetricMeta{Type: "gauge", ID: key, DisplayName: displayName, Unit: unit, Description: description}
+}
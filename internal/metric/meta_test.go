@@ -0,0 +1,30 @@
+package metric_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c2pc/go-musthave-metrics/internal/metric"
+)
+
+func TestRuntimeMeta(t *testing.T) {
+	described := make(map[string]bool)
+	for _, meta := range metric.RuntimeMeta {
+		assert.False(t, described[meta.Type+"/"+meta.ID], "%s is described twice", meta.ID)
+		described[meta.Type+"/"+meta.ID] = true
+		assert.NotEmpty(t, meta.Description, meta.ID)
+	}
+
+	gauges := metric.NewGaugeMetric()
+	gauges.PollStats()
+	for key := range gauges.GetStats() {
+		assert.True(t, described["gauge/"+key], "%s is not described", key)
+	}
+
+	counters := metric.NewCounterMetric()
+	counters.PollStats()
+	for key := range counters.GetStats() {
+		assert.True(t, described["counter/"+key], "%s is not described", key)
+	}
+}
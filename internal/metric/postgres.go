@@ -0,0 +1,278 @@
+package metric
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c2pc/go-musthave-metrics/internal/logger"
+	"github.com/c2pc/go-musthave-metrics/internal/reporter"
+)
+
+const (
+	postgresPrefix       = "postgres"
+	postgresQueryTimeout = 1 * time.Second
+)
+
+const (
+	postgresDatabaseQuery = `SELECT datname, xact_commit, xact_rollback, blks_read, blks_hit,
+       tup_returned, tup_fetched, tup_inserted, tup_updated, tup_deleted, deadlocks
+FROM pg_stat_database WHERE datname IS NOT NULL`
+	postgresActivityQuery = `SELECT COALESCE(datname, ''), COALESCE(state, 'unknown'), count(*)
+FROM pg_stat_activity GROUP BY 1, 2`
+	postgresReplicationQuery = `SELECT application_name, COALESCE(EXTRACT(EPOCH FROM replay_lag), 0)
+FROM pg_stat_replication`
+	postgresReplicaLagQuery = `SELECT CASE WHEN pg_is_in_recovery()
+    THEN COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) ELSE 0 END`
+)
+
+var postgresCounterColumns = []string{
+	"xact_commit", "xact_rollback", "blks_read", "blks_hit", "tup_returned",
+	"tup_fetched", "tup_inserted", "tup_updated", "tup_deleted", "deadlocks",
+}
+
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type PostgresGaugeMetric struct {
+	mu    *sync.Mutex
+	stats map[string]float64
+	db    Querier
+}
+
+func NewPostgresGaugeMetric(db Querier) reporter.MetricReader[float64] {
+	return &PostgresGaugeMetric{
+		mu:    &sync.Mutex{},
+		stats: make(map[string]float64),
+		db:    db,
+	}
+}
+
+func (m *PostgresGaugeMetric) GetName() string {
+	return "gauge"
+}
+
+func (m *PostgresGaugeMetric) GetStats() map[string]float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make(map[string]float64, len(m.stats))
+	for k, v := range m.stats {
+		stats[k] = v
+	}
+
+	return stats
+}
+
+func (m *PostgresGaugeMetric) PollStats() {
+	ctx, cancel := context.WithTimeout(context.Background(), postgresQueryTimeout)
+	defer cancel()
+
+	stats := make(map[string]float64)
+	for _, poll := range []func(context.Context, map[string]float64) error{
+		m.pollBackends,
+		m.pollActivity,
+		m.pollReplication,
+		m.pollReplicaLag,
+	} {
+		if err := poll(ctx, stats); err != nil {
+			logger.Log.Info("Error polling postgres gauges", logger.Error(err))
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats = stats
+}
+
+func (m *PostgresGaugeMetric) pollBackends(ctx context.Context, stats map[string]float64) error {
+	rows, err := m.db.QueryContext(ctx, `SELECT datname, numbackends FROM pg_stat_database WHERE datname IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var backends int64
+		if err := rows.Scan(&name, &backends); err != nil {
+			return err
+		}
+		stats[postgresKey(name, "numbackends")] = float64(backends)
+	}
+
+	return rows.Err()
+}
+
+func (m *PostgresGaugeMetric) pollActivity(ctx context.Context, stats map[string]float64) error {
+	rows, err := m.db.QueryContext(ctx, postgresActivityQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, state string
+		var count int64
+		if err := rows.Scan(&name, &state, &count); err != nil {
+			return err
+		}
+		stats[postgresKey(name, "activity", state)] += float64(count)
+	}
+
+	return rows.Err()
+}
+
+func (m *PostgresGaugeMetric) pollReplication(ctx context.Context, stats map[string]float64) error {
+	rows, err := m.db.QueryContext(ctx, postgresReplicationQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var lag float64
+		if err := rows.Scan(&name, &lag); err != nil {
+			return err
+		}
+		stats[postgresKey("replication", name, "replay_lag")] = lag
+	}
+
+	return rows.Err()
+}
+
+func (m *PostgresGaugeMetric) pollReplicaLag(ctx context.Context, stats map[string]float64) error {
+	rows, err := m.db.QueryContext(ctx, postgresReplicaLagQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var lag float64
+		if err := rows.Scan(&lag); err != nil {
+			return err
+		}
+		stats[postgresKey("replica_lag")] = lag
+	}
+
+	return rows.Err()
+}
+
+// PostgresCounterMetric reports the growth of the cumulative pg_stat_database
+// columns. Deltas are accumulated between polls until the reporter resets them.
+type PostgresCounterMetric struct {
+	mu    *sync.Mutex
+	stats map[string]int64
+	last  map[string]int64
+	db    Querier
+}
+
+func NewPostgresCounterMetric(db Querier) reporter.MetricReader[int64] {
+	return &PostgresCounterMetric{
+		mu:    &sync.Mutex{},
+		stats: make(map[string]int64),
+		last:  nil,
+		db:    db,
+	}
+}
+
+func (m *PostgresCounterMetric) GetName() string {
+	return "counter"
+}
+
+func (m *PostgresCounterMetric) GetStats() map[string]int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make(map[string]int64, len(m.stats))
+	for k, v := range m.stats {
+		stats[k] = v
+	}
+
+	return stats
+}
+
+func (m *PostgresCounterMetric) ResetStats() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats = make(map[string]int64)
+}
+
+func (m *PostgresCounterMetric) PollStats() {
+	ctx, cancel := context.WithTimeout(context.Background(), postgresQueryTimeout)
+	defer cancel()
+
+	current, err := m.pollDatabases(ctx)
+	if err != nil {
+		logger.Log.Info("Error polling postgres counters", logger.Error(err))
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.last != nil {
+		for key, value := range current {
+			last, ok := m.last[key]
+			if !ok {
+				continue
+			}
+			if value < last {
+				// statistics were reset on the server
+				last = 0
+			}
+			if delta := value - last; delta > 0 {
+				m.stats[key] += delta
+			}
+		}
+	}
+
+	m.last = current
+}
+
+func (m *PostgresCounterMetric) pollDatabases(ctx context.Context) (map[string]int64, error) {
+	rows, err := m.db.QueryContext(ctx, postgresDatabaseQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]int64)
+	for rows.Next() {
+		var name string
+		values := make([]int64, len(postgresCounterColumns))
+		dest := []interface{}{&name}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		for i, column := range postgresCounterColumns {
+			result[postgresKey(name, column)] = values[i]
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func postgresKey(parts ...string) string {
+	key := postgresPrefix
+	for _, part := range parts {
+		key += nameSeparator + strings.ReplaceAll(part, " ", "_")
+	}
+	return key
+}
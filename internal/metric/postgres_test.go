@@ -0,0 +1,173 @@
+package metric_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c2pc/go-musthave-metrics/internal/database"
+	"github.com/c2pc/go-musthave-metrics/internal/metric"
+	"github.com/c2pc/go-musthave-metrics/internal/reporter"
+)
+
+var postgresCounterColumns = []string{
+	"datname", "xact_commit", "xact_rollback", "blks_read", "blks_hit", "tup_returned",
+	"tup_fetched", "tup_inserted", "tup_updated", "tup_deleted", "deadlocks",
+}
+
+func TestPostgresGaugeMetric_GetName(t *testing.T) {
+	gaugeMetric := metric.NewPostgresGaugeMetric(nil)
+
+	if gaugeMetric.GetName() != "gauge" {
+		t.Error("Postgres gauge metric name not set properly")
+	}
+}
+
+func TestPostgresCounterMetric_GetName(t *testing.T) {
+	counterMetric := metric.NewPostgresCounterMetric(nil)
+
+	if counterMetric.GetName() != "counter" {
+		t.Error("Postgres counter metric name not set properly")
+	}
+}
+
+func TestPostgresGaugeMetric_PollStats(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	gaugeMetric := metric.NewPostgresGaugeMetric(&database.DB{DB: mockDB})
+
+	tests := []struct {
+		name    string
+		want    map[string]float64
+		mockgen func()
+	}{
+		{
+			name: "Success",
+			want: map[string]float64{
+				"postgres.app.numbackends":                  3,
+				"postgres.app.activity.active":              1,
+				"postgres.app.activity.idle_in_transaction": 2,
+				"postgres.replication.replica1.replay_lag":  0.5,
+				"postgres.replica_lag":                      0,
+			},
+			mockgen: func() {
+				mock.ExpectQuery("^SELECT datname, numbackends FROM pg_stat_database (.+)$").
+					WillReturnRows(sqlmock.NewRows([]string{"datname", "numbackends"}).AddRow("app", 3))
+				mock.ExpectQuery("^SELECT (.+) FROM pg_stat_activity GROUP BY (.+)$").
+					WillReturnRows(sqlmock.NewRows([]string{"datname", "state", "count"}).
+						AddRow("app", "active", 1).
+						AddRow("app", "idle in transaction", 2))
+				mock.ExpectQuery("^SELECT (.+) FROM pg_stat_replication$").
+					WillReturnRows(sqlmock.NewRows([]string{"application_name", "lag"}).AddRow("replica1", 0.5))
+				mock.ExpectQuery("^SELECT CASE WHEN pg_is_in_recovery(.+)$").
+					WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0))
+			},
+		},
+		{
+			name: "Partial error",
+			want: map[string]float64{
+				"postgres.app.numbackends": 4,
+				"postgres.replica_lag":     1.5,
+			},
+			mockgen: func() {
+				mock.ExpectQuery("^SELECT datname, numbackends FROM pg_stat_database (.+)$").
+					WillReturnRows(sqlmock.NewRows([]string{"datname", "numbackends"}).AddRow("app", 4))
+				mock.ExpectQuery("^SELECT (.+) FROM pg_stat_activity GROUP BY (.+)$").
+					WillReturnError(errors.New("some error"))
+				mock.ExpectQuery("^SELECT (.+) FROM pg_stat_replication$").
+					WillReturnError(errors.New("some error"))
+				mock.ExpectQuery("^SELECT CASE WHEN pg_is_in_recovery(.+)$").
+					WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(1.5))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockgen()
+
+			gaugeMetric.PollStats()
+			assert.Equal(t, tt.want, gaugeMetric.GetStats())
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPostgresCounterMetric_PollStats(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	counterMetric := metric.NewPostgresCounterMetric(&database.DB{DB: mockDB})
+
+	rows := func(commits, deadlocks int64) *sqlmock.Rows {
+		return sqlmock.NewRows(postgresCounterColumns).
+			AddRow("app", commits, 0, 0, 0, 0, 0, 0, 0, 0, deadlocks)
+	}
+
+	tests := []struct {
+		name    string
+		reset   bool
+		want    map[string]int64
+		mockgen func()
+	}{
+		{
+			name: "First poll sets baseline",
+			want: map[string]int64{},
+			mockgen: func() {
+				mock.ExpectQuery("^SELECT (.+) FROM pg_stat_database (.+)$").WillReturnRows(rows(100, 1))
+			},
+		},
+		{
+			name: "Delta",
+			want: map[string]int64{"postgres.app.xact_commit": 10},
+			mockgen: func() {
+				mock.ExpectQuery("^SELECT (.+) FROM pg_stat_database (.+)$").WillReturnRows(rows(110, 1))
+			},
+		},
+		{
+			name: "Accumulated delta",
+			want: map[string]int64{"postgres.app.xact_commit": 15, "postgres.app.deadlocks": 2},
+			mockgen: func() {
+				mock.ExpectQuery("^SELECT (.+) FROM pg_stat_database (.+)$").WillReturnRows(rows(115, 3))
+			},
+		},
+		{
+			name: "Error keeps stats",
+			want: map[string]int64{"postgres.app.xact_commit": 15, "postgres.app.deadlocks": 2},
+			mockgen: func() {
+				mock.ExpectQuery("^SELECT (.+) FROM pg_stat_database (.+)$").WillReturnError(errors.New("some error"))
+			},
+		},
+		{
+			name:  "Stats reset on server",
+			reset: true,
+			want:  map[string]int64{"postgres.app.xact_commit": 5},
+			mockgen: func() {
+				mock.ExpectQuery("^SELECT (.+) FROM pg_stat_database (.+)$").WillReturnRows(rows(5, 0))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.reset {
+				counterMetric.(reporter.StatsResetter).ResetStats()
+			}
+
+			tt.mockgen()
+
+			counterMetric.PollStats()
+			assert.Equal(t, tt.want, counterMetric.GetStats())
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
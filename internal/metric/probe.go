@@ -0,0 +1,228 @@
+package metric
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/c2pc/go-musthave-metrics/internal/logger"
+	"github.com/c2pc/go-musthave-metrics/internal/reporter"
+)
+
+const (
+	probePrefix         = "probe"
+	probeMaxBodySize    = 10 << 20
+	probeDefaultTimeout = 5 * time.Second
+)
+
+const (
+	ProbeUpKey                = "up"
+	ProbeLatencyKey           = "latency_ms"
+	ProbeCertDaysRemainingKey = "cert_days_remaining"
+	ProbeResponseSizeKey      = "response_size"
+)
+
+type ProbeCheck struct {
+	Name           string
+	URL            string
+	Method         string
+	ExpectedStatus int
+	BodyRegex      string
+	Timeout        time.Duration
+	SkipTLSVerify  bool
+}
+
+type probe struct {
+	ProbeCheck
+	bodyRegex *regexp.Regexp
+	client    *http.Client
+}
+
+func newProbe(check ProbeCheck) (*probe, error) {
+	u, err := url.Parse(check.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, errors.New("invalid probe url: " + check.URL)
+	}
+
+	p := &probe{ProbeCheck: check}
+	if p.Name == "" {
+		p.Name = u.Host
+	}
+	if p.Method == "" {
+		p.Method = http.MethodGet
+	}
+	if p.ExpectedStatus == 0 {
+		p.ExpectedStatus = http.StatusOK
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = probeDefaultTimeout
+	}
+	if p.BodyRegex != "" {
+		p.bodyRegex, err = regexp.Compile(p.BodyRegex)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	p.client = &http.Client{
+		Timeout: p.Timeout,
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: p.SkipTLSVerify},
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return p, nil
+}
+
+// ProbeMetric runs the checks in the background so that PollStats never
+// waits for a slow target. A new round starts only when the previous one
+// has finished.
+type ProbeMetric struct {
+	mu          *sync.Mutex
+	stats       map[string]float64
+	probes      []*probe
+	concurrency int
+	running     atomic.Bool
+}
+
+func NewProbeMetric(checks []ProbeCheck, concurrency int) (reporter.MetricReader[float64], error) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	probes := make([]*probe, len(checks))
+	for i, check := range checks {
+		p, err := newProbe(check)
+		if err != nil {
+			return nil, err
+		}
+		probes[i] = p
+	}
+
+	return &ProbeMetric{
+		mu:          &sync.Mutex{},
+		stats:       make(map[string]float64),
+		probes:      probes,
+		concurrency: concurrency,
+	}, nil
+}
+
+func (m *ProbeMetric) GetName() string {
+	return "gauge"
+}
+
+func (m *ProbeMetric) GetStats() map[string]float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make(map[string]float64, len(m.stats))
+	for k, v := range m.stats {
+		stats[k] = v
+	}
+
+	return stats
+}
+
+func (m *ProbeMetric) PollStats() {
+	if !m.running.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer m.running.Store(false)
+		m.runChecks()
+	}()
+}
+
+func (m *ProbeMetric) runChecks() {
+	semaphore := make(chan struct{}, m.concurrency)
+	waitGroup := sync.WaitGroup{}
+
+	for _, p := range m.probes {
+		semaphore <- struct{}{}
+		waitGroup.Add(1)
+
+		go func() {
+			defer waitGroup.Done()
+			defer func() { <-semaphore }()
+
+			stats := m.runCheck(p)
+
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			prefix := probePrefix + nameSeparator + p.Name + nameSeparator
+			delete(m.stats, prefix+ProbeCertDaysRemainingKey)
+			for key, value := range stats {
+				m.stats[prefix+key] = value
+			}
+		}()
+	}
+
+	waitGroup.Wait()
+}
+
+func (m *ProbeMetric) runCheck(check *probe) map[string]float64 {
+	stats := map[string]float64{
+		ProbeUpKey:           0,
+		ProbeLatencyKey:      0,
+		ProbeResponseSizeKey: 0,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), check.Timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, check.Method, check.URL, nil)
+	if err != nil {
+		logger.Log.Info("Error creating probe request", logger.Any("probe", check.Name), logger.Error(err))
+		return stats
+	}
+
+	start := time.Now()
+	response, err := check.client.Do(request)
+	if err != nil {
+		stats[ProbeLatencyKey] = float64(time.Since(start).Milliseconds())
+		logger.Log.Info("Probe failed", logger.Any("probe", check.Name), logger.Error(err))
+		return stats
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, probeMaxBodySize))
+	stats[ProbeLatencyKey] = float64(time.Since(start).Milliseconds())
+	stats[ProbeResponseSizeKey] = float64(len(body))
+
+	if response.TLS != nil && len(response.TLS.PeerCertificates) > 0 {
+		stats[ProbeCertDaysRemainingKey] = time.Until(response.TLS.PeerCertificates[0].NotAfter).Hours() / 24
+	}
+
+	if err != nil {
+		logger.Log.Info("Probe failed to read body", logger.Any("probe", check.Name), logger.Error(err))
+		return stats
+	}
+
+	if response.StatusCode != check.ExpectedStatus {
+		return stats
+	}
+
+	if check.bodyRegex != nil && !check.bodyRegex.Match(body) {
+		return stats
+	}
+
+	stats[ProbeUpKey] = 1
+
+	return stats
+}
@@ -0,0 +1,132 @@
+package metric_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/metric"
+	"github.com/c2pc/go-musthave-metrics/internal/reporter"
+)
+
+func waitProbeStats(t *testing.T, probeMetric reporter.MetricReader[float64], key string) map[string]float64 {
+	var stats map[string]float64
+	require.Eventually(t, func() bool {
+		stats = probeMetric.GetStats()
+		_, ok := stats[key]
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	return stats
+}
+
+func TestProbeMetric_GetName(t *testing.T) {
+	probeMetric, err := metric.NewProbeMetric(nil, 1)
+	require.NoError(t, err)
+
+	if probeMetric.GetName() != "gauge" {
+		t.Error("Probe metric name not set properly")
+	}
+}
+
+func TestNewProbeMetric_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		check metric.ProbeCheck
+	}{
+		{"Empty url", metric.ProbeCheck{}},
+		{"Invalid url", metric.ProbeCheck{URL: "localhost"}},
+		{"Invalid regex", metric.ProbeCheck{URL: "http://localhost", BodyRegex: "("}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := metric.NewProbeMetric([]metric.ProbeCheck{tt.check}, 1)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestProbeMetric_PollStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			_, _ = w.Write([]byte("status: ok"))
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name  string
+		check metric.ProbeCheck
+		up    float64
+		size  float64
+	}{
+		{"Up", metric.ProbeCheck{Name: "ok", URL: server.URL + "/ok"}, 1, 10},
+		{"Body matches", metric.ProbeCheck{Name: "ok", URL: server.URL + "/ok", BodyRegex: "status: (ok|degraded)"}, 1, 10},
+		{"Body mismatch", metric.ProbeCheck{Name: "ok", URL: server.URL + "/ok", BodyRegex: "^down$"}, 0, 10},
+		{"Expected status", metric.ProbeCheck{Name: "created", URL: server.URL + "/created", Method: http.MethodPost, ExpectedStatus: http.StatusCreated}, 1, 0},
+		{"Unexpected status", metric.ProbeCheck{Name: "fail", URL: server.URL + "/fail"}, 0, 0},
+		{"Unreachable", metric.ProbeCheck{Name: "down", URL: "http://127.0.0.1:1", Timeout: time.Second}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probeMetric, err := metric.NewProbeMetric([]metric.ProbeCheck{tt.check}, 1)
+			require.NoError(t, err)
+
+			probeMetric.PollStats()
+
+			prefix := "probe." + tt.check.Name + "."
+			stats := waitProbeStats(t, probeMetric, prefix+metric.ProbeUpKey)
+			assert.Equal(t, tt.up, stats[prefix+metric.ProbeUpKey])
+			assert.Equal(t, tt.size, stats[prefix+metric.ProbeResponseSizeKey])
+			assert.Contains(t, stats, prefix+metric.ProbeLatencyKey)
+			assert.NotContains(t, stats, prefix+metric.ProbeCertDaysRemainingKey)
+		})
+	}
+}
+
+func TestProbeMetric_PollStats_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	probeMetric, err := metric.NewProbeMetric([]metric.ProbeCheck{{Name: "tls", URL: server.URL, SkipTLSVerify: true}}, 1)
+	require.NoError(t, err)
+
+	probeMetric.PollStats()
+
+	stats := waitProbeStats(t, probeMetric, "probe.tls."+metric.ProbeUpKey)
+	assert.Equal(t, float64(1), stats["probe.tls."+metric.ProbeUpKey])
+	assert.Greater(t, stats["probe.tls."+metric.ProbeCertDaysRemainingKey], float64(0))
+}
+
+func TestProbeMetric_PollStats_NonBlocking(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	checks := []metric.ProbeCheck{
+		{Name: "slow1", URL: server.URL},
+		{Name: "slow2", URL: server.URL},
+		{Name: "slow3", URL: server.URL},
+	}
+	probeMetric, err := metric.NewProbeMetric(checks, 2)
+	require.NoError(t, err)
+
+	start := time.Now()
+	probeMetric.PollStats()
+	probeMetric.PollStats()
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Empty(t, probeMetric.GetStats())
+}
@@ -0,0 +1,15 @@
+package model
+
+// Headers identifying a batch of metrics sent to /updates/. A batch that
+// carries both is applied at most once.
+const (
+	AgentIDHeader       = "X-Agent-ID"
+	BatchSequenceHeader = "X-Batch-Sequence"
+)
+
+// BatchAck answers a batch sent over the streaming connection.
+type BatchAck struct {
+	Sequence int64  `json:"sequence"`
+	Status   int    `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// MetricEvent is sent by the /api/v1/stream event stream for every accepted
+// update. Gauges carry their new value, counters the delta added and the
+// total after the update.
+type MetricEvent struct {
+	ID    string    `json:"id"`
+	Type  string    `json:"type"`
+	Value *float64  `json:"value,omitempty"`
+	Delta *int64    `json:"delta,omitempty"`
+	Total *int64    `json:"total,omitempty"`
+	Time  time.Time `json:"time"`
+}
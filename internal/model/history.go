@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+type HistoryPoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+	Min   *float64  `json:"min,omitempty"`
+	Max   *float64  `json:"max,omitempty"`
+	Last  *float64  `json:"last,omitempty"`
+}
+
+// History is the answer of /api/v1/history. Counter points hold the deltas
+// added in the interval, gauge points the average value along with the
+// minimum, the maximum and the last one.
+type History struct {
+	Type   string         `json:"type"`
+	ID     string         `json:"id"`
+	Points []HistoryPoint `json:"points"`
+}
@@ -0,0 +1,11 @@
+package model
+
+// MetricMeta describes a metric, it is served and set at /api/v1/meta.
+type MetricMeta struct {
+	Type        string `json:"type"`
+	ID          string `json:"id"`
+	Unit        string `json:"unit,omitempty"`
+	Description string `json:"description,omitempty"`
+	Owner       string `json:"owner,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+}
@@ -0,0 +1,22 @@
+package model
+
+// Query is the body of /api/v1/query. The metrics of the type are selected
+// by a name glob or a regex and by Labels, the tags or attributes whose
+// values are segments of the name, e.g. {"host": "a"} selects cpu.a.usage.
+// K limits the number of metrics returned by topk.
+type Query struct {
+	Type        string            `json:"type"`
+	Name        string            `json:"name,omitempty"`
+	Regex       string            `json:"regex,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Aggregation string            `json:"aggregation"`
+	K           int               `json:"k,omitempty"`
+}
+
+// QueryResult holds the value of the aggregation, or the metrics for topk.
+// The value is null when no metric was selected for avg, min and max.
+type QueryResult struct {
+	Aggregation string    `json:"aggregation"`
+	Value       *float64  `json:"value"`
+	Metrics     []Metrics `json:"metrics,omitempty"`
+}
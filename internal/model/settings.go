@@ -0,0 +1,11 @@
+package model
+
+// AgentSettings is the configuration the server hands out to agents. Zero
+// values leave the agent's own configuration in place.
+type AgentSettings struct {
+	PollInterval   int      `json:"poll_interval,omitempty"`
+	ReportInterval int      `json:"report_interval,omitempty"`
+	Collectors     []string `json:"collectors,omitempty"`
+	Include        []string `json:"include,omitempty"`
+	Exclude        []string `json:"exclude,omitempty"`
+}
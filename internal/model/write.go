@@ -0,0 +1,16 @@
+package model
+
+// LineError reports a line of /write that was not written, the lines are
+// numbered from 1.
+type LineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// WriteResult is returned by /write when some lines were rejected, Written
+// is the number of metrics written from the other lines.
+type WriteResult struct {
+	Error   string      `json:"error"`
+	Written int         `json:"written"`
+	Lines   []LineError `json:"lines"`
+}
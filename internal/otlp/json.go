@@ -0,0 +1,248 @@
+package otlp
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// jsonNumber accepts the 64-bit integers and the doubles encoded as numbers
+// or as strings, as the OTLP JSON encoding allows both.
+type jsonNumber string
+
+func (n *jsonNumber) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	switch value := v.(type) {
+	case string:
+		*n = jsonNumber(value)
+	case float64:
+		*n = jsonNumber(string(b))
+	case nil:
+		*n = ""
+	default:
+		return &json.UnmarshalTypeError{Value: string(b), Type: nil}
+	}
+	return nil
+}
+
+func (n jsonNumber) uint64() uint64 {
+	v, _ := strconv.ParseUint(string(n), 10, 64)
+	return v
+}
+
+func (n jsonNumber) float64() float64 {
+	v, _ := strconv.ParseFloat(string(n), 64)
+	return v
+}
+
+func (n *jsonNumber) pointer() *float64 {
+	if n == nil || *n == "" {
+		return nil
+	}
+	v := n.float64()
+	return &v
+}
+
+// jsonTemporality accepts the enum as its number or its name.
+type jsonTemporality Temporality
+
+func (t *jsonTemporality) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	switch value := v.(type) {
+	case float64:
+		*t = jsonTemporality(value)
+	case string:
+		switch value {
+		case "AGGREGATION_TEMPORALITY_DELTA":
+			*t = jsonTemporality(TemporalityDelta)
+		case "AGGREGATION_TEMPORALITY_CUMULATIVE":
+			*t = jsonTemporality(TemporalityCumulative)
+		default:
+			*t = jsonTemporality(TemporalityUnspecified)
+		}
+	}
+	return nil
+}
+
+type jsonAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue *string     `json:"stringValue"`
+		BoolValue   *bool       `json:"boolValue"`
+		IntValue    *jsonNumber `json:"intValue"`
+		DoubleValue *jsonNumber `json:"doubleValue"`
+	} `json:"value"`
+}
+
+type jsonNumberPoint struct {
+	Attributes        []jsonAttribute `json:"attributes"`
+	StartTimeUnixNano jsonNumber      `json:"startTimeUnixNano"`
+	TimeUnixNano      jsonNumber      `json:"timeUnixNano"`
+	AsDouble          *jsonNumber     `json:"asDouble"`
+	AsInt             *jsonNumber     `json:"asInt"`
+}
+
+type jsonHistogramPoint struct {
+	Attributes        []jsonAttribute `json:"attributes"`
+	StartTimeUnixNano jsonNumber      `json:"startTimeUnixNano"`
+	TimeUnixNano      jsonNumber      `json:"timeUnixNano"`
+	Count             jsonNumber      `json:"count"`
+	Sum               *jsonNumber     `json:"sum"`
+	Min               *jsonNumber     `json:"min"`
+	Max               *jsonNumber     `json:"max"`
+}
+
+type jsonNumbers struct {
+	DataPoints             []jsonNumberPoint `json:"dataPoints"`
+	AggregationTemporality jsonTemporality   `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type jsonPoints struct {
+	DataPoints []json.RawMessage `json:"dataPoints"`
+}
+
+type jsonMetric struct {
+	Name      string       `json:"name"`
+	Gauge     *jsonNumbers `json:"gauge"`
+	Sum       *jsonNumbers `json:"sum"`
+	Histogram *struct {
+		DataPoints             []jsonHistogramPoint `json:"dataPoints"`
+		AggregationTemporality jsonTemporality      `json:"aggregationTemporality"`
+	} `json:"histogram"`
+	ExponentialHistogram *jsonPoints `json:"exponentialHistogram"`
+	Summary              *jsonPoints `json:"summary"`
+}
+
+type jsonRequest struct {
+	ResourceMetrics []struct {
+		Resource struct {
+			Attributes []jsonAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeMetrics []struct {
+			Metrics []jsonMetric `json:"metrics"`
+		} `json:"scopeMetrics"`
+	} `json:"resourceMetrics"`
+}
+
+// UnmarshalJSON decodes an ExportMetricsServiceRequest in the OTLP JSON
+// encoding.
+func UnmarshalJSON(data []byte) (*Request, error) {
+	var body jsonRequest
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+
+	req := &Request{}
+	for _, rm := range body.ResourceMetrics {
+		resource := ResourceMetrics{Attributes: jsonAttributes(rm.Resource.Attributes)}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				resource.Metrics = append(resource.Metrics, jsonToMetric(m))
+			}
+		}
+		req.ResourceMetrics = append(req.ResourceMetrics, resource)
+	}
+
+	return req, nil
+}
+
+func jsonToMetric(m jsonMetric) Metric {
+	metric := Metric{Name: m.Name}
+
+	numbers := m.Gauge
+	metric.Kind = KindGauge
+	if m.Sum != nil {
+		numbers = m.Sum
+		metric.Kind = KindSum
+	}
+
+	switch {
+	case numbers != nil:
+		metric.Temporality = Temporality(numbers.AggregationTemporality)
+		metric.Monotonic = numbers.IsMonotonic
+		for _, p := range numbers.DataPoints {
+			point := NumberPoint{
+				Attributes: jsonAttributes(p.Attributes),
+				Start:      p.StartTimeUnixNano.uint64(),
+				Time:       p.TimeUnixNano.uint64(),
+			}
+			if p.AsDouble != nil {
+				point.Value = p.AsDouble.float64()
+			} else if p.AsInt != nil {
+				point.Value = p.AsInt.float64()
+			}
+			metric.NumberPoints = append(metric.NumberPoints, point)
+		}
+	case m.Histogram != nil:
+		metric.Kind = KindHistogram
+		metric.Temporality = Temporality(m.Histogram.AggregationTemporality)
+		for _, p := range m.Histogram.DataPoints {
+			metric.HistogramPoints = append(metric.HistogramPoints, HistogramPoint{
+				Attributes: jsonAttributes(p.Attributes),
+				Start:      p.StartTimeUnixNano.uint64(),
+				Time:       p.TimeUnixNano.uint64(),
+				Count:      p.Count.uint64(),
+				Sum:        p.Sum.pointer(),
+				Min:        p.Min.pointer(),
+				Max:        p.Max.pointer(),
+			})
+		}
+	default:
+		metric.Kind = KindUnsupported
+		for _, points := range []*jsonPoints{m.ExponentialHistogram, m.Summary} {
+			if points != nil {
+				metric.Points += len(points.DataPoints)
+			}
+		}
+	}
+
+	return metric
+}
+
+func jsonAttributes(attributes []jsonAttribute) []Attribute {
+	var result []Attribute
+	for _, a := range attributes {
+		attribute := Attribute{Key: a.Key}
+		switch {
+		case a.Value.StringValue != nil:
+			attribute.Value = *a.Value.StringValue
+		case a.Value.BoolValue != nil:
+			attribute.Value = strconv.FormatBool(*a.Value.BoolValue)
+		case a.Value.IntValue != nil:
+			attribute.Value = string(*a.Value.IntValue)
+		case a.Value.DoubleValue != nil:
+			attribute.Value = string(*a.Value.DoubleValue)
+		}
+		result = append(result, attribute)
+	}
+	return result
+}
+
+// MarshalJSON encodes the result as an ExportMetricsServiceResponse.
+func (r Result) MarshalJSON() ([]byte, error) {
+	type partialSuccess struct {
+		RejectedDataPoints string `json:"rejectedDataPoints,omitempty"`
+		ErrorMessage       string `json:"errorMessage,omitempty"`
+	}
+	type response struct {
+		PartialSuccess *partialSuccess `json:"partialSuccess,omitempty"`
+	}
+
+	if r.Rejected == 0 && r.Message == "" {
+		return json.Marshal(response{})
+	}
+
+	partial := &partialSuccess{ErrorMessage: r.Message}
+	if r.Rejected != 0 {
+		partial.RejectedDataPoints = strconv.FormatInt(r.Rejected, 10)
+	}
+	return json.Marshal(response{PartialSuccess: partial})
+}
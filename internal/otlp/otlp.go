@@ -0,0 +1,336 @@
+// Package otlp decodes the OTLP/HTTP metrics export requests and converts
+// them to the metrics of the server.
+package otlp
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+)
+
+type Temporality int
+
+const (
+	TemporalityUnspecified Temporality = 0
+	TemporalityDelta       Temporality = 1
+	TemporalityCumulative  Temporality = 2
+)
+
+type Kind int
+
+const (
+	KindUnsupported Kind = iota
+	KindGauge
+	KindSum
+	KindHistogram
+)
+
+// seriesTTL is how long the state of a cumulative series is kept after its
+// last point.
+const seriesTTL = time.Hour
+
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+type NumberPoint struct {
+	Attributes []Attribute
+	Start      uint64
+	Time       uint64
+	Value      float64
+}
+
+type HistogramPoint struct {
+	Attributes []Attribute
+	Start      uint64
+	Time       uint64
+	Count      uint64
+	Sum        *float64
+	Min        *float64
+	Max        *float64
+}
+
+type Metric struct {
+	Name            string
+	Kind            Kind
+	Temporality     Temporality
+	Monotonic       bool
+	NumberPoints    []NumberPoint
+	HistogramPoints []HistogramPoint
+	// Points is the number of points of the unsupported kinds
+	Points int
+}
+
+func (m Metric) points() int {
+	return len(m.NumberPoints) + len(m.HistogramPoints) + m.Points
+}
+
+type ResourceMetrics struct {
+	Attributes []Attribute
+	Metrics    []Metric
+}
+
+type Request struct {
+	ResourceMetrics []ResourceMetrics
+}
+
+// Result is the partial success of an export, it is empty when every point
+// was accepted.
+type Result struct {
+	Rejected int64
+	Message  string
+}
+
+type series struct {
+	start uint64
+	last  float64
+	// remainder keeps the fractions of the double sums stored by the
+	// integer counters
+	remainder float64
+	// total is the value of the non-monotonic delta sums
+	total float64
+	seen  time.Time
+}
+
+// Converter maps the OTLP metrics to gauges and counters. It keeps the last
+// value of every cumulative sum to send the counters as deltas.
+//
+// The state of the series is only changed by Commit, so a request that
+// could not be stored converts to the same metrics when it is retried.
+type Converter struct {
+	started time.Time
+	// writeMu serializes Write from the conversion to the commit
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	series  map[string]*series
+	pruned  time.Time
+}
+
+// Pending is the state of the series after a conversion, it is committed
+// once the converted metrics are stored.
+type Pending struct {
+	series map[string]*series
+}
+
+func NewConverter() *Converter {
+	now := time.Now()
+	return &Converter{
+		started: now,
+		writeMu: sync.Mutex{},
+		mu:      sync.Mutex{},
+		series:  make(map[string]*series),
+		pruned:  now,
+	}
+}
+
+// Convert names every metric service.namespace.service.name.metric.attribute
+// values ordered by key, the missing parts are left out. Sums are counters,
+// or gauges when they are not monotonic, histograms are summarized with the
+// count, sum, avg, min and max gauges. times[i] is the time of the point of
+// metrics[i], zero when the point has none.
+func (c *Converter) Convert(req *Request) ([]model.Metrics, []time.Time, Result, Pending) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.pruned) > seriesTTL {
+		for key, s := range c.series {
+			if now.Sub(s.seen) > seriesTTL {
+				delete(c.series, key)
+			}
+		}
+		c.pruned = now
+	}
+
+	var metrics []model.Metrics
+	var times []time.Time
+	var result Result
+	var invalid []string
+	pending := Pending{series: make(map[string]*series)}
+	for _, resource := range req.ResourceMetrics {
+		prefix := resourcePrefix(resource.Attributes)
+
+		for _, metric := range resource.Metrics {
+			if metric.Name == "" {
+				result.Rejected += int64(metric.points())
+				invalid = append(invalid, "metric name is empty")
+				continue
+			}
+			name := prefix + metric.Name
+
+			switch metric.Kind {
+			case KindGauge:
+				for _, point := range metric.NumberPoints {
+					metrics = append(metrics, gauge(pointName(name, point.Attributes), point.Value))
+					times = append(times, pointTime(point.Time))
+				}
+			case KindSum:
+				for _, point := range metric.NumberPoints {
+					if m, ok := c.sum(pending, pointName(name, point.Attributes), metric, point, now); ok {
+						metrics = append(metrics, m)
+						times = append(times, pointTime(point.Time))
+					}
+				}
+			case KindHistogram:
+				for _, point := range metric.HistogramPoints {
+					summary := histogram(pointName(name, point.Attributes), point)
+					metrics = append(metrics, summary...)
+					for range summary {
+						times = append(times, pointTime(point.Time))
+					}
+				}
+			default:
+				result.Rejected += int64(metric.points())
+				invalid = append(invalid, "unsupported type of "+metric.Name)
+			}
+		}
+	}
+
+	if len(invalid) > 0 {
+		result.Message = strings.Join(invalid, "; ")
+	}
+
+	return metrics, times, result, pending
+}
+
+// Write converts the request and passes the metrics with their times to
+// store, the state of the series is committed when store reports them
+// stored. The writes are serialized, so the concurrent exports of a series
+// take their deltas from the state the previous one committed.
+func (c *Converter) Write(req *Request, store func(metrics []model.Metrics, times []time.Time) bool) (Result, bool) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	metrics, times, result, pending := c.Convert(req)
+	if !store(metrics, times) {
+		return result, false
+	}
+	c.Commit(pending)
+
+	return result, true
+}
+
+// Commit keeps the state of the series of a conversion whose metrics were
+// stored.
+func (c *Converter) Commit(pending Pending) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name, s := range pending.series {
+		c.series[name] = s
+	}
+}
+
+// sum converts a point of a sum, ok is false for the first point of a
+// cumulative series that started before the converter. The new state of the
+// series is kept in pending.
+func (c *Converter) sum(pending Pending, name string, metric Metric, point NumberPoint, now time.Time) (model.Metrics, bool) {
+	s, found := pending.series[name]
+	if !found {
+		if current, ok := c.series[name]; ok {
+			copied := *current
+			s, found = &copied, true
+		} else {
+			s = &series{}
+		}
+		pending.series[name] = s
+	}
+	s.seen = now
+
+	var delta float64
+	switch metric.Temporality {
+	case TemporalityCumulative:
+		reset := point.Start != s.start || point.Value < s.last
+		switch {
+		case found && !reset:
+			delta = point.Value - s.last
+		case found || (point.Start != 0 && time.Unix(0, int64(point.Start)).After(c.started)):
+			// the series restarted from zero
+			delta = point.Value
+		default:
+			// the counts before the first point are unknown
+			s.start, s.last = point.Start, point.Value
+			if !metric.Monotonic {
+				return gauge(name, point.Value), true
+			}
+			return model.Metrics{}, false
+		}
+		s.start, s.last = point.Start, point.Value
+		if !metric.Monotonic {
+			return gauge(name, point.Value), true
+		}
+	default:
+		delta = point.Value
+		if !metric.Monotonic {
+			s.total += point.Value
+			return gauge(name, s.total), true
+		}
+	}
+
+	s.remainder += delta
+	whole := math.Trunc(s.remainder)
+	s.remainder -= whole
+
+	return counter(name, int64(whole)), true
+}
+
+func pointTime(unixNano uint64) time.Time {
+	if unixNano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(unixNano))
+}
+
+func histogram(name string, point HistogramPoint) []model.Metrics {
+	count := float64(point.Count)
+	metrics := []model.Metrics{gauge(name+".count", count)}
+	if point.Sum != nil {
+		metrics = append(metrics, gauge(name+".sum", *point.Sum))
+		if point.Count > 0 {
+			metrics = append(metrics, gauge(name+".avg", *point.Sum/count))
+		}
+	}
+	if point.Min != nil {
+		metrics = append(metrics, gauge(name+".min", *point.Min))
+	}
+	if point.Max != nil {
+		metrics = append(metrics, gauge(name+".max", *point.Max))
+	}
+	return metrics
+}
+
+func resourcePrefix(attributes []Attribute) string {
+	var prefix string
+	for _, key := range []string{"service.namespace", "service.name"} {
+		for _, attribute := range attributes {
+			if attribute.Key == key && attribute.Value != "" {
+				prefix += attribute.Value + "."
+			}
+		}
+	}
+	return prefix
+}
+
+func pointName(name string, attributes []Attribute) string {
+	sorted := append([]Attribute(nil), attributes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Key < sorted[j].Key
+	})
+	for _, attribute := range sorted {
+		name += "." + attribute.Value
+	}
+	return strings.NewReplacer("/", "_", " ", "_").Replace(name)
+}
+
+func gauge(id string, value float64) model.Metrics {
+	return model.Metrics{ID: id, Type: "gauge", Value: &value}
+}
+
+func counter(id string, delta int64) model.Metrics {
+	return model.Metrics{ID: id, Type: "counter", Delta: &delta}
+}
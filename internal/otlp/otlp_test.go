@@ -0,0 +1,274 @@
+package otlp_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+	"github.com/c2pc/go-musthave-metrics/internal/otlp"
+)
+
+func appendMessage(b []byte, num protowire.Number, message []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, message)
+}
+
+func appendFixed64(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, v)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func stringAttribute(key, value string) []byte {
+	var b []byte
+	b = appendMessage(b, 1, []byte(key))
+	return appendMessage(b, 2, appendMessage(nil, 1, []byte(value)))
+}
+
+func TestUnmarshalProtobuf(t *testing.T) {
+	var sumPoint []byte
+	sumPoint = appendFixed64(sumPoint, 2, 10)
+	sumPoint = appendFixed64(sumPoint, 3, 20)
+	sumPoint = appendFixed64(sumPoint, 6, 5)
+	sumPoint = appendMessage(sumPoint, 7, stringAttribute("method", "GET"))
+
+	var sum []byte
+	sum = appendMessage(sum, 1, sumPoint)
+	sum = appendVarint(sum, 2, uint64(otlp.TemporalityCumulative))
+	sum = appendVarint(sum, 3, 1)
+
+	var histogramPoint []byte
+	histogramPoint = appendFixed64(histogramPoint, 4, 2)
+	histogramPoint = appendFixed64(histogramPoint, 5, math.Float64bits(3))
+	histogramPoint = appendFixed64(histogramPoint, 12, math.Float64bits(2))
+
+	var requests, latency, summary []byte
+	requests = appendMessage(requests, 1, []byte("requests"))
+	requests = appendMessage(requests, 7, sum)
+	latency = appendMessage(latency, 1, []byte("latency"))
+	latency = appendMessage(latency, 9, appendMessage(nil, 1, histogramPoint))
+	summary = appendMessage(summary, 1, []byte("summary"))
+	summary = appendMessage(summary, 11, appendMessage(appendMessage(nil, 1, nil), 1, nil))
+
+	var scope []byte
+	scope = appendMessage(scope, 2, requests)
+	scope = appendMessage(scope, 2, latency)
+	scope = appendMessage(scope, 2, summary)
+
+	var resource []byte
+	resource = appendMessage(resource, 1, appendMessage(nil, 1, stringAttribute("service.name", "api")))
+	resource = appendMessage(resource, 2, scope)
+
+	got, err := otlp.UnmarshalProtobuf(appendMessage(nil, 1, resource))
+	require.NoError(t, err)
+
+	sumValue, maxValue := 3.0, 2.0
+	assert.Equal(t, &otlp.Request{ResourceMetrics: []otlp.ResourceMetrics{{
+		Attributes: []otlp.Attribute{{Key: "service.name", Value: "api"}},
+		Metrics: []otlp.Metric{
+			{
+				Name:        "requests",
+				Kind:        otlp.KindSum,
+				Temporality: otlp.TemporalityCumulative,
+				Monotonic:   true,
+				NumberPoints: []otlp.NumberPoint{
+					{Attributes: []otlp.Attribute{{Key: "method", Value: "GET"}}, Start: 10, Time: 20, Value: 5},
+				},
+			},
+			{
+				Name:            "latency",
+				Kind:            otlp.KindHistogram,
+				HistogramPoints: []otlp.HistogramPoint{{Count: 2, Sum: &sumValue, Max: &maxValue}},
+			},
+			{Name: "summary", Kind: otlp.KindUnsupported, Points: 2},
+		},
+	}}}, got)
+
+	_, err = otlp.UnmarshalProtobuf([]byte{0x0a, 0x05})
+	assert.Error(t, err)
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	got, err := otlp.UnmarshalJSON([]byte(`{"resourceMetrics": [{
+		"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "api"}}]},
+		"scopeMetrics": [{"metrics": [
+			{"name": "requests", "sum": {
+				"aggregationTemporality": "AGGREGATION_TEMPORALITY_DELTA",
+				"isMonotonic": true,
+				"dataPoints": [{"asInt": "5", "timeUnixNano": "20", "attributes": [{"key": "code", "value": {"intValue": 200}}]}]
+			}},
+			{"name": "load", "gauge": {"dataPoints": [{"asDouble": 0.5}]}},
+			{"name": "latency", "histogram": {"aggregationTemporality": 2, "dataPoints": [{"count": "2", "sum": 3}]}},
+			{"name": "summary", "summary": {"dataPoints": [{}]}}
+		]}]
+	}]}`))
+	require.NoError(t, err)
+
+	sumValue := 3.0
+	assert.Equal(t, &otlp.Request{ResourceMetrics: []otlp.ResourceMetrics{{
+		Attributes: []otlp.Attribute{{Key: "service.name", Value: "api"}},
+		Metrics: []otlp.Metric{
+			{
+				Name:        "requests",
+				Kind:        otlp.KindSum,
+				Temporality: otlp.TemporalityDelta,
+				Monotonic:   true,
+				NumberPoints: []otlp.NumberPoint{
+					{Attributes: []otlp.Attribute{{Key: "code", Value: "200"}}, Time: 20, Value: 5},
+				},
+			},
+			{Name: "load", Kind: otlp.KindGauge, NumberPoints: []otlp.NumberPoint{{Value: 0.5}}},
+			{
+				Name:            "latency",
+				Kind:            otlp.KindHistogram,
+				Temporality:     otlp.TemporalityCumulative,
+				HistogramPoints: []otlp.HistogramPoint{{Count: 2, Sum: &sumValue}},
+			},
+			{Name: "summary", Kind: otlp.KindUnsupported, Points: 1},
+		},
+	}}}, got)
+
+	_, err = otlp.UnmarshalJSON([]byte(`{"resourceMetrics": {}}`))
+	assert.Error(t, err)
+}
+
+// values returns the metrics as "type id value".
+func values(metrics []model.Metrics) []string {
+	var result []string
+	for _, m := range metrics {
+		if m.Value != nil {
+			result = append(result, fmt.Sprintf("%s %s %g", m.Type, m.ID, *m.Value))
+		} else {
+			result = append(result, fmt.Sprintf("%s %s %d", m.Type, m.ID, *m.Delta))
+		}
+	}
+	return result
+}
+
+func TestConverter_Convert(t *testing.T) {
+	before := uint64(time.Now().Add(-time.Hour).UnixNano())
+	converter := otlp.NewConverter()
+	after := uint64(time.Now().Add(time.Second).UnixNano())
+
+	cumulative := func(start uint64, value float64) *otlp.Request {
+		return &otlp.Request{ResourceMetrics: []otlp.ResourceMetrics{{
+			Attributes: []otlp.Attribute{{Key: "service.name", Value: "api"}, {Key: "service.namespace", Value: "shop"}},
+			Metrics: []otlp.Metric{{
+				Name:        "requests",
+				Kind:        otlp.KindSum,
+				Temporality: otlp.TemporalityCumulative,
+				Monotonic:   true,
+				NumberPoints: []otlp.NumberPoint{{
+					Attributes: []otlp.Attribute{{Key: "path", Value: "/orders"}, {Key: "method", Value: "GET"}},
+					Start:      start,
+					Value:      value,
+				}},
+			}},
+		}}}
+	}
+
+	tests := []struct {
+		name       string
+		req        *otlp.Request
+		want       []string
+		wantResult otlp.Result
+	}{
+		{name: "Cumulative baseline", req: cumulative(before, 10)},
+		{name: "Cumulative delta", req: cumulative(before, 15.5), want: []string{"counter shop.api.requests.GET._orders 5"}},
+		{name: "Cumulative fraction", req: cumulative(before, 16), want: []string{"counter shop.api.requests.GET._orders 1"}},
+		{name: "Cumulative reset", req: cumulative(after, 3), want: []string{"counter shop.api.requests.GET._orders 3"}},
+		{
+			name: "Gauges and histograms",
+			req: &otlp.Request{ResourceMetrics: []otlp.ResourceMetrics{{Metrics: []otlp.Metric{
+				{Name: "load", Kind: otlp.KindGauge, NumberPoints: []otlp.NumberPoint{{Value: 0.5}}},
+				{Name: "queue", Kind: otlp.KindSum, Temporality: otlp.TemporalityDelta, NumberPoints: []otlp.NumberPoint{{Value: 2}, {Value: -1}}},
+				{Name: "sent", Kind: otlp.KindSum, Temporality: otlp.TemporalityDelta, Monotonic: true, NumberPoints: []otlp.NumberPoint{{Value: 4}}},
+				{Name: "latency", Kind: otlp.KindHistogram, HistogramPoints: []otlp.HistogramPoint{{Count: 4, Sum: ptr(2.0), Min: ptr(0.1), Max: ptr(1.0)}}},
+			}}}},
+			want: []string{
+				"gauge load 0.5",
+				"gauge queue 2",
+				"gauge queue 1",
+				"counter sent 4",
+				"gauge latency.count 4",
+				"gauge latency.sum 2",
+				"gauge latency.avg 0.5",
+				"gauge latency.min 0.1",
+				"gauge latency.max 1",
+			},
+		},
+		{
+			name: "Rejected",
+			req: &otlp.Request{ResourceMetrics: []otlp.ResourceMetrics{{Metrics: []otlp.Metric{
+				{Name: "summary", Kind: otlp.KindUnsupported, Points: 2},
+				{Kind: otlp.KindGauge, NumberPoints: []otlp.NumberPoint{{Value: 1}}},
+				{Name: "load", Kind: otlp.KindGauge, NumberPoints: []otlp.NumberPoint{{Value: 1}}},
+			}}}},
+			want:       []string{"gauge load 1"},
+			wantResult: otlp.Result{Rejected: 3, Message: "unsupported type of summary; metric name is empty"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics, _, result, pending := converter.Convert(tt.req)
+			assert.Equal(t, tt.want, values(metrics))
+			assert.Equal(t, tt.wantResult, result)
+			converter.Commit(pending)
+		})
+	}
+}
+
+func TestConverter_Convert_Uncommitted(t *testing.T) {
+	converter := otlp.NewConverter()
+	after := uint64(time.Now().Add(time.Second).UnixNano())
+
+	req := func(value float64) *otlp.Request {
+		return &otlp.Request{ResourceMetrics: []otlp.ResourceMetrics{{Metrics: []otlp.Metric{{
+			Name:         "requests",
+			Kind:         otlp.KindSum,
+			Temporality:  otlp.TemporalityCumulative,
+			Monotonic:    true,
+			NumberPoints: []otlp.NumberPoint{{Start: after, Value: value}},
+		}}}}}
+	}
+
+	metrics, _, _, pending := converter.Convert(req(10))
+	assert.Equal(t, []string{"counter requests 10"}, values(metrics))
+	converter.Commit(pending)
+
+	// the storage failed, the retry gives the same delta
+	metrics, _, _, _ = converter.Convert(req(15))
+	assert.Equal(t, []string{"counter requests 5"}, values(metrics))
+	metrics, _, _, pending = converter.Convert(req(15))
+	assert.Equal(t, []string{"counter requests 5"}, values(metrics))
+	converter.Commit(pending)
+
+	metrics, _, _, _ = converter.Convert(req(18))
+	assert.Equal(t, []string{"counter requests 3"}, values(metrics))
+}
+
+func TestResult_Marshal(t *testing.T) {
+	data, err := otlp.Result{}.MarshalJSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{}`, string(data))
+	assert.Empty(t, otlp.Result{}.MarshalProtobuf())
+
+	data, err = otlp.Result{Rejected: 2, Message: "unsupported type of summary"}.MarshalJSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"partialSuccess": {"rejectedDataPoints": "2", "errorMessage": "unsupported type of summary"}}`, string(data))
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
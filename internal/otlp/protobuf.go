@@ -0,0 +1,374 @@
+package otlp
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The fields of the opentelemetry.proto.collector.metrics.v1 messages that
+// are read, the others are skipped.
+const (
+	fieldResourceMetrics = 1
+
+	fieldResource     = 1
+	fieldScopeMetrics = 2
+
+	fieldResourceAttributes = 1
+
+	fieldScopeMetricsMetrics = 2
+
+	fieldMetricName      = 1
+	fieldMetricGauge     = 5
+	fieldMetricSum       = 7
+	fieldMetricHistogram = 9
+	fieldMetricExpHist   = 10
+	fieldMetricSummary   = 11
+
+	fieldDataPoints  = 1
+	fieldTemporality = 2
+	fieldMonotonic   = 3
+
+	fieldNumberStart      = 2
+	fieldNumberTime       = 3
+	fieldNumberDouble     = 4
+	fieldNumberInt        = 6
+	fieldNumberAttributes = 7
+
+	fieldHistogramStart      = 2
+	fieldHistogramTime       = 3
+	fieldHistogramCount      = 4
+	fieldHistogramSum        = 5
+	fieldHistogramAttributes = 9
+	fieldHistogramMin        = 11
+	fieldHistogramMax        = 12
+
+	fieldKey   = 1
+	fieldValue = 2
+
+	fieldStringValue = 1
+	fieldBoolValue   = 2
+	fieldIntValue    = 3
+	fieldDoubleValue = 4
+
+	fieldPartialSuccess = 1
+	fieldRejectedPoints = 1
+	fieldPartialMessage = 2
+)
+
+var errInvalidProtobuf = errors.New("invalid protobuf message")
+
+type field struct {
+	num    protowire.Number
+	typ    protowire.Type
+	bytes  []byte
+	scalar uint64
+}
+
+// fields splits the message into its fields.
+func fields(data []byte) ([]field, error) {
+	var result []field
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, errInvalidProtobuf
+		}
+		data = data[n:]
+
+		f := field{num: num, typ: typ}
+		switch typ {
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(data)
+		case protowire.VarintType:
+			f.scalar, n = protowire.ConsumeVarint(data)
+		case protowire.Fixed64Type:
+			f.scalar, n = protowire.ConsumeFixed64(data)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(data)
+			f.scalar = uint64(v)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return nil, errInvalidProtobuf
+		}
+		data = data[n:]
+		result = append(result, f)
+	}
+	return result, nil
+}
+
+func (f field) is(num protowire.Number, typ protowire.Type) bool {
+	return f.num == num && f.typ == typ
+}
+
+// UnmarshalProtobuf decodes an ExportMetricsServiceRequest.
+func UnmarshalProtobuf(data []byte) (*Request, error) {
+	fs, err := fields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &Request{}
+	for _, f := range fs {
+		if !f.is(fieldResourceMetrics, protowire.BytesType) {
+			continue
+		}
+		resource, err := unmarshalResourceMetrics(f.bytes)
+		if err != nil {
+			return nil, err
+		}
+		req.ResourceMetrics = append(req.ResourceMetrics, resource)
+	}
+	return req, nil
+}
+
+func unmarshalResourceMetrics(data []byte) (ResourceMetrics, error) {
+	var result ResourceMetrics
+
+	fs, err := fields(data)
+	if err != nil {
+		return result, err
+	}
+
+	for _, f := range fs {
+		switch {
+		case f.is(fieldResource, protowire.BytesType):
+			resource, err := fields(f.bytes)
+			if err != nil {
+				return result, err
+			}
+			for _, rf := range resource {
+				if rf.is(fieldResourceAttributes, protowire.BytesType) {
+					attribute, err := unmarshalAttribute(rf.bytes)
+					if err != nil {
+						return result, err
+					}
+					result.Attributes = append(result.Attributes, attribute)
+				}
+			}
+		case f.is(fieldScopeMetrics, protowire.BytesType):
+			scope, err := fields(f.bytes)
+			if err != nil {
+				return result, err
+			}
+			for _, sf := range scope {
+				if sf.is(fieldScopeMetricsMetrics, protowire.BytesType) {
+					metric, err := unmarshalMetric(sf.bytes)
+					if err != nil {
+						return result, err
+					}
+					result.Metrics = append(result.Metrics, metric)
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func unmarshalMetric(data []byte) (Metric, error) {
+	var result Metric
+
+	fs, err := fields(data)
+	if err != nil {
+		return result, err
+	}
+
+	for _, f := range fs {
+		if f.is(fieldMetricName, protowire.BytesType) {
+			result.Name = string(f.bytes)
+			continue
+		}
+		if f.typ != protowire.BytesType {
+			continue
+		}
+
+		switch f.num {
+		case fieldMetricGauge, fieldMetricSum:
+			result.Kind = KindSum
+			if f.num == fieldMetricGauge {
+				result.Kind = KindGauge
+			}
+			err = unmarshalNumbers(f.bytes, &result)
+		case fieldMetricHistogram:
+			result.Kind = KindHistogram
+			err = unmarshalHistograms(f.bytes, &result)
+		case fieldMetricExpHist, fieldMetricSummary:
+			result.Kind = KindUnsupported
+			result.Points, err = countPoints(f.bytes)
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+func unmarshalNumbers(data []byte, metric *Metric) error {
+	fs, err := fields(data)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fs {
+		switch {
+		case f.is(fieldTemporality, protowire.VarintType):
+			metric.Temporality = Temporality(f.scalar)
+		case f.is(fieldMonotonic, protowire.VarintType):
+			metric.Monotonic = f.scalar != 0
+		case f.is(fieldDataPoints, protowire.BytesType):
+			pfs, err := fields(f.bytes)
+			if err != nil {
+				return err
+			}
+
+			var point NumberPoint
+			for _, pf := range pfs {
+				switch {
+				case pf.is(fieldNumberStart, protowire.Fixed64Type):
+					point.Start = pf.scalar
+				case pf.is(fieldNumberTime, protowire.Fixed64Type):
+					point.Time = pf.scalar
+				case pf.is(fieldNumberDouble, protowire.Fixed64Type):
+					point.Value = math.Float64frombits(pf.scalar)
+				case pf.is(fieldNumberInt, protowire.Fixed64Type):
+					point.Value = float64(int64(pf.scalar))
+				case pf.is(fieldNumberAttributes, protowire.BytesType):
+					attribute, err := unmarshalAttribute(pf.bytes)
+					if err != nil {
+						return err
+					}
+					point.Attributes = append(point.Attributes, attribute)
+				}
+			}
+			metric.NumberPoints = append(metric.NumberPoints, point)
+		}
+	}
+
+	return nil
+}
+
+func unmarshalHistograms(data []byte, metric *Metric) error {
+	fs, err := fields(data)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fs {
+		switch {
+		case f.is(fieldTemporality, protowire.VarintType):
+			metric.Temporality = Temporality(f.scalar)
+		case f.is(fieldDataPoints, protowire.BytesType):
+			pfs, err := fields(f.bytes)
+			if err != nil {
+				return err
+			}
+
+			var point HistogramPoint
+			for _, pf := range pfs {
+				value := math.Float64frombits(pf.scalar)
+				switch {
+				case pf.is(fieldHistogramStart, protowire.Fixed64Type):
+					point.Start = pf.scalar
+				case pf.is(fieldHistogramTime, protowire.Fixed64Type):
+					point.Time = pf.scalar
+				case pf.is(fieldHistogramCount, protowire.Fixed64Type):
+					point.Count = pf.scalar
+				case pf.is(fieldHistogramSum, protowire.Fixed64Type):
+					point.Sum = &value
+				case pf.is(fieldHistogramMin, protowire.Fixed64Type):
+					point.Min = &value
+				case pf.is(fieldHistogramMax, protowire.Fixed64Type):
+					point.Max = &value
+				case pf.is(fieldHistogramAttributes, protowire.BytesType):
+					attribute, err := unmarshalAttribute(pf.bytes)
+					if err != nil {
+						return err
+					}
+					point.Attributes = append(point.Attributes, attribute)
+				}
+			}
+			metric.HistogramPoints = append(metric.HistogramPoints, point)
+		}
+	}
+
+	return nil
+}
+
+func countPoints(data []byte) (int, error) {
+	fs, err := fields(data)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	for _, f := range fs {
+		if f.is(fieldDataPoints, protowire.BytesType) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// unmarshalAttribute decodes a KeyValue, the value is kept as a string.
+func unmarshalAttribute(data []byte) (Attribute, error) {
+	var result Attribute
+
+	fs, err := fields(data)
+	if err != nil {
+		return result, err
+	}
+
+	for _, f := range fs {
+		switch {
+		case f.is(fieldKey, protowire.BytesType):
+			result.Key = string(f.bytes)
+		case f.is(fieldValue, protowire.BytesType):
+			vfs, err := fields(f.bytes)
+			if err != nil {
+				return result, err
+			}
+			for _, vf := range vfs {
+				switch {
+				case vf.is(fieldStringValue, protowire.BytesType):
+					result.Value = string(vf.bytes)
+				case vf.is(fieldBoolValue, protowire.VarintType):
+					result.Value = strconv.FormatBool(vf.scalar != 0)
+				case vf.is(fieldIntValue, protowire.VarintType):
+					result.Value = strconv.FormatInt(int64(vf.scalar), 10)
+				case vf.is(fieldDoubleValue, protowire.Fixed64Type):
+					result.Value = strconv.FormatFloat(math.Float64frombits(vf.scalar), 'g', -1, 64)
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// MarshalProtobuf encodes the result as an ExportMetricsServiceResponse.
+func (r Result) MarshalProtobuf() []byte {
+	if r.Rejected == 0 && r.Message == "" {
+		return nil
+	}
+
+	var partial []byte
+	if r.Rejected != 0 {
+		partial = protowire.AppendTag(partial, fieldRejectedPoints, protowire.VarintType)
+		partial = protowire.AppendVarint(partial, uint64(r.Rejected))
+	}
+	if r.Message != "" {
+		partial = protowire.AppendTag(partial, fieldPartialMessage, protowire.BytesType)
+		partial = protowire.AppendString(partial, r.Message)
+	}
+
+	var b []byte
+	b = protowire.AppendTag(b, fieldPartialSuccess, protowire.BytesType)
+	b = protowire.AppendBytes(b, partial)
+	return b
+}
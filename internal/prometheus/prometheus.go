@@ -0,0 +1,47 @@
+// Package prometheus names the metrics of the server the way Prometheus
+// expects them.
+package prometheus
+
+import "strings"
+
+// SanitizeName turns a metric name into a valid Prometheus one, replacing
+// every character outside [a-zA-Z0-9_:] with an underscore.
+func SanitizeName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// CounterName adds the _total suffix the counters are expected to have.
+func CounterName(name string) string {
+	if strings.HasSuffix(name, "_total") {
+		return name
+	}
+	return name + "_total"
+}
+
+// MetricName is the sanitized name of a metric of the server, with the
+// _total suffix for the counters.
+func MetricName(metricType string, id string) string {
+	name := SanitizeName(id)
+	if metricType == "counter" {
+		return CounterName(name)
+	}
+	return name
+}
@@ -0,0 +1,31 @@
+package prometheus_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c2pc/go-musthave-metrics/internal/prometheus"
+)
+
+func TestMetricName(t *testing.T) {
+	tests := []struct {
+		name       string
+		metricType string
+		id         string
+		want       string
+	}{
+		{"Gauge", "gauge", "Alloc", "Alloc"},
+		{"Invalid characters", "gauge", "probe.api.latency-ms", "probe_api_latency_ms"},
+		{"Leading digit", "gauge", "5xx", "_5xx"},
+		{"Empty", "gauge", "", "_"},
+		{"Counter", "counter", "PollCount", "PollCount_total"},
+		{"Counter with suffix", "counter", "requests.total", "requests_total"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, prometheus.MetricName(tt.metricType, tt.id))
+		})
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"net"
+	"path"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/c2pc/go-musthave-metrics/internal/logger"
@@ -13,7 +15,7 @@ import (
 )
 
 type Updater interface {
-	UpdateMetric(ctx context.Context, metrics []model.Metrics) error
+	UpdateMetric(ctx context.Context, sequence int64, metrics []model.Metrics) error
 }
 
 type MetricReader[T float64 | int64] interface {
@@ -22,32 +24,67 @@ type MetricReader[T float64 | int64] interface {
 	GetStats() map[string]T
 }
 
+// StatsResetter is implemented by readers whose stats are deltas that must be
+// cleared once they have been delivered to the server.
+type StatsResetter interface {
+	ResetStats()
+}
+
 type Timer struct {
 	PollInterval   int
 	ReportInterval int
 }
 
+// Collector groups the readers of one metric source so that the source can
+// be switched on and off by name.
+type Collector struct {
+	Name     string
+	Counters []MetricReader[int64]
+	Gauges   []MetricReader[float64]
+}
+
+type Settings struct {
+	Timer
+	Collectors []string
+	Include    []string
+	Exclude    []string
+}
+
 type Reporter struct {
-	counterMetric MetricReader[int64]
-	gaugeMetric   MetricReader[float64]
-	client        Updater
-	timer         Timer
+	collectors []Collector
+	client     Updater
+	base       Settings
+	remote     model.AgentSettings
+	mu         *sync.RWMutex
+	settings   Settings
+	changed    chan struct{}
+	sequence   *atomic.Int64
 }
 
-func New(client Updater, timer Timer, counterMetric MetricReader[int64], gaugeMetric MetricReader[float64]) *Reporter {
+func New(client Updater, base Settings, collectors ...Collector) *Reporter {
+	// start from the clock so that a restarted agent does not reuse the
+	// sequences the server still remembers
+	sequence := &atomic.Int64{}
+	sequence.Store(time.Now().UnixNano())
+
 	return &Reporter{
-		counterMetric: counterMetric,
-		gaugeMetric:   gaugeMetric,
-		client:        client,
-		timer:         timer,
+		collectors: collectors,
+		client:     client,
+		base:       base,
+		mu:         &sync.RWMutex{},
+		settings:   base,
+		changed:    make(chan struct{}, 1),
+		sequence:   sequence,
 	}
 }
 
 func (r *Reporter) Run(ctx context.Context) {
-	pollTicker := time.NewTicker(time.Duration(r.timer.PollInterval) * time.Second)
+	settings := r.getSettings()
+
+	pollTicker := time.NewTicker(time.Duration(settings.PollInterval) * time.Second)
 	defer pollTicker.Stop()
 
-	reportTicker := time.NewTicker(time.Duration(r.timer.ReportInterval) * time.Second)
+	reportTicker := time.NewTicker(time.Duration(settings.ReportInterval) * time.Second)
 	defer reportTicker.Stop()
 
 	for {
@@ -56,26 +93,129 @@ func (r *Reporter) Run(ctx context.Context) {
 			r.pollMetrics()
 		case <-reportTicker.C:
 			r.reportMetrics(ctx)
+		case <-r.changed:
+			newSettings := r.getSettings()
+			if newSettings.PollInterval != settings.PollInterval {
+				pollTicker.Reset(time.Duration(newSettings.PollInterval) * time.Second)
+			}
+			if newSettings.ReportInterval != settings.ReportInterval {
+				reportTicker.Reset(time.Duration(newSettings.ReportInterval) * time.Second)
+			}
+			settings = newSettings
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// Apply overrides the reporter's own settings with the non-empty fields of
+// the given ones. It is safe to call while Run is active.
+func (r *Reporter) Apply(remote model.AgentSettings) {
+	r.mu.Lock()
+	r.remote = remote
+	r.mu.Unlock()
+
+	r.update()
+}
+
+// Configure replaces the reporter's own settings, keeping the overrides set
+// by Apply. It is safe to call while Run is active.
+func (r *Reporter) Configure(base Settings) {
+	r.mu.Lock()
+	r.base = base
+	r.mu.Unlock()
+
+	r.update()
+}
+
+func (r *Reporter) update() {
+	r.mu.Lock()
+	settings := r.base
+	if r.remote.PollInterval > 0 {
+		settings.PollInterval = r.remote.PollInterval
+	}
+	if r.remote.ReportInterval > 0 {
+		settings.ReportInterval = r.remote.ReportInterval
+	}
+	if r.remote.Collectors != nil {
+		settings.Collectors = r.remote.Collectors
+	}
+	if r.remote.Include != nil {
+		settings.Include = r.remote.Include
+	}
+	if r.remote.Exclude != nil {
+		settings.Exclude = r.remote.Exclude
+	}
+
+	previous := r.settings
+	r.settings = settings
+	r.mu.Unlock()
+
+	// drop deltas of the collectors that were switched off so they are not
+	// reported when the collector comes back
+	for _, collector := range r.collectors {
+		if isEnabled(previous, collector.Name) && !isEnabled(settings, collector.Name) {
+			for _, counterMetric := range collector.Counters {
+				if resetter, ok := counterMetric.(StatsResetter); ok {
+					resetter.ResetStats()
+				}
+			}
+		}
+	}
+
+	logger.Log.Info("Reporter settings applied",
+		logger.Any("poll_interval", settings.PollInterval),
+		logger.Any("report_interval", settings.ReportInterval),
+		logger.Any("collectors", settings.Collectors),
+	)
+
+	select {
+	case r.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Reporter) getSettings() Settings {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.settings
+}
+
+func (r *Reporter) enabledCollectors() []Collector {
+	settings := r.getSettings()
+
+	var collectors []Collector
+	for _, collector := range r.collectors {
+		if isEnabled(settings, collector.Name) {
+			collectors = append(collectors, collector)
+		}
+	}
+
+	return collectors
+}
+
 func (r *Reporter) pollMetrics() {
 	logger.Log.Info("Starting polling metrics...")
 	waitGroup := sync.WaitGroup{}
-	waitGroup.Add(2)
 
-	go func() {
-		defer waitGroup.Done()
-		r.counterMetric.PollStats()
-	}()
+	for _, collector := range r.enabledCollectors() {
+		for _, counterMetric := range collector.Counters {
+			waitGroup.Add(1)
+			go func() {
+				defer waitGroup.Done()
+				counterMetric.PollStats()
+			}()
+		}
 
-	go func() {
-		defer waitGroup.Done()
-		r.gaugeMetric.PollStats()
-	}()
+		for _, gaugeMetric := range collector.Gauges {
+			waitGroup.Add(1)
+			go func() {
+				defer waitGroup.Done()
+				gaugeMetric.PollStats()
+			}()
+		}
+	}
 
 	waitGroup.Wait()
 
@@ -86,23 +226,39 @@ func (r *Reporter) reportMetrics(ctx context.Context) {
 	logger.Log.Info("Starting reporting metrics...")
 	waitGroup := sync.WaitGroup{}
 
-	var counters []model.Metrics
-	for key, value := range r.counterMetric.GetStats() {
-		counters = append(counters, model.Metrics{
-			ID:    key,
-			Type:  r.counterMetric.GetName(),
-			Delta: &value,
-		})
+	settings := r.getSettings()
+	collectors := r.enabledCollectors()
 
+	var counters []model.Metrics
+	for _, collector := range collectors {
+		for _, counterMetric := range collector.Counters {
+			for key, value := range counterMetric.GetStats() {
+				if !isAllowed(settings, key) {
+					continue
+				}
+				counters = append(counters, model.Metrics{
+					ID:    key,
+					Type:  counterMetric.GetName(),
+					Delta: &value,
+				})
+			}
+		}
 	}
 
 	var gauges []model.Metrics
-	for key, value := range r.gaugeMetric.GetStats() {
-		gauges = append(gauges, model.Metrics{
-			ID:    key,
-			Type:  r.gaugeMetric.GetName(),
-			Value: &value,
-		})
+	for _, collector := range collectors {
+		for _, gaugeMetric := range collector.Gauges {
+			for key, value := range gaugeMetric.GetStats() {
+				if !isAllowed(settings, key) {
+					continue
+				}
+				gauges = append(gauges, model.Metrics{
+					ID:    key,
+					Type:  gaugeMetric.GetName(),
+					Value: &value,
+				})
+			}
+		}
 	}
 
 	if len(counters) > 0 {
@@ -114,6 +270,14 @@ func (r *Reporter) reportMetrics(ctx context.Context) {
 				logger.Log.Info("Error updating counters metric", logger.Error(err))
 				return
 			}
+
+			for _, collector := range collectors {
+				for _, counterMetric := range collector.Counters {
+					if resetter, ok := counterMetric.(StatsResetter); ok {
+						resetter.ResetStats()
+					}
+				}
+			}
 		}()
 	}
 
@@ -135,9 +299,12 @@ func (r *Reporter) reportMetrics(ctx context.Context) {
 }
 
 func (r *Reporter) updateMetrics(ctx context.Context, metrics []model.Metrics) error {
+	// retries keep the sequence so the server can drop a batch it already has
+	sequence := r.sequence.Add(1)
+
 	return retry.Retry(
 		func() error {
-			return r.client.UpdateMetric(ctx, metrics)
+			return r.client.UpdateMetric(ctx, sequence, metrics)
 		},
 		func(err error) bool {
 			var netErr net.Error
@@ -149,3 +316,34 @@ func (r *Reporter) updateMetrics(ctx context.Context, metrics []model.Metrics) e
 		[]time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second},
 	)
 }
+
+func isEnabled(settings Settings, name string) bool {
+	if settings.Collectors == nil {
+		return true
+	}
+
+	for _, collector := range settings.Collectors {
+		if collector == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isAllowed(settings Settings, name string) bool {
+	if len(settings.Include) > 0 && !matchAny(settings.Include, name) {
+		return false
+	}
+
+	return !matchAny(settings.Exclude, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
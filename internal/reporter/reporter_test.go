@@ -0,0 +1,94 @@
+package reporter
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+)
+
+type updater struct {
+	mu      sync.Mutex
+	batches [][]model.Metrics
+}
+
+func (u *updater) UpdateMetric(_ context.Context, _ int64, metrics []model.Metrics) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.batches = append(u.batches, metrics)
+	return nil
+}
+
+func (u *updater) ids() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var ids []string
+	for _, batch := range u.batches {
+		for _, m := range batch {
+			ids = append(ids, m.ID)
+		}
+	}
+	sort.Strings(ids)
+	u.batches = nil
+	return ids
+}
+
+type gauges map[string]float64
+
+func (g gauges) GetName() string              { return "gauge" }
+func (g gauges) PollStats()                   {}
+func (g gauges) GetStats() map[string]float64 { return g }
+
+func TestReporter_Apply(t *testing.T) {
+	client := &updater{}
+	r := New(client, Settings{Timer: Timer{PollInterval: 1, ReportInterval: 1}},
+		Collector{Name: "runtime", Gauges: []MetricReader[float64]{gauges{"Alloc": 1, "RandomValue": 2}}},
+		Collector{Name: "probe", Gauges: []MetricReader[float64]{gauges{"probe.api.up": 1}}},
+	)
+
+	tests := []struct {
+		name     string
+		settings model.AgentSettings
+		want     []string
+	}{
+		{"Local settings", model.AgentSettings{}, []string{"Alloc", "RandomValue", "probe.api.up"}},
+		{"Collectors", model.AgentSettings{Collectors: []string{"probe"}}, []string{"probe.api.up"}},
+		{"Exclude", model.AgentSettings{Exclude: []string{"Random*"}}, []string{"Alloc", "probe.api.up"}},
+		{"Include", model.AgentSettings{Include: []string{"probe.*"}}, []string{"probe.api.up"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r.Apply(tt.settings)
+			r.reportMetrics(context.Background())
+
+			assert.Equal(t, tt.want, client.ids())
+		})
+	}
+}
+
+func TestReporter_Run_Apply(t *testing.T) {
+	client := &updater{}
+	r := New(client, Settings{Timer: Timer{PollInterval: 3600, ReportInterval: 3600}},
+		Collector{Name: "runtime", Gauges: []MetricReader[float64]{gauges{"Alloc": 1}}},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	r.Apply(model.AgentSettings{PollInterval: 1, ReportInterval: 1})
+
+	assert.Eventually(t, func() bool {
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		return len(client.batches) > 0
+	}, 3*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,41 @@
+package reporter
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/c2pc/go-musthave-metrics/internal/logger"
+	"github.com/c2pc/go-musthave-metrics/internal/model"
+)
+
+type SettingsFetcher interface {
+	// GetSettings returns nil settings when they have not changed since etag.
+	GetSettings(ctx context.Context, agentID string, etag string) (*model.AgentSettings, string, error)
+}
+
+// WatchSettings fetches the agent settings from the server right away and
+// then every interval, applying them whenever they change.
+func (r *Reporter) WatchSettings(ctx context.Context, fetcher SettingsFetcher, agentID string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var etag string
+	var applied *model.AgentSettings
+	for {
+		settings, newETag, err := fetcher.GetSettings(ctx, agentID, etag)
+		if err != nil {
+			logger.Log.Info("Error fetching agent settings", logger.Error(err))
+		} else if settings != nil && (applied == nil || !reflect.DeepEqual(*applied, *settings)) {
+			etag = newETag
+			applied = settings
+			r.Apply(*settings)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
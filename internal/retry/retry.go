@@ -3,6 +3,7 @@ package retry
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"time"
 )
 
@@ -27,3 +28,17 @@ func Retry(fn func() error, needRetry func(error) bool, delays []time.Duration)
 
 	return ErrMaxAttempts
 }
+
+// StatusError is the unexpected status code of an HTTP response.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.Code)
+}
+
+// Temporary reports whether the request may succeed when it is sent again.
+func (e *StatusError) Temporary() bool {
+	return e.Code >= http.StatusInternalServerError || e.Code == http.StatusTooManyRequests
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"net/http"
 )
 
@@ -9,16 +10,35 @@ type Server struct {
 	httpServer *http.Server
 }
 
-func NewServer(handler http.Handler, address string) *Server {
-	return &Server{
+type Option func(*Server)
+
+// WithTLS serves HTTPS with the config.
+func WithTLS(config *tls.Config) Option {
+	return func(s *Server) {
+		s.httpServer.TLSConfig = config
+	}
+}
+
+func NewServer(handler http.Handler, address string, opts ...Option) *Server {
+	s := &Server{
 		httpServer: &http.Server{
 			Addr:    address,
 			Handler: handler,
 		},
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 func (s *Server) ListenAndServe() error {
+	if s.httpServer.TLSConfig != nil {
+		// the certificates are already in the config
+		return s.httpServer.ListenAndServeTLS("", "")
+	}
 	return s.httpServer.ListenAndServe()
 }
 
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const batchPruneInterval = time.Minute
+
+// BatchStorage applies the batches of the agents to the gauge and counter
+// storages and remembers the batches applied recently for every agent so
+// that a retried batch is not applied twice.
+type BatchStorage struct {
+	storageType Type
+	mu          sync.Mutex
+	storage     map[string]map[int64]time.Time
+	db          Driver
+	gauges      *GaugeStorage
+	counters    *CounterStorage
+	ttl         time.Duration
+	lastPrune   time.Time
+}
+
+func NewBatchStorage(storageType Type, db Driver, ttl time.Duration, gauges *GaugeStorage, counters *CounterStorage) (*BatchStorage, error) {
+	if !storageType.IsValid() {
+		return nil, errors.New("invalid storage type")
+	}
+
+	if ttl <= 0 {
+		return nil, errors.New("invalid ttl")
+	}
+
+	if gauges == nil || counters == nil {
+		return nil, errors.New("invalid storages")
+	}
+
+	return &BatchStorage{
+		storageType: storageType,
+		storage:     make(map[string]map[int64]time.Time),
+		db:          db,
+		gauges:      gauges,
+		counters:    counters,
+		ttl:         ttl,
+		mu:          sync.Mutex{},
+	}, nil
+}
+
+// Apply stores the gauges and the counters of the batch unless the batch
+// has already been applied, it reports whether the batch was applied. The
+// batch is recorded together with its values, so a failed batch is never
+// recorded and a recorded batch is never applied twice.
+func (s *BatchStorage) Apply(ctx context.Context, agentID string, sequence int64, gauges []Valuer[float64], counters []Valuer[int64]) (bool, error) {
+	switch s.storageType {
+	case TypeDB:
+		return s.applyInDB(ctx, agentID, sequence, gauges, counters)
+	default:
+		return s.applyInMemory(agentID, sequence, gauges, counters)
+	}
+}
+
+func (s *BatchStorage) applyInDB(ctx context.Context, agentID string, sequence int64, gauges []Valuer[float64], counters []Valuer[int64]) (bool, error) {
+	if s.needPrune() {
+		_, err := s.db.ExecContext(ctx, `DELETE FROM batches WHERE created_at < $1`, time.Now().Add(-s.ttl))
+		if err != nil {
+			return false, err
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	// a concurrent retry of the batch waits on the row until this
+	// transaction ends, it is applied if this one is rolled back
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO batches (agent_id, sequence, created_at) VALUES ($1, $2, $3) ON CONFLICT (agent_id, sequence) DO NOTHING`,
+		agentID, sequence, time.Now())
+	if err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	if rows == 0 {
+		return false, tx.Rollback()
+	}
+
+	if err := s.gauges.saveInTx(ctx, tx, gauges...); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	if err := s.counters.saveInTx(ctx, tx, counters...); err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *BatchStorage) applyInMemory(agentID string, sequence int64, gauges []Valuer[float64], counters []Valuer[int64]) (bool, error) {
+	now := time.Now()
+
+	// the lock is held until the values are stored, so a retry of the batch
+	// cannot be acknowledged before the batch is applied
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.needPruneLocked(now) {
+		for id, batches := range s.storage {
+			for seq, createdAt := range batches {
+				if now.Sub(createdAt) > s.ttl {
+					delete(batches, seq)
+				}
+			}
+			if len(batches) == 0 {
+				delete(s.storage, id)
+			}
+		}
+	}
+
+	batches, ok := s.storage[agentID]
+	if !ok {
+		batches = make(map[int64]time.Time)
+		s.storage[agentID] = batches
+	}
+
+	if createdAt, ok := batches[sequence]; ok && now.Sub(createdAt) <= s.ttl {
+		return false, nil
+	}
+
+	if err := s.gauges.saveInMemory(gauges...); err != nil {
+		return false, err
+	}
+	if err := s.counters.saveInMemory(counters...); err != nil {
+		return false, err
+	}
+
+	batches[sequence] = now
+	return true, nil
+}
+
+func (s *BatchStorage) needPrune() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.needPruneLocked(time.Now())
+}
+
+func (s *BatchStorage) needPruneLocked(now time.Time) bool {
+	if now.Sub(s.lastPrune) < batchPruneInterval {
+		return false
+	}
+
+	s.lastPrune = now
+	return true
+}
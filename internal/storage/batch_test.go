@@ -0,0 +1,147 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/database"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+func TestBatchStorage_Apply_Memory(t *testing.T) {
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+	batchStorage, err := storage.NewBatchStorage(storage.TypeMemory, nil, time.Minute, gaugeStorage, counterStorage)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		agentID  string
+		sequence int64
+		applied  bool
+		counter  int64
+	}{
+		{"New batch", "agent1", 1, true, 5},
+		{"Duplicate", "agent1", 1, false, 5},
+		{"Next batch", "agent1", 2, true, 10},
+		{"Other agent", "agent2", 1, true, 15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied, err := batchStorage.Apply(context.Background(), tt.agentID, tt.sequence,
+				[]storage.Valuer[float64]{storage.Value[float64]{Key: "Alloc", Value: 1.5}},
+				[]storage.Valuer[int64]{storage.Value[int64]{Key: "PollCount", Value: 5}})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.applied, applied)
+
+			counter, err := counterStorage.Get(context.Background(), "PollCount")
+			require.NoError(t, err)
+			assert.Equal(t, tt.counter, counter)
+
+			gauge, err := gaugeStorage.Get(context.Background(), "Alloc")
+			require.NoError(t, err)
+			assert.Equal(t, 1.5, gauge)
+		})
+	}
+}
+
+func TestBatchStorage_Apply_DB(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	db := &database.DB{DB: mockDB}
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeDB, db)
+	require.NoError(t, err)
+	counterStorage, err := storage.NewCounterStorage(storage.TypeDB, db)
+	require.NoError(t, err)
+	batchStorage, err := storage.NewBatchStorage(storage.TypeDB, db, time.Minute, gaugeStorage, counterStorage)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		applied bool
+		err     error
+		mockgen func()
+	}{
+		{
+			name:    "New batch",
+			applied: true,
+			mockgen: func() {
+				mock.ExpectExec("^DELETE FROM batches WHERE created_at < (.+)$").
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectBegin()
+				mock.ExpectExec("^INSERT INTO batches (.+) VALUES (.+) ON CONFLICT (.+) DO NOTHING$").
+					WithArgs("agent1", 1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("^INSERT INTO gauges (.+) VALUES (.+) ON CONFLICT (.+)$").
+					WithArgs("Alloc", 1.5).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("^INSERT INTO counters (.+) VALUES (.+) ON CONFLICT (.+)$").
+					WithArgs("PollCount", 5).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:    "Duplicate",
+			applied: false,
+			mockgen: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("^INSERT INTO batches (.+) VALUES (.+) ON CONFLICT (.+) DO NOTHING$").
+					WithArgs("agent1", 1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+		},
+		{
+			name: "Values error",
+			err:  errors.New("some error"),
+			mockgen: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("^INSERT INTO batches (.+) VALUES (.+) ON CONFLICT (.+) DO NOTHING$").
+					WithArgs("agent1", 1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("^INSERT INTO gauges (.+) VALUES (.+) ON CONFLICT (.+)$").
+					WithArgs("Alloc", 1.5).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("^INSERT INTO counters (.+) VALUES (.+) ON CONFLICT (.+)$").
+					WithArgs("PollCount", 5).WillReturnError(errors.New("some error"))
+				mock.ExpectRollback()
+			},
+		},
+		{
+			name: "Batch error",
+			err:  errors.New("some error"),
+			mockgen: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec("^INSERT INTO batches (.+) VALUES (.+) ON CONFLICT (.+) DO NOTHING$").
+					WithArgs("agent1", 1, sqlmock.AnyArg()).WillReturnError(errors.New("some error"))
+				mock.ExpectRollback()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockgen()
+
+			applied, err := batchStorage.Apply(context.Background(), "agent1", 1,
+				[]storage.Valuer[float64]{storage.Value[float64]{Key: "Alloc", Value: 1.5}},
+				[]storage.Valuer[int64]{storage.Value[int64]{Key: "PollCount", Value: 5}})
+			if tt.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err.Error())
+			}
+			assert.Equal(t, tt.applied, applied)
+		})
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
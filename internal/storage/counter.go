@@ -2,16 +2,19 @@ package storage
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"strconv"
 	"sync"
+	"time"
 )
 
 type CounterStorage struct {
 	storageType Type
 	mu          sync.RWMutex
 	storage     map[string]int64
+	updated     map[string]time.Time
 	db          Driver
 }
 
@@ -23,6 +26,7 @@ func NewCounterStorage(storageType Type, db Driver) (*CounterStorage, error) {
 	return &CounterStorage{
 		storageType: storageType,
 		storage:     make(map[string]int64),
+		updated:     make(map[string]time.Time),
 		db:          db,
 		mu:          sync.RWMutex{},
 	}, nil
@@ -104,38 +108,94 @@ func (s *CounterStorage) Set(ctx context.Context, values ...Valuer[int64]) error
 }
 
 func (s *CounterStorage) saveInDB(ctx context.Context, values ...Valuer[int64]) error {
-	db, err := s.db.BeginTx(ctx, nil)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
+	if err := s.saveInTx(ctx, tx, values...); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// saveInTx stores the values within the transaction, it lets the values be
+// stored together with other changes.
+func (s *CounterStorage) saveInTx(ctx context.Context, tx *sql.Tx, values ...Valuer[int64]) error {
 	for _, value := range values {
-		_, err = s.db.ExecContext(ctx,
-			`INSERT INTO counters (key,value) VALUES ($1, $2) ON CONFLICT (key) DO UPDATE SET value = counters.value + excluded.value`, value.GetKey(), value.GetValue())
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO counters (key,value,updated_at) VALUES ($1, $2, now()) ON CONFLICT (key) DO UPDATE SET value = counters.value + excluded.value, updated_at = excluded.updated_at`, value.GetKey(), value.GetValue())
 		if err != nil {
-			_ = db.Rollback()
 			return err
 		}
 	}
 
-	return db.Commit()
+	return nil
 }
 
 func (s *CounterStorage) saveInMemory(values ...Valuer[int64]) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	now := time.Now()
 	for _, value := range values {
 		if val, ok := s.storage[value.GetKey()]; ok {
 			s.storage[value.GetKey()] = val + value.GetValue()
 		} else {
 			s.storage[value.GetKey()] = value.GetValue()
 		}
+		s.updated[value.GetKey()] = now
 	}
 
 	return nil
 }
 
+// Replace sets the counters to the given values instead of adding them.
+func (s *CounterStorage) Replace(ctx context.Context, values ...Valuer[int64]) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	switch s.storageType {
+	case TypeDB:
+		return s.replaceInDB(ctx, values...)
+	default:
+		s.replaceInMemory(values...)
+		return nil
+	}
+}
+
+func (s *CounterStorage) replaceInDB(ctx context.Context, values ...Valuer[int64]) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, value := range values {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO counters (key,value,updated_at) VALUES ($1, $2, now()) ON CONFLICT (key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`, value.GetKey(), value.GetValue())
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *CounterStorage) replaceInMemory(values ...Valuer[int64]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, value := range values {
+		s.storage[value.GetKey()] = value.GetValue()
+		s.updated[value.GetKey()] = now
+	}
+}
+
 func (s *CounterStorage) SetString(ctx context.Context, values ...Valuer[string]) error {
 	vs := make([]Valuer[int64], len(values))
 	for i, value := range values {
@@ -186,7 +246,228 @@ func (s *CounterStorage) getAllFromMemory() (map[string]int64, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	return s.storage, nil
+	// copy the map so that callers can range over it while it is updated
+	result := make(map[string]int64, len(s.storage))
+	for key, value := range s.storage {
+		result[key] = value
+	}
+
+	return result, nil
+}
+
+// Delete removes the metrics and returns how many of them existed.
+func (s *CounterStorage) Delete(ctx context.Context, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	switch s.storageType {
+	case TypeDB:
+		return s.deleteFromDB(ctx, keys...)
+	default:
+		return s.deleteFromMemory(keys...), nil
+	}
+}
+
+func (s *CounterStorage) deleteFromDB(ctx context.Context, keys ...string) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for _, key := range keys {
+		result, err := tx.ExecContext(ctx, `DELETE FROM counters WHERE key=$1`, key)
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+		deleted += affected
+	}
+
+	return deleted, tx.Commit()
+}
+
+func (s *CounterStorage) deleteFromMemory(keys ...string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for _, key := range keys {
+		if _, ok := s.storage[key]; ok {
+			delete(s.storage, key)
+			delete(s.updated, key)
+			deleted++
+		}
+	}
+
+	return deleted
+}
+
+// Expire removes the metrics that were last updated before the given time
+// and returns their keys.
+func (s *CounterStorage) Expire(ctx context.Context, before time.Time) ([]string, error) {
+	switch s.storageType {
+	case TypeDB:
+		return s.expireInDB(ctx, before)
+	default:
+		return s.expireInMemory(before), nil
+	}
+}
+
+func (s *CounterStorage) expireInDB(ctx context.Context, before time.Time) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `DELETE FROM counters WHERE updated_at < $1 RETURNING key`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return keys, nil
+}
+
+func (s *CounterStorage) expireInMemory(before time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for key, updated := range s.updated {
+		if updated.Before(before) {
+			delete(s.storage, key)
+			delete(s.updated, key)
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// GetAllUpdated returns the time of the last update of every metric.
+func (s *CounterStorage) GetAllUpdated(ctx context.Context) (map[string]time.Time, error) {
+	switch s.storageType {
+	case TypeDB:
+		return s.getAllUpdatedFromDB(ctx)
+	default:
+		return s.getAllUpdatedFromMemory(), nil
+	}
+}
+
+func (s *CounterStorage) getAllUpdatedFromDB(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT key, updated_at FROM counters`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]time.Time)
+	for rows.Next() {
+		var key string
+		var updated time.Time
+		if err := rows.Scan(&key, &updated); err != nil {
+			return nil, err
+		}
+		result[key] = updated
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return result, nil
+}
+
+func (s *CounterStorage) getAllUpdatedFromMemory() map[string]time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]time.Time, len(s.updated))
+	for key, updated := range s.updated {
+		result[key] = updated
+	}
+
+	return result
+}
+
+// SetUpdated overrides the time of the last update, it is used to restore
+// the metrics saved earlier. Unknown metrics are skipped.
+func (s *CounterStorage) SetUpdated(ctx context.Context, updated map[string]time.Time) error {
+	if len(updated) == 0 {
+		return nil
+	}
+
+	switch s.storageType {
+	case TypeDB:
+		return s.setUpdatedInDB(ctx, updated)
+	default:
+		s.setUpdatedInMemory(updated)
+		return nil
+	}
+}
+
+func (s *CounterStorage) setUpdatedInDB(ctx context.Context, updated map[string]time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for key, t := range updated {
+		if _, err := tx.ExecContext(ctx, `UPDATE counters SET updated_at=$2 WHERE key=$1`, key, t); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *CounterStorage) setUpdatedInMemory(updated map[string]time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, t := range updated {
+		if _, ok := s.storage[key]; ok {
+			s.updated[key] = t
+		}
+	}
+}
+
+// Aggregate computes the aggregation over the selected metrics, k limits
+// the number of metrics returned by topk.
+func (s *CounterStorage) Aggregate(ctx context.Context, selector Selector, aggregation Aggregation, k int) (AggregateResult, error) {
+	switch s.storageType {
+	case TypeDB:
+		return aggregateDB(ctx, s.db, "counters", selector, aggregation, k)
+	default:
+		all, err := s.getAllFromMemory()
+		if err != nil {
+			return AggregateResult{}, err
+		}
+
+		values := make(map[string]float64, len(all))
+		for key, value := range all {
+			values[key] = float64(value)
+		}
+
+		return aggregateMemory(values, selector, aggregation, k)
+	}
 }
 
 func (s *CounterStorage) GetAllString(ctx context.Context) (map[string]string, error) {
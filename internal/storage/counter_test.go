@@ -5,6 +5,7 @@ import (
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/c2pc/go-musthave-metrics/internal/database"
@@ -577,3 +578,135 @@ func TestCounterStorage_GetAllString(t *testing.T) {
 		})
 	}
 }
+
+func TestCounterStorage_Delete_Memory(t *testing.T) {
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, counterStorage.Set(ctx,
+		storage.Value[int64]{Key: "key1", Value: 1},
+		storage.Value[int64]{Key: "key2", Value: 2},
+	))
+
+	deleted, err := counterStorage.Delete(ctx, "key1", "unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	_, err = counterStorage.Get(ctx, "key1")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	updated, err := counterStorage.GetAllUpdated(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, updated, 1)
+	assert.Contains(t, updated, "key2")
+}
+
+func TestCounterStorage_Delete_DB(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	counterStorage, err := storage.NewCounterStorage(storage.TypeDB, &database.DB{DB: mockDB})
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^DELETE FROM counters WHERE key=\\$1$").WithArgs("key1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^DELETE FROM counters WHERE key=\\$1$").WithArgs("unknown").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	deleted, err := counterStorage.Delete(context.Background(), "key1", "unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCounterStorage_Replace_Memory(t *testing.T) {
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, counterStorage.Set(ctx, storage.Value[int64]{Key: "key1", Value: 5}))
+	assert.NoError(t, counterStorage.Replace(ctx,
+		storage.Value[int64]{Key: "key1", Value: 2},
+		storage.Value[int64]{Key: "key2", Value: 3},
+	))
+
+	all, err := counterStorage.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"key1": 2, "key2": 3}, all)
+
+	updated, err := counterStorage.GetAllUpdated(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, updated, 2)
+}
+
+func TestCounterStorage_Replace_DB(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	counterStorage, err := storage.NewCounterStorage(storage.TypeDB, &database.DB{DB: mockDB})
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^INSERT INTO counters .* DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at$").
+		WithArgs("key1", int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, counterStorage.Replace(context.Background(), storage.Value[int64]{Key: "key1", Value: 2}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCounterStorage_Expire_Memory(t *testing.T) {
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, counterStorage.Set(ctx,
+		storage.Value[int64]{Key: "stale", Value: 1},
+		storage.Value[int64]{Key: "fresh", Value: 2},
+	))
+
+	now := time.Now()
+	assert.NoError(t, counterStorage.SetUpdated(ctx, map[string]time.Time{
+		"stale":   now.Add(-time.Hour),
+		"unknown": now.Add(-time.Hour),
+	}))
+
+	keys, err := counterStorage.Expire(ctx, now.Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"stale"}, keys)
+
+	all, err := counterStorage.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"fresh": 2}, all)
+}
+
+func TestCounterStorage_Expire_DB(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	counterStorage, err := storage.NewCounterStorage(storage.TypeDB, &database.DB{DB: mockDB})
+	assert.NoError(t, err)
+
+	before := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("^DELETE FROM counters WHERE updated_at < \\$1 RETURNING key$").
+		WithArgs(before).
+		WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("stale"))
+
+	keys, err := counterStorage.Expire(context.Background(), before)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"stale"}, keys)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,15 +2,18 @@ package storage
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"strconv"
 	"sync"
+	"time"
 )
 
 type GaugeStorage struct {
 	storageType Type
 	mu          sync.RWMutex
 	storage     map[string]float64
+	updated     map[string]time.Time
 	db          Driver
 }
 
@@ -22,6 +25,7 @@ func NewGaugeStorage(storageType Type, db Driver) (*GaugeStorage, error) {
 	return &GaugeStorage{
 		storageType: storageType,
 		storage:     make(map[string]float64),
+		updated:     make(map[string]time.Time),
 		db:          db,
 		mu:          sync.RWMutex{},
 	}, nil
@@ -102,30 +106,47 @@ func (s *GaugeStorage) Set(ctx context.Context, values ...Valuer[float64]) error
 	}
 }
 
+// Replace is Set, the gauges are always replaced.
+func (s *GaugeStorage) Replace(ctx context.Context, values ...Valuer[float64]) error {
+	return s.Set(ctx, values...)
+}
+
 func (s *GaugeStorage) saveInDB(ctx context.Context, values ...Valuer[float64]) error {
-	db, err := s.db.BeginTx(ctx, nil)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
+	if err := s.saveInTx(ctx, tx, values...); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// saveInTx stores the values within the transaction, it lets the values be
+// stored together with other changes.
+func (s *GaugeStorage) saveInTx(ctx context.Context, tx *sql.Tx, values ...Valuer[float64]) error {
 	for _, value := range values {
-		_, err = s.db.ExecContext(ctx,
-			`INSERT INTO gauges (key,value) VALUES ($1, $2) ON CONFLICT (key) DO UPDATE SET value = excluded.value`, value.GetKey(), value.GetValue())
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO gauges (key,value,updated_at) VALUES ($1, $2, now()) ON CONFLICT (key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`, value.GetKey(), value.GetValue())
 		if err != nil {
-			_ = db.Rollback()
 			return err
 		}
 	}
 
-	return db.Commit()
+	return nil
 }
 
 func (s *GaugeStorage) saveInMemory(values ...Valuer[float64]) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	now := time.Now()
 	for _, value := range values {
 		s.storage[value.GetKey()] = value.GetValue()
+		s.updated[value.GetKey()] = now
 	}
 
 	return nil
@@ -181,7 +202,223 @@ func (s *GaugeStorage) getAllFromMemory() (map[string]float64, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	return s.storage, nil
+	// copy the map so that callers can range over it while it is updated
+	result := make(map[string]float64, len(s.storage))
+	for key, value := range s.storage {
+		result[key] = value
+	}
+
+	return result, nil
+}
+
+// Delete removes the metrics and returns how many of them existed.
+func (s *GaugeStorage) Delete(ctx context.Context, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	switch s.storageType {
+	case TypeDB:
+		return s.deleteFromDB(ctx, keys...)
+	default:
+		return s.deleteFromMemory(keys...), nil
+	}
+}
+
+func (s *GaugeStorage) deleteFromDB(ctx context.Context, keys ...string) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for _, key := range keys {
+		result, err := tx.ExecContext(ctx, `DELETE FROM gauges WHERE key=$1`, key)
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+		deleted += affected
+	}
+
+	return deleted, tx.Commit()
+}
+
+func (s *GaugeStorage) deleteFromMemory(keys ...string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for _, key := range keys {
+		if _, ok := s.storage[key]; ok {
+			delete(s.storage, key)
+			delete(s.updated, key)
+			deleted++
+		}
+	}
+
+	return deleted
+}
+
+// Expire removes the metrics that were last updated before the given time
+// and returns their keys.
+func (s *GaugeStorage) Expire(ctx context.Context, before time.Time) ([]string, error) {
+	switch s.storageType {
+	case TypeDB:
+		return s.expireInDB(ctx, before)
+	default:
+		return s.expireInMemory(before), nil
+	}
+}
+
+func (s *GaugeStorage) expireInDB(ctx context.Context, before time.Time) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `DELETE FROM gauges WHERE updated_at < $1 RETURNING key`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return keys, nil
+}
+
+func (s *GaugeStorage) expireInMemory(before time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for key, updated := range s.updated {
+		if updated.Before(before) {
+			delete(s.storage, key)
+			delete(s.updated, key)
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// GetAllUpdated returns the time of the last update of every metric.
+func (s *GaugeStorage) GetAllUpdated(ctx context.Context) (map[string]time.Time, error) {
+	switch s.storageType {
+	case TypeDB:
+		return s.getAllUpdatedFromDB(ctx)
+	default:
+		return s.getAllUpdatedFromMemory(), nil
+	}
+}
+
+func (s *GaugeStorage) getAllUpdatedFromDB(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT key, updated_at FROM gauges`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]time.Time)
+	for rows.Next() {
+		var key string
+		var updated time.Time
+		if err := rows.Scan(&key, &updated); err != nil {
+			return nil, err
+		}
+		result[key] = updated
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return result, nil
+}
+
+func (s *GaugeStorage) getAllUpdatedFromMemory() map[string]time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]time.Time, len(s.updated))
+	for key, updated := range s.updated {
+		result[key] = updated
+	}
+
+	return result
+}
+
+// SetUpdated overrides the time of the last update, it is used to restore
+// the metrics saved earlier. Unknown metrics are skipped.
+func (s *GaugeStorage) SetUpdated(ctx context.Context, updated map[string]time.Time) error {
+	if len(updated) == 0 {
+		return nil
+	}
+
+	switch s.storageType {
+	case TypeDB:
+		return s.setUpdatedInDB(ctx, updated)
+	default:
+		s.setUpdatedInMemory(updated)
+		return nil
+	}
+}
+
+func (s *GaugeStorage) setUpdatedInDB(ctx context.Context, updated map[string]time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for key, t := range updated {
+		if _, err := tx.ExecContext(ctx, `UPDATE gauges SET updated_at=$2 WHERE key=$1`, key, t); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *GaugeStorage) setUpdatedInMemory(updated map[string]time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, t := range updated {
+		if _, ok := s.storage[key]; ok {
+			s.updated[key] = t
+		}
+	}
+}
+
+// Aggregate computes the aggregation over the selected metrics, k limits
+// the number of metrics returned by topk.
+func (s *GaugeStorage) Aggregate(ctx context.Context, selector Selector, aggregation Aggregation, k int) (AggregateResult, error) {
+	switch s.storageType {
+	case TypeDB:
+		return aggregateDB(ctx, s.db, "gauges", selector, aggregation, k)
+	default:
+		all, err := s.getAllFromMemory()
+		if err != nil {
+			return AggregateResult{}, err
+		}
+
+		return aggregateMemory(all, selector, aggregation, k)
+	}
 }
 
 func (s *GaugeStorage) GetAllString(ctx context.Context) (map[string]string, error) {
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/c2pc/go-musthave-metrics/internal/database"
@@ -579,3 +580,95 @@ func TestGaugeStorage_GetAllString(t *testing.T) {
 		})
 	}
 }
+
+func TestGaugeStorage_Delete_Memory(t *testing.T) {
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, gaugeStorage.Set(ctx,
+		storage.Value[float64]{Key: "key1", Value: 1.5},
+		storage.Value[float64]{Key: "key2", Value: 2.5},
+	))
+
+	deleted, err := gaugeStorage.Delete(ctx, "key1", "unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	_, err = gaugeStorage.Get(ctx, "key1")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	updated, err := gaugeStorage.GetAllUpdated(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, updated, 1)
+	assert.Contains(t, updated, "key2")
+}
+
+func TestGaugeStorage_Delete_DB(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeDB, &database.DB{DB: mockDB})
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^DELETE FROM gauges WHERE key=\\$1$").WithArgs("key1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^DELETE FROM gauges WHERE key=\\$1$").WithArgs("unknown").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	deleted, err := gaugeStorage.Delete(context.Background(), "key1", "unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGaugeStorage_Expire_Memory(t *testing.T) {
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, gaugeStorage.Set(ctx,
+		storage.Value[float64]{Key: "stale", Value: 1.5},
+		storage.Value[float64]{Key: "fresh", Value: 2.5},
+	))
+
+	now := time.Now()
+	assert.NoError(t, gaugeStorage.SetUpdated(ctx, map[string]time.Time{
+		"stale":   now.Add(-time.Hour),
+		"unknown": now.Add(-time.Hour),
+	}))
+
+	keys, err := gaugeStorage.Expire(ctx, now.Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"stale"}, keys)
+
+	all, err := gaugeStorage.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{"fresh": 2.5}, all)
+}
+
+func TestGaugeStorage_Expire_DB(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeDB, &database.DB{DB: mockDB})
+	assert.NoError(t, err)
+
+	before := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("^DELETE FROM gauges WHERE updated_at < \\$1 RETURNING key$").
+		WithArgs(before).
+		WillReturnRows(sqlmock.NewRows([]string{"key"}).AddRow("stale"))
+
+	keys, err := gaugeStorage.Expire(context.Background(), before)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"stale"}, keys)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
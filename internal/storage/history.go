@@ -0,0 +1,475 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Point is one accepted update of a metric. Gauges keep the value that was
+// set, counters keep the delta that was added.
+type Point struct {
+	Type  string
+	ID    string
+	Time  time.Time
+	Value float64
+}
+
+type ring struct {
+	points []Point
+	next   int
+	full   bool
+}
+
+// add keeps the points ordered by time, a point older than the newest one
+// is inserted in place and is dropped when it is older than all the points
+// of a full ring.
+func (r *ring) add(point Point) {
+	points := r.ordered()
+	if len(points) == 0 || !point.Time.Before(points[len(points)-1].Time) {
+		r.points[r.next] = point
+		r.next = (r.next + 1) % len(r.points)
+		if r.next == 0 {
+			r.full = true
+		}
+		return
+	}
+
+	i := sort.Search(len(points), func(i int) bool {
+		return point.Time.Before(points[i].Time)
+	})
+	if r.full && i == 0 {
+		return
+	}
+
+	kept := make([]Point, 0, len(points)+1)
+	kept = append(append(append(kept, points[:i]...), point), points[i:]...)
+	if len(kept) > len(r.points) {
+		kept = kept[1:]
+	}
+	r.points = append(kept, make([]Point, len(r.points)-len(kept))...)
+	r.next = len(kept) % len(r.points)
+	r.full = len(kept) == len(r.points)
+}
+
+// ordered returns the points from the oldest to the newest.
+func (r *ring) ordered() []Point {
+	if !r.full {
+		return r.points[:r.next]
+	}
+	return append(append([]Point{}, r.points[r.next:]...), r.points[:r.next]...)
+}
+
+// dropBefore removes the points older than t.
+func (r *ring) dropBefore(t time.Time) {
+	points := r.ordered()
+	i := sort.Search(len(points), func(i int) bool {
+		return !points[i].Time.Before(t)
+	})
+	if i == 0 {
+		return
+	}
+
+	kept := append([]Point{}, points[i:]...)
+	r.points = append(kept, make([]Point, len(r.points)-len(kept))...)
+	r.next = len(kept) % len(r.points)
+	r.full = len(kept) == len(r.points)
+}
+
+// rollup is a bucket of the memory rollups, lastTime is the time of the
+// point of Last since the points may come out of order.
+type rollup struct {
+	Bucket
+	lastTime time.Time
+}
+
+func (r *rollup) add(point Point) {
+	last := r.Last
+	r.Merge(pointBucket(point))
+	if point.Time.Before(r.lastTime) {
+		r.Last = last
+		return
+	}
+	r.lastTime = point.Time
+}
+
+// HistoryStorage records the updates of every metric. In memory it keeps
+// the last points of each metric in a ring buffer of a fixed size.
+//
+// With retention tiers the points are also rolled up into buckets of every
+// tier resolution, and the data older than the tier retention is deleted by
+// Compact.
+type HistoryStorage struct {
+	storageType Type
+	mu          sync.RWMutex
+	storage     map[string]*ring
+	rollups     []map[string]map[int64]*rollup
+	size        int
+	tiers       []RetentionTier
+	compacted   []time.Time
+	db          Driver
+}
+
+func NewHistoryStorage(storageType Type, db Driver, size int, tiers []RetentionTier) (*HistoryStorage, error) {
+	if !storageType.IsValid() {
+		return nil, errors.New("invalid storage type")
+	}
+
+	if size <= 0 {
+		return nil, errors.New("invalid history size")
+	}
+
+	if err := validateRetentionTiers(tiers); err != nil {
+		return nil, err
+	}
+
+	rollups := make([]map[string]map[int64]*rollup, len(tiers))
+	for i := range rollups {
+		rollups[i] = make(map[string]map[int64]*rollup)
+	}
+
+	return &HistoryStorage{
+		storageType: storageType,
+		storage:     make(map[string]*ring),
+		rollups:     rollups,
+		size:        size,
+		tiers:       tiers,
+		compacted:   make([]time.Time, len(tiers)),
+		db:          db,
+		mu:          sync.RWMutex{},
+	}, nil
+}
+
+func (s *HistoryStorage) Add(ctx context.Context, points ...Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	switch s.storageType {
+	case TypeDB:
+		return s.addToDB(ctx, points...)
+	default:
+		s.addToMemory(points...)
+		return nil
+	}
+}
+
+func (s *HistoryStorage) addToDB(ctx context.Context, points ...Point) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, point := range points {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO history (type, key, time, value) VALUES ($1, $2, $3, $4)`, point.Type, point.ID, point.Time, point.Value)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *HistoryStorage) addToMemory(points ...Point) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, point := range points {
+		key := metricKey(point.Type, point.ID)
+		r, ok := s.storage[key]
+		if !ok {
+			r = &ring{points: make([]Point, s.size)}
+			s.storage[key] = r
+		}
+		r.add(point)
+
+		// the memory rollups are kept up to date on every point
+		for i, tier := range s.tiers {
+			if tier.Resolution == 0 {
+				continue
+			}
+
+			buckets, ok := s.rollups[i][key]
+			if !ok {
+				buckets = make(map[int64]*rollup)
+				s.rollups[i][key] = buckets
+			}
+
+			start := truncateTime(point.Time, tier.Resolution)
+			bucket, ok := buckets[start.Unix()]
+			if !ok {
+				bucket = &rollup{Bucket: Bucket{Type: point.Type, ID: point.ID, Time: start}}
+				buckets[start.Unix()] = bucket
+			}
+			bucket.add(point)
+		}
+	}
+}
+
+// Query returns the buckets of the metric in [from, to] ordered by time. The
+// tier is chosen from the range and the step, raw points are returned as
+// buckets of one.
+func (s *HistoryStorage) Query(ctx context.Context, metricType string, id string, from time.Time, to time.Time, step time.Duration) ([]Bucket, error) {
+	buckets, err := s.QueryMany(ctx, metricType, []string{id}, from, to, step)
+	if err != nil {
+		return nil, err
+	}
+	return buckets[id], nil
+}
+
+// QueryMany is Query for several metrics of the type in one query, the
+// buckets are keyed by the metric id and the metrics without any are left
+// out.
+func (s *HistoryStorage) QueryMany(ctx context.Context, metricType string, ids []string, from time.Time, to time.Time, step time.Duration) (map[string][]Bucket, error) {
+	if len(ids) == 0 {
+		return map[string][]Bucket{}, nil
+	}
+
+	tier := -1
+	if len(s.tiers) > 0 {
+		tier = pickTier(s.tiers, from, step, time.Now())
+	}
+
+	if tier < 0 || s.tiers[tier].Resolution == 0 {
+		var points []Point
+		var err error
+		switch s.storageType {
+		case TypeDB:
+			points, err = s.queryDB(ctx, metricType, ids, from, to)
+		default:
+			points = s.queryMemory(metricType, ids, from, to)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		buckets := make(map[string][]Bucket)
+		for _, point := range points {
+			buckets[point.ID] = append(buckets[point.ID], pointBucket(point))
+		}
+		return buckets, nil
+	}
+
+	from = truncateTime(from, s.tiers[tier].Resolution)
+	switch s.storageType {
+	case TypeDB:
+		return s.queryRollupsDB(ctx, tier, metricType, ids, from, to)
+	default:
+		return s.queryRollupsMemory(tier, metricType, ids, from, to), nil
+	}
+}
+
+// keysIn returns the SQL condition on the key column for the ids, the
+// placeholders are numbered after the first args.
+func keysIn(ids []string, args []interface{}) (string, []interface{}) {
+	placeholders := make([]string, len(ids))
+	for i, id := range ids {
+		args = append(args, id)
+		placeholders[i] = fmt.Sprintf("$%d", len(args))
+	}
+	return "key IN (" + strings.Join(placeholders, ", ") + ")", args
+}
+
+func (s *HistoryStorage) queryDB(ctx context.Context, metricType string, ids []string, from time.Time, to time.Time) ([]Point, error) {
+	keys, args := keysIn(ids, []interface{}{metricType, from, to})
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT key, time, value FROM history WHERE type=$1 AND `+keys+` AND time BETWEEN $2 AND $3 ORDER BY key, time`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []Point
+	for rows.Next() {
+		point := Point{Type: metricType}
+		if err := rows.Scan(&point.ID, &point.Time, &point.Value); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return points, nil
+}
+
+func (s *HistoryStorage) queryMemory(metricType string, ids []string, from time.Time, to time.Time) []Point {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var points []Point
+	for _, id := range ids {
+		r, ok := s.storage[metricKey(metricType, id)]
+		if !ok {
+			continue
+		}
+
+		for _, point := range r.ordered() {
+			if !point.Time.Before(from) && !point.Time.After(to) {
+				points = append(points, point)
+			}
+		}
+	}
+
+	return points
+}
+
+func (s *HistoryStorage) queryRollupsDB(ctx context.Context, tier int, metricType string, ids []string, from time.Time, to time.Time) (map[string][]Bucket, error) {
+	keys, args := keysIn(ids, []interface{}{int64(s.tiers[tier].Resolution / time.Second), metricType, from, to})
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT key, time, min, max, sum, count, last FROM history_rollups WHERE resolution=$1 AND type=$2 AND `+keys+` AND time BETWEEN $3 AND $4 ORDER BY key, time`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := make(map[string][]Bucket)
+	for rows.Next() {
+		bucket := Bucket{Type: metricType}
+		if err := rows.Scan(&bucket.ID, &bucket.Time, &bucket.Min, &bucket.Max, &bucket.Sum, &bucket.Count, &bucket.Last); err != nil {
+			return nil, err
+		}
+		buckets[bucket.ID] = append(buckets[bucket.ID], bucket)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return buckets, nil
+}
+
+func (s *HistoryStorage) queryRollupsMemory(tier int, metricType string, ids []string, from time.Time, to time.Time) map[string][]Bucket {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	buckets := make(map[string][]Bucket)
+	for _, id := range ids {
+		var metricBuckets []Bucket
+		for _, bucket := range s.rollups[tier][metricKey(metricType, id)] {
+			if !bucket.Time.Before(from) && !bucket.Time.After(to) {
+				metricBuckets = append(metricBuckets, bucket.Bucket)
+			}
+		}
+		if len(metricBuckets) == 0 {
+			continue
+		}
+
+		sort.Slice(metricBuckets, func(i, j int) bool {
+			return metricBuckets[i].Time.Before(metricBuckets[j].Time)
+		})
+		buckets[id] = metricBuckets
+	}
+
+	return buckets
+}
+
+// Compact rolls the points up into the tiers and deletes the data that is
+// past the retention of its tier.
+func (s *HistoryStorage) Compact(ctx context.Context, now time.Time) error {
+	switch s.storageType {
+	case TypeDB:
+		return s.compactDB(ctx, now)
+	default:
+		s.compactMemory(now)
+		return nil
+	}
+}
+
+func (s *HistoryStorage) compactDB(ctx context.Context, now time.Time) error {
+	for i := 1; i < len(s.tiers); i++ {
+		resolution := int64(s.tiers[i].Resolution / time.Second)
+
+		// the last bucket rolled up may have been incomplete, so it is
+		// computed again
+		from := s.compacted[i]
+		if from.IsZero() {
+			from = now.Add(-s.tiers[i-1].Retention)
+		}
+		from = truncateTime(from, s.tiers[i].Resolution)
+
+		var err error
+		if s.tiers[i-1].Resolution == 0 {
+			_, err = s.db.ExecContext(ctx, `INSERT INTO history_rollups (resolution, type, key, time, min, max, sum, count, last)
+SELECT $1, type, key, to_timestamp(floor(extract(epoch from time) / $1) * $1) AS bucket,
+       min(value), max(value), sum(value), count(*), (array_agg(value ORDER BY time DESC))[1]
+FROM history WHERE time >= $2 AND time <= $3
+GROUP BY type, key, bucket
+ON CONFLICT (resolution, type, key, time) DO UPDATE SET
+    min = excluded.min, max = excluded.max, sum = excluded.sum, count = excluded.count, last = excluded.last`,
+				resolution, from, now)
+		} else {
+			_, err = s.db.ExecContext(ctx, `INSERT INTO history_rollups (resolution, type, key, time, min, max, sum, count, last)
+SELECT $1, type, key, to_timestamp(floor(extract(epoch from time) / $1) * $1) AS bucket,
+       min(min), max(max), sum(sum), sum(count), (array_agg(last ORDER BY time DESC))[1]
+FROM history_rollups WHERE resolution = $4 AND time >= $2 AND time <= $3
+GROUP BY type, key, bucket
+ON CONFLICT (resolution, type, key, time) DO UPDATE SET
+    min = excluded.min, max = excluded.max, sum = excluded.sum, count = excluded.count, last = excluded.last`,
+				resolution, from, now, int64(s.tiers[i-1].Resolution/time.Second))
+		}
+		if err != nil {
+			return err
+		}
+
+		s.compacted[i] = now
+	}
+
+	for _, tier := range s.tiers {
+		var err error
+		if tier.Resolution == 0 {
+			_, err = s.db.ExecContext(ctx, `DELETE FROM history WHERE time < $1`, now.Add(-tier.Retention))
+		} else {
+			_, err = s.db.ExecContext(ctx, `DELETE FROM history_rollups WHERE resolution = $1 AND time < $2`,
+				int64(tier.Resolution/time.Second), now.Add(-tier.Retention))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *HistoryStorage) compactMemory(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, tier := range s.tiers {
+		expired := now.Add(-tier.Retention)
+
+		if tier.Resolution == 0 {
+			for key, r := range s.storage {
+				r.dropBefore(expired)
+				if r.next == 0 && !r.full {
+					delete(s.storage, key)
+				}
+			}
+			continue
+		}
+
+		for key, buckets := range s.rollups[i] {
+			for start, bucket := range buckets {
+				if bucket.Time.Before(expired) {
+					delete(buckets, start)
+				}
+			}
+			if len(buckets) == 0 {
+				delete(s.rollups[i], key)
+			}
+		}
+	}
+}
+
+func metricKey(metricType string, id string) string {
+	return metricType + "/" + id
+}
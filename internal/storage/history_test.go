@@ -0,0 +1,168 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/database"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+func TestHistoryStorage_Memory(t *testing.T) {
+	historyStorage, err := storage.NewHistoryStorage(storage.TypeMemory, nil, 3, nil)
+	require.NoError(t, err)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	point := func(i int) storage.Point {
+		return storage.Point{Type: "gauge", ID: "Alloc", Time: start.Add(time.Duration(i) * time.Minute), Value: float64(i)}
+	}
+
+	ctx := context.Background()
+	require.NoError(t, historyStorage.Add(ctx, point(0), point(1)))
+	require.NoError(t, historyStorage.Add(ctx, storage.Point{Type: "counter", ID: "Alloc", Time: start, Value: 5}))
+
+	tests := []struct {
+		name string
+		add  []storage.Point
+		from time.Time
+		to   time.Time
+		want []storage.Point
+	}{
+		{"Not full", nil, start, start.Add(time.Hour), []storage.Point{point(0), point(1)}},
+		{"Wrapped", []storage.Point{point(2), point(3), point(4)}, start, start.Add(time.Hour), []storage.Point{point(2), point(3), point(4)}},
+		{"Range", nil, start.Add(3 * time.Minute), start.Add(3 * time.Minute), []storage.Point{point(3)}},
+		{"Empty range", nil, start.Add(time.Hour), start.Add(2 * time.Hour), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, historyStorage.Add(ctx, tt.add...))
+
+			got, err := historyStorage.Query(ctx, "gauge", "Alloc", tt.from, tt.to, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, pointBuckets(tt.want...), got)
+		})
+	}
+}
+
+func TestHistoryStorage_MemoryOutOfOrder(t *testing.T) {
+	tiers, err := storage.ParseRetentionTiers("raw=1h,1m=2h")
+	require.NoError(t, err)
+
+	historyStorage, err := storage.NewHistoryStorage(storage.TypeMemory, nil, 3, tiers)
+	require.NoError(t, err)
+
+	start := time.Now().Truncate(time.Minute).Add(-30 * time.Minute)
+	point := func(seconds int, value float64) storage.Point {
+		return storage.Point{Type: "gauge", ID: "Alloc", Time: start.Add(time.Duration(seconds) * time.Second), Value: value}
+	}
+
+	ctx := context.Background()
+	require.NoError(t, historyStorage.Add(ctx, point(30, 3), point(10, 1)))
+	require.NoError(t, historyStorage.Add(ctx, point(20, 2), point(40, 4)))
+	// older than every point of the full ring
+	require.NoError(t, historyStorage.Add(ctx, point(0, 0)))
+
+	got, err := historyStorage.Query(ctx, "gauge", "Alloc", start, start.Add(time.Hour), 0)
+	require.NoError(t, err)
+	assert.Equal(t, pointBuckets(point(20, 2), point(30, 3), point(40, 4)), got)
+
+	// the last value of the rollup is the latest by time
+	got, err = historyStorage.Query(ctx, "gauge", "Alloc", start.Add(-90*time.Minute), start.Add(time.Hour), 0)
+	require.NoError(t, err)
+	assert.Equal(t, []storage.Bucket{
+		{Type: "gauge", ID: "Alloc", Time: start.UTC(), Min: 0, Max: 4, Sum: 10, Count: 5, Last: 4},
+	}, got)
+
+	require.NoError(t, historyStorage.Compact(ctx, start.Add(time.Hour+35*time.Second)))
+
+	got, err = historyStorage.Query(ctx, "gauge", "Alloc", start, start.Add(time.Hour), 0)
+	require.NoError(t, err)
+	assert.Equal(t, pointBuckets(point(40, 4)), got)
+}
+
+func TestHistoryStorage_DB(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	historyStorage, err := storage.NewHistoryStorage(storage.TypeDB, &database.DB{DB: mockDB}, 1, nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	point := storage.Point{Type: "gauge", ID: "Alloc", Time: now, Value: 1.5}
+
+	t.Run("Add", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("^INSERT INTO history (.+) VALUES (.+)$").
+			WithArgs("gauge", "Alloc", now, 1.5).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, historyStorage.Add(ctx, point))
+	})
+
+	t.Run("Add error", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("^INSERT INTO history (.+) VALUES (.+)$").
+			WithArgs("gauge", "Alloc", now, 1.5).WillReturnError(errors.New("some error"))
+		mock.ExpectRollback()
+
+		assert.EqualError(t, historyStorage.Add(ctx, point), "some error")
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		mock.ExpectQuery("^SELECT key, time, value FROM history WHERE (.+) ORDER BY key, time$").
+			WithArgs("gauge", now, now, "Alloc").
+			WillReturnRows(sqlmock.NewRows([]string{"key", "time", "value"}).AddRow("Alloc", now, 1.5))
+
+		got, err := historyStorage.Query(ctx, "gauge", "Alloc", now, now, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, pointBuckets(point), got)
+	})
+
+	t.Run("QueryMany", func(t *testing.T) {
+		mock.ExpectQuery(`^SELECT key, time, value FROM history WHERE type=\$1 AND key IN \(\$4, \$5\) AND (.+) ORDER BY key, time$`).
+			WithArgs("gauge", now, now, "Alloc", "HeapSys").
+			WillReturnRows(sqlmock.NewRows([]string{"key", "time", "value"}).
+				AddRow("Alloc", now, 1.5).AddRow("HeapSys", now, 2.0))
+
+		got, err := historyStorage.QueryMany(ctx, "gauge", []string{"Alloc", "HeapSys"}, now, now, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string][]storage.Bucket{
+			"Alloc":   pointBuckets(point),
+			"HeapSys": pointBuckets(storage.Point{Type: "gauge", ID: "HeapSys", Time: now, Value: 2}),
+		}, got)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func pointBuckets(points ...storage.Point) []storage.Bucket {
+	if len(points) == 0 {
+		return nil
+	}
+
+	buckets := make([]storage.Bucket, len(points))
+	for i, point := range points {
+		buckets[i] = storage.Bucket{
+			Type:  point.Type,
+			ID:    point.ID,
+			Time:  point.Time,
+			Min:   point.Value,
+			Max:   point.Value,
+			Sum:   point.Value,
+			Count: 1,
+			Last:  point.Value,
+		}
+	}
+	return buckets
+}
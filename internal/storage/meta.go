@@ -0,0 +1,247 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Meta describes a metric for the people reading it.
+type Meta struct {
+	Type        string
+	ID          string
+	Unit        string
+	Description string
+	Owner       string
+	DisplayName string
+}
+
+type MetaStorage struct {
+	storageType Type
+	mu          sync.RWMutex
+	storage     map[string]Meta
+	db          Driver
+}
+
+func NewMetaStorage(storageType Type, db Driver) (*MetaStorage, error) {
+	if !storageType.IsValid() {
+		return nil, errors.New("invalid storage type")
+	}
+
+	return &MetaStorage{
+		storageType: storageType,
+		storage:     make(map[string]Meta),
+		db:          db,
+		mu:          sync.RWMutex{},
+	}, nil
+}
+
+func (s *MetaStorage) Get(ctx context.Context, metricType string, id string) (Meta, error) {
+	switch s.storageType {
+	case TypeDB:
+		return s.getFromDB(ctx, metricType, id)
+	default:
+		return s.getFromMemory(metricType, id)
+	}
+}
+
+func (s *MetaStorage) getFromDB(ctx context.Context, metricType string, id string) (Meta, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT unit, description, owner, display_name FROM metadata WHERE type=$1 AND key=$2 LIMIT 1`, metricType, id)
+	if err != nil {
+		return Meta{}, err
+	}
+	defer rows.Close()
+
+	meta := Meta{Type: metricType, ID: id}
+	if rows.Next() {
+		if err := rows.Scan(&meta.Unit, &meta.Description, &meta.Owner, &meta.DisplayName); err != nil {
+			return Meta{}, err
+		}
+	} else {
+		if rows.Err() != nil {
+			return Meta{}, rows.Err()
+		}
+		return Meta{}, ErrNotFound
+	}
+
+	return meta, nil
+}
+
+func (s *MetaStorage) getFromMemory(metricType string, id string) (Meta, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	meta, ok := s.storage[metricKey(metricType, id)]
+	if !ok {
+		return Meta{}, ErrNotFound
+	}
+
+	return meta, nil
+}
+
+// GetAll returns the metadata of every metric ordered by type and name.
+func (s *MetaStorage) GetAll(ctx context.Context) ([]Meta, error) {
+	switch s.storageType {
+	case TypeDB:
+		return s.getAllFromDB(ctx)
+	default:
+		return s.getAllFromMemory(), nil
+	}
+}
+
+func (s *MetaStorage) getAllFromDB(ctx context.Context) ([]Meta, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT type, key, unit, description, owner, display_name FROM metadata ORDER BY type, key`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metas []Meta
+	for rows.Next() {
+		var meta Meta
+		if err := rows.Scan(&meta.Type, &meta.ID, &meta.Unit, &meta.Description, &meta.Owner, &meta.DisplayName); err != nil {
+			return nil, err
+		}
+		metas = append(metas, meta)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return metas, nil
+}
+
+func (s *MetaStorage) getAllFromMemory() []Meta {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	metas := make([]Meta, 0, len(s.storage))
+	for _, meta := range s.storage {
+		metas = append(metas, meta)
+	}
+
+	sort.Slice(metas, func(i, j int) bool {
+		if metas[i].Type != metas[j].Type {
+			return metas[i].Type < metas[j].Type
+		}
+		return metas[i].ID < metas[j].ID
+	})
+
+	return metas
+}
+
+// Set replaces the metadata of the metrics.
+func (s *MetaStorage) Set(ctx context.Context, metas ...Meta) error {
+	if len(metas) == 0 {
+		return nil
+	}
+
+	switch s.storageType {
+	case TypeDB:
+		return s.saveInDB(ctx, metas...)
+	default:
+		s.saveInMemory(metas...)
+		return nil
+	}
+}
+
+func (s *MetaStorage) saveInDB(ctx context.Context, metas ...Meta) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, meta := range metas {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO metadata (type, key, unit, description, owner, display_name) VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (type, key) DO UPDATE SET unit = excluded.unit, description = excluded.description, owner = excluded.owner, display_name = excluded.display_name`,
+			meta.Type, meta.ID, meta.Unit, meta.Description, meta.Owner, meta.DisplayName)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *MetaStorage) saveInMemory(metas ...Meta) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, meta := range metas {
+		s.storage[metricKey(meta.Type, meta.ID)] = meta
+	}
+}
+
+// SetDefaults adds the metadata of the metrics that have none, the metadata
+// set earlier is kept.
+func (s *MetaStorage) SetDefaults(ctx context.Context, metas ...Meta) error {
+	if len(metas) == 0 {
+		return nil
+	}
+
+	switch s.storageType {
+	case TypeDB:
+		return s.saveDefaultsInDB(ctx, metas...)
+	default:
+		s.saveDefaultsInMemory(metas...)
+		return nil
+	}
+}
+
+func (s *MetaStorage) saveDefaultsInDB(ctx context.Context, metas ...Meta) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, meta := range metas {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO metadata (type, key, unit, description, owner, display_name) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (type, key) DO NOTHING`,
+			meta.Type, meta.ID, meta.Unit, meta.Description, meta.Owner, meta.DisplayName)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *MetaStorage) saveDefaultsInMemory(metas ...Meta) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, meta := range metas {
+		key := metricKey(meta.Type, meta.ID)
+		if _, ok := s.storage[key]; !ok {
+			s.storage[key] = meta
+		}
+	}
+}
+
+// Delete removes the metadata of the metric and reports whether it existed.
+func (s *MetaStorage) Delete(ctx context.Context, metricType string, id string) (bool, error) {
+	switch s.storageType {
+	case TypeDB:
+		result, err := s.db.ExecContext(ctx, `DELETE FROM metadata WHERE type=$1 AND key=$2`, metricType, id)
+		if err != nil {
+			return false, err
+		}
+		affected, err := result.RowsAffected()
+		return affected > 0, err
+	default:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		key := metricKey(metricType, id)
+		_, ok := s.storage[key]
+		delete(s.storage, key)
+		return ok, nil
+	}
+}
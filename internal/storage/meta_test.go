@@ -0,0 +1,125 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/database"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+func TestMetaStorage_Memory(t *testing.T) {
+	metaStorage, err := storage.NewMetaStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	alloc := storage.Meta{Type: "gauge", ID: "Alloc", Unit: "bytes", Description: "Allocated heap"}
+	pollCount := storage.Meta{Type: "counter", ID: "PollCount", Owner: "platform"}
+	require.NoError(t, metaStorage.Set(ctx, alloc, pollCount))
+
+	got, err := metaStorage.Get(ctx, "gauge", "Alloc")
+	assert.NoError(t, err)
+	assert.Equal(t, alloc, got)
+
+	_, err = metaStorage.Get(ctx, "counter", "Alloc")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	alloc.DisplayName = "Allocated"
+	require.NoError(t, metaStorage.Set(ctx, alloc))
+
+	// the defaults don't replace the metadata set earlier
+	heapSys := storage.Meta{Type: "gauge", ID: "HeapSys", Unit: "bytes"}
+	require.NoError(t, metaStorage.SetDefaults(ctx, storage.Meta{Type: "gauge", ID: "Alloc", Unit: "bytes"}, heapSys))
+
+	all, err := metaStorage.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.Meta{pollCount, alloc, heapSys}, all)
+
+	deleted, err := metaStorage.Delete(ctx, "counter", "PollCount")
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+
+	deleted, err = metaStorage.Delete(ctx, "counter", "PollCount")
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+}
+
+func TestMetaStorage_DB(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	metaStorage, err := storage.NewMetaStorage(storage.TypeDB, &database.DB{DB: mockDB})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	alloc := storage.Meta{Type: "gauge", ID: "Alloc", Unit: "bytes", Description: "Allocated heap", Owner: "platform", DisplayName: "Allocated"}
+
+	t.Run("Set", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("^INSERT INTO metadata (.+) VALUES (.+) ON CONFLICT (.+) DO UPDATE SET (.+)$").
+			WithArgs("gauge", "Alloc", "bytes", "Allocated heap", "platform", "Allocated").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, metaStorage.Set(ctx, alloc))
+	})
+
+	t.Run("SetDefaults", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec("^INSERT INTO metadata (.+) VALUES (.+) ON CONFLICT (.+) DO NOTHING$").
+			WithArgs("gauge", "Alloc", "bytes", "Allocated heap", "platform", "Allocated").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		assert.NoError(t, metaStorage.SetDefaults(ctx, alloc))
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		mock.ExpectQuery("^SELECT unit, description, owner, display_name FROM metadata WHERE (.+)$").
+			WithArgs("gauge", "Alloc").
+			WillReturnRows(sqlmock.NewRows([]string{"unit", "description", "owner", "display_name"}).
+				AddRow("bytes", "Allocated heap", "platform", "Allocated"))
+
+		got, err := metaStorage.Get(ctx, "gauge", "Alloc")
+		assert.NoError(t, err)
+		assert.Equal(t, alloc, got)
+	})
+
+	t.Run("Get not found", func(t *testing.T) {
+		mock.ExpectQuery("^SELECT unit, description, owner, display_name FROM metadata WHERE (.+)$").
+			WithArgs("gauge", "Unknown").
+			WillReturnRows(sqlmock.NewRows([]string{"unit", "description", "owner", "display_name"}))
+
+		_, err := metaStorage.Get(ctx, "gauge", "Unknown")
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("GetAll", func(t *testing.T) {
+		mock.ExpectQuery("^SELECT type, key, unit, description, owner, display_name FROM metadata ORDER BY type, key$").
+			WillReturnRows(sqlmock.NewRows([]string{"type", "key", "unit", "description", "owner", "display_name"}).
+				AddRow("gauge", "Alloc", "bytes", "Allocated heap", "platform", "Allocated"))
+
+		got, err := metaStorage.GetAll(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []storage.Meta{alloc}, got)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		mock.ExpectExec("^DELETE FROM metadata WHERE (.+)$").
+			WithArgs("gauge", "Alloc").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		deleted, err := metaStorage.Delete(ctx, "gauge", "Alloc")
+		assert.NoError(t, err)
+		assert.True(t, deleted)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
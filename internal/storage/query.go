@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type Aggregation string
+
+const (
+	AggregationSum   Aggregation = "sum"
+	AggregationAvg   Aggregation = "avg"
+	AggregationMin   Aggregation = "min"
+	AggregationMax   Aggregation = "max"
+	AggregationCount Aggregation = "count"
+	AggregationTopK  Aggregation = "topk"
+)
+
+func (a Aggregation) IsValid() bool {
+	switch a {
+	case AggregationSum, AggregationAvg, AggregationMin, AggregationMax, AggregationCount, AggregationTopK:
+		return true
+	default:
+		return false
+	}
+}
+
+// Selector picks the metrics by name. Glob supports * and ?, Regex is
+// matched against the whole name. Labels are the values the tags and
+// attributes of the metric put in its name, each must be whole dot separated
+// segments of the name. An empty selector picks every metric.
+type Selector struct {
+	Glob   string
+	Regex  string
+	Labels []string
+}
+
+func (s Selector) Validate() error {
+	if s.Glob != "" && s.Regex != "" {
+		return errors.New("both glob and regex are set")
+	}
+	if s.Regex != "" {
+		if _, err := regexp.Compile(s.Regex); err != nil {
+			return fmt.Errorf("invalid regex: %s", err)
+		}
+	}
+	for _, label := range s.Labels {
+		if label == "" || strings.HasPrefix(label, ".") || strings.HasSuffix(label, ".") {
+			return fmt.Errorf("invalid label: %q", label)
+		}
+	}
+	return nil
+}
+
+func (s Selector) matchLabels(key string) bool {
+	for _, label := range s.Labels {
+		if !strings.Contains("."+key+".", "."+label+".") {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Selector) regexp() (*regexp.Regexp, error) {
+	switch {
+	case s.Regex != "":
+		return regexp.Compile("^(?:" + s.Regex + ")$")
+	case s.Glob != "":
+		pattern := regexp.QuoteMeta(s.Glob)
+		pattern = strings.ReplaceAll(pattern, `\*`, ".*")
+		pattern = strings.ReplaceAll(pattern, `\?`, ".")
+		return regexp.Compile("^" + pattern + "$")
+	default:
+		return nil, nil
+	}
+}
+
+// where returns the SQL conditions on the key column with their arguments.
+func (s Selector) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	switch {
+	case s.Regex != "":
+		args = append(args, "^(?:"+s.Regex+")$")
+		conditions = append(conditions, fmt.Sprintf(`key ~ $%d`, len(args)))
+	case s.Glob != "":
+		args = append(args, globToLike.Replace(s.Glob))
+		conditions = append(conditions, fmt.Sprintf(`key LIKE $%d ESCAPE '\'`, len(args)))
+	}
+	for _, label := range s.Labels {
+		args = append(args, "%."+likeEscaper.Replace(label)+".%")
+		conditions = append(conditions, fmt.Sprintf(`'.' || key || '.' LIKE $%d ESCAPE '\'`, len(args)))
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+var (
+	likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	globToLike  = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`, `?`, `_`)
+)
+
+// AggregateResult holds Value for the aggregations over all the selected
+// metrics and Top for topk. Value is nil when there is nothing to average
+// or compare.
+type AggregateResult struct {
+	Value *float64
+	Top   []Value[float64]
+}
+
+func aggregateMemory(values map[string]float64, selector Selector, aggregation Aggregation, k int) (AggregateResult, error) {
+	if !aggregation.IsValid() {
+		return AggregateResult{}, fmt.Errorf("invalid aggregation: %s", aggregation)
+	}
+
+	re, err := selector.regexp()
+	if err != nil {
+		return AggregateResult{}, err
+	}
+
+	var selected []Value[float64]
+	for key, value := range values {
+		if (re == nil || re.MatchString(key)) && selector.matchLabels(key) {
+			selected = append(selected, Value[float64]{Key: key, Value: value})
+		}
+	}
+
+	result := AggregateResult{}
+	switch aggregation {
+	case AggregationTopK:
+		sort.Slice(selected, func(i, j int) bool {
+			if selected[i].Value != selected[j].Value {
+				return selected[i].Value > selected[j].Value
+			}
+			return selected[i].Key < selected[j].Key
+		})
+		result.Top = selected[:min(k, len(selected))]
+		return result, nil
+	case AggregationCount:
+		count := float64(len(selected))
+		result.Value = &count
+		return result, nil
+	case AggregationSum:
+		var sum float64
+		for _, value := range selected {
+			sum += value.Value
+		}
+		result.Value = &sum
+		return result, nil
+	}
+
+	if len(selected) == 0 {
+		return result, nil
+	}
+
+	value := selected[0].Value
+	for _, v := range selected[1:] {
+		switch aggregation {
+		case AggregationAvg:
+			value += v.Value
+		case AggregationMin:
+			value = min(value, v.Value)
+		case AggregationMax:
+			value = max(value, v.Value)
+		}
+	}
+	if aggregation == AggregationAvg {
+		value /= float64(len(selected))
+	}
+	result.Value = &value
+
+	return result, nil
+}
+
+func aggregateDB(ctx context.Context, db Driver, table string, selector Selector, aggregation Aggregation, k int) (AggregateResult, error) {
+	where, args := selector.where()
+
+	if aggregation == AggregationTopK {
+		args = append(args, k)
+		rows, err := db.QueryContext(ctx,
+			fmt.Sprintf(`SELECT key, value::double precision FROM %s%s ORDER BY value DESC, key LIMIT $%d`, table, where, len(args)), args...)
+		if err != nil {
+			return AggregateResult{}, err
+		}
+		defer rows.Close()
+
+		result := AggregateResult{}
+		for rows.Next() {
+			var value Value[float64]
+			if err := rows.Scan(&value.Key, &value.Value); err != nil {
+				return AggregateResult{}, err
+			}
+			result.Top = append(result.Top, value)
+		}
+
+		if rows.Err() != nil {
+			return AggregateResult{}, rows.Err()
+		}
+
+		return result, nil
+	}
+
+	var expression string
+	switch aggregation {
+	case AggregationSum:
+		expression = `COALESCE(SUM(value), 0)`
+	case AggregationAvg:
+		expression = `AVG(value)`
+	case AggregationMin:
+		expression = `MIN(value)`
+	case AggregationMax:
+		expression = `MAX(value)`
+	case AggregationCount:
+		expression = `COUNT(*)`
+	default:
+		return AggregateResult{}, fmt.Errorf("invalid aggregation: %s", aggregation)
+	}
+
+	rows, err := db.QueryContext(ctx,
+		fmt.Sprintf(`SELECT (%s)::double precision FROM %s%s`, expression, table, where), args...)
+	if err != nil {
+		return AggregateResult{}, err
+	}
+	defer rows.Close()
+
+	var value sql.NullFloat64
+	if rows.Next() {
+		if err := rows.Scan(&value); err != nil {
+			return AggregateResult{}, err
+		}
+	}
+
+	if rows.Err() != nil {
+		return AggregateResult{}, rows.Err()
+	}
+
+	result := AggregateResult{}
+	if value.Valid {
+		result.Value = &value.Float64
+	}
+
+	return result, nil
+}
@@ -0,0 +1,144 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/database"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+func TestGaugeStorage_AggregateMemory(t *testing.T) {
+	gaugeStorage, err := storage.NewGaugeStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, gaugeStorage.Set(ctx,
+		storage.Value[float64]{Key: "requests_ok", Value: 4},
+		storage.Value[float64]{Key: "requests_failed", Value: 1},
+		storage.Value[float64]{Key: "requests.total", Value: 5},
+		storage.Value[float64]{Key: "HeapAlloc", Value: 10},
+	))
+
+	value := func(v float64) *float64 {
+		return &v
+	}
+
+	tests := []struct {
+		name        string
+		selector    storage.Selector
+		aggregation storage.Aggregation
+		k           int
+		want        storage.AggregateResult
+	}{
+		{"Sum glob", storage.Selector{Glob: "requests_*"}, storage.AggregationSum, 0, storage.AggregateResult{Value: value(5)}},
+		{"Glob is literal", storage.Selector{Glob: "requests.*"}, storage.AggregationCount, 0, storage.AggregateResult{Value: value(1)}},
+		{"Avg regex", storage.Selector{Regex: "requests_(ok|failed)"}, storage.AggregationAvg, 0, storage.AggregateResult{Value: value(2.5)}},
+		{"Regex is anchored", storage.Selector{Regex: "Heap"}, storage.AggregationCount, 0, storage.AggregateResult{Value: value(0)}},
+		{"Min", storage.Selector{}, storage.AggregationMin, 0, storage.AggregateResult{Value: value(1)}},
+		{"Max", storage.Selector{Glob: "*Alloc"}, storage.AggregationMax, 0, storage.AggregateResult{Value: value(10)}},
+		{"Max of nothing", storage.Selector{Glob: "unknown"}, storage.AggregationMax, 0, storage.AggregateResult{}},
+		{"Sum of nothing", storage.Selector{Glob: "unknown"}, storage.AggregationSum, 0, storage.AggregateResult{Value: value(0)}},
+		{"Labels", storage.Selector{Labels: []string{"total"}}, storage.AggregationSum, 0, storage.AggregateResult{Value: value(5)}},
+		{"Labels are segments", storage.Selector{Labels: []string{"requests"}}, storage.AggregationCount, 0, storage.AggregateResult{Value: value(1)}},
+		{"Glob and labels", storage.Selector{Glob: "requests_*", Labels: []string{"total"}}, storage.AggregationCount, 0, storage.AggregateResult{Value: value(0)}},
+		{
+			name:        "TopK",
+			selector:    storage.Selector{Glob: "requests?*"},
+			aggregation: storage.AggregationTopK,
+			k:           2,
+			want: storage.AggregateResult{Top: []storage.Value[float64]{
+				{Key: "requests.total", Value: 5},
+				{Key: "requests_ok", Value: 4},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gaugeStorage.Aggregate(ctx, tt.selector, tt.aggregation, tt.k)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCounterStorage_AggregateMemory(t *testing.T) {
+	counterStorage, err := storage.NewCounterStorage(storage.TypeMemory, nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, counterStorage.Set(ctx,
+		storage.Value[int64]{Key: "requests_ok", Value: 4},
+		storage.Value[int64]{Key: "requests_failed", Value: 1},
+	))
+
+	got, err := counterStorage.Aggregate(ctx, storage.Selector{Glob: "requests_*"}, storage.AggregationSum, 0)
+	require.NoError(t, err)
+	require.NotNil(t, got.Value)
+	assert.Equal(t, 5.0, *got.Value)
+
+	_, err = counterStorage.Aggregate(ctx, storage.Selector{}, storage.Aggregation("median"), 0)
+	assert.Error(t, err)
+}
+
+func TestCounterStorage_AggregateDB(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	counterStorage, err := storage.NewCounterStorage(storage.TypeDB, &database.DB{DB: mockDB})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	t.Run("Sum glob", func(t *testing.T) {
+		mock.ExpectQuery(`^SELECT \(COALESCE\(SUM\(value\), 0\)\)::double precision FROM counters WHERE key LIKE \$1`).
+			WithArgs(`requests\_%`).
+			WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(5.0))
+
+		got, err := counterStorage.Aggregate(ctx, storage.Selector{Glob: "requests_*"}, storage.AggregationSum, 0)
+		assert.NoError(t, err)
+		require.NotNil(t, got.Value)
+		assert.Equal(t, 5.0, *got.Value)
+	})
+
+	t.Run("Avg of nothing", func(t *testing.T) {
+		mock.ExpectQuery(`^SELECT \(AVG\(value\)\)::double precision FROM counters WHERE key ~ \$1$`).
+			WithArgs(`^(?:requests_.+)$`).
+			WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(nil))
+
+		got, err := counterStorage.Aggregate(ctx, storage.Selector{Regex: "requests_.+"}, storage.AggregationAvg, 0)
+		assert.NoError(t, err)
+		assert.Nil(t, got.Value)
+	})
+
+	t.Run("Max labels", func(t *testing.T) {
+		mock.ExpectQuery(`^SELECT \(MAX\(value\)\)::double precision FROM counters WHERE key LIKE \$1 ESCAPE '\\' AND '\.' \|\| key \|\| '\.' LIKE \$2 ESCAPE '\\'$`).
+			WithArgs(`requests\_%`, `%.web\_1.%`).
+			WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(3.0))
+
+		got, err := counterStorage.Aggregate(ctx, storage.Selector{Glob: "requests_*", Labels: []string{"web_1"}}, storage.AggregationMax, 0)
+		assert.NoError(t, err)
+		require.NotNil(t, got.Value)
+		assert.Equal(t, 3.0, *got.Value)
+	})
+
+	t.Run("TopK", func(t *testing.T) {
+		mock.ExpectQuery(`^SELECT key, value::double precision FROM counters ORDER BY value DESC, key LIMIT \$1$`).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"key", "value"}).AddRow("requests_ok", 4.0).AddRow("requests_failed", 1.0))
+
+		got, err := counterStorage.Aggregate(ctx, storage.Selector{}, storage.AggregationTopK, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []storage.Value[float64]{{Key: "requests_ok", Value: 4}, {Key: "requests_failed", Value: 1}}, got.Top)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RetentionTier keeps the history at the given resolution for the given
+// time. A zero resolution stands for the raw points.
+type RetentionTier struct {
+	Resolution time.Duration
+	Retention  time.Duration
+}
+
+// ParseRetentionTiers parses tiers written as "raw=24h,1m=720h,1h=8760h".
+func ParseRetentionTiers(value string) ([]RetentionTier, error) {
+	var tiers []RetentionTier
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		resolution, retention, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid retention tier: %s", item)
+		}
+
+		tier := RetentionTier{}
+		if resolution != "raw" {
+			var err error
+			if tier.Resolution, err = time.ParseDuration(resolution); err != nil {
+				return nil, fmt.Errorf("invalid retention tier resolution: %s", resolution)
+			}
+		}
+
+		var err error
+		if tier.Retention, err = time.ParseDuration(retention); err != nil {
+			return nil, fmt.Errorf("invalid retention tier retention: %s", retention)
+		}
+
+		tiers = append(tiers, tier)
+	}
+
+	return tiers, validateRetentionTiers(tiers)
+}
+
+func validateRetentionTiers(tiers []RetentionTier) error {
+	for i, tier := range tiers {
+		if tier.Retention <= 0 {
+			return fmt.Errorf("retention tier %d: invalid retention", i)
+		}
+		if tier.Resolution < 0 || tier.Resolution%time.Second != 0 {
+			return fmt.Errorf("retention tier %d: resolution must be whole seconds", i)
+		}
+		if i == 0 {
+			if tier.Resolution != 0 {
+				return fmt.Errorf("retention tier %d: the first tier must be raw", i)
+			}
+			continue
+		}
+
+		previous := tiers[i-1]
+		if tier.Resolution <= previous.Resolution {
+			return fmt.Errorf("retention tier %d: resolutions must increase", i)
+		}
+		if previous.Resolution > 0 && tier.Resolution%previous.Resolution != 0 {
+			return fmt.Errorf("retention tier %d: resolution must be a multiple of the previous one", i)
+		}
+	}
+
+	return nil
+}
+
+// Bucket aggregates the points of a metric over an interval starting at
+// Time. A raw point is a bucket of one.
+type Bucket struct {
+	Type  string
+	ID    string
+	Time  time.Time
+	Min   float64
+	Max   float64
+	Sum   float64
+	Count int64
+	Last  float64
+}
+
+func pointBucket(point Point) Bucket {
+	return Bucket{
+		Type:  point.Type,
+		ID:    point.ID,
+		Time:  point.Time,
+		Min:   point.Value,
+		Max:   point.Value,
+		Sum:   point.Value,
+		Count: 1,
+		Last:  point.Value,
+	}
+}
+
+// Merge adds a later bucket of the same metric.
+func (b *Bucket) Merge(other Bucket) {
+	if b.Count == 0 {
+		*b = Bucket{Type: b.Type, ID: b.ID, Time: b.Time, Min: other.Min, Max: other.Max, Sum: other.Sum, Count: other.Count, Last: other.Last}
+		return
+	}
+
+	b.Min = min(b.Min, other.Min)
+	b.Max = max(b.Max, other.Max)
+	b.Sum += other.Sum
+	b.Count += other.Count
+	b.Last = other.Last
+}
+
+func truncateTime(t time.Time, resolution time.Duration) time.Time {
+	seconds := int64(resolution / time.Second)
+	return time.Unix(t.Unix()/seconds*seconds, 0).UTC()
+}
+
+// pickTier returns the coarsest tier that is not coarser than the step and
+// still holds from. When there is none it returns the finest tier holding
+// from, or else the tier that reaches furthest back.
+func pickTier(tiers []RetentionTier, from time.Time, step time.Duration, now time.Time) int {
+	picked := -1
+	for i, tier := range tiers {
+		if tier.Resolution <= step && !now.Add(-tier.Retention).After(from) {
+			picked = i
+		}
+	}
+	if picked >= 0 {
+		return picked
+	}
+
+	for i, tier := range tiers {
+		if !now.Add(-tier.Retention).After(from) {
+			return i
+		}
+	}
+
+	longest := 0
+	for i, tier := range tiers {
+		if tier.Retention > tiers[longest].Retention {
+			longest = i
+		}
+	}
+	return longest
+}
@@ -0,0 +1,170 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/c2pc/go-musthave-metrics/internal/database"
+	"github.com/c2pc/go-musthave-metrics/internal/storage"
+)
+
+func TestParseRetentionTiers(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []storage.RetentionTier
+		wantErr bool
+	}{
+		{
+			name:  "Default",
+			value: "raw=24h, 1m=720h, 1h=8760h",
+			want: []storage.RetentionTier{
+				{Resolution: 0, Retention: 24 * time.Hour},
+				{Resolution: time.Minute, Retention: 720 * time.Hour},
+				{Resolution: time.Hour, Retention: 8760 * time.Hour},
+			},
+		},
+		{name: "Raw only", value: "raw=1h", want: []storage.RetentionTier{{Retention: time.Hour}}},
+		{name: "Empty", value: "", want: nil},
+		{name: "Missing raw", value: "1m=1h", wantErr: true},
+		{name: "Not increasing", value: "raw=1h,1h=2h,1m=3h", wantErr: true},
+		{name: "Not a multiple", value: "raw=1h,1m=2h,90s=3h", wantErr: true},
+		{name: "Fractional second", value: "raw=1h,1500ms=2h", wantErr: true},
+		{name: "Zero retention", value: "raw=0s", wantErr: true},
+		{name: "Invalid resolution", value: "raw=1h,minute=2h", wantErr: true},
+		{name: "Missing retention", value: "raw", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := storage.ParseRetentionTiers(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHistoryStorage_MemoryRetention(t *testing.T) {
+	tiers, err := storage.ParseRetentionTiers("raw=1h,1m=2h,1h=24h")
+	require.NoError(t, err)
+
+	historyStorage, err := storage.NewHistoryStorage(storage.TypeMemory, nil, 100, tiers)
+	require.NoError(t, err)
+
+	now := time.Now()
+	old := now.Truncate(time.Minute).Add(-90 * time.Minute)
+	recent := now.Truncate(time.Minute).Add(-10 * time.Minute)
+
+	ctx := context.Background()
+	require.NoError(t, historyStorage.Add(ctx,
+		storage.Point{Type: "gauge", ID: "Alloc", Time: old.Add(10 * time.Second), Value: 1},
+		storage.Point{Type: "gauge", ID: "Alloc", Time: old.Add(20 * time.Second), Value: 3},
+		storage.Point{Type: "gauge", ID: "Alloc", Time: recent.Add(5 * time.Second), Value: 5},
+	))
+
+	t.Run("Raw tier", func(t *testing.T) {
+		got, err := historyStorage.Query(ctx, "gauge", "Alloc", now.Add(-30*time.Minute), now, 0)
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, 5.0, got[0].Last)
+	})
+
+	t.Run("Rollup tier", func(t *testing.T) {
+		got, err := historyStorage.Query(ctx, "gauge", "Alloc", now.Add(-100*time.Minute), now, 0)
+		require.NoError(t, err)
+		assert.Equal(t, []storage.Bucket{
+			{Type: "gauge", ID: "Alloc", Time: old.UTC(), Min: 1, Max: 3, Sum: 4, Count: 2, Last: 3},
+			{Type: "gauge", ID: "Alloc", Time: recent.UTC(), Min: 5, Max: 5, Sum: 5, Count: 1, Last: 5},
+		}, got)
+	})
+
+	t.Run("Compact", func(t *testing.T) {
+		require.NoError(t, historyStorage.Compact(ctx, now.Add(2*time.Hour)))
+
+		got, err := historyStorage.Query(ctx, "gauge", "Alloc", now.Add(-30*time.Minute), now, 0)
+		require.NoError(t, err)
+		assert.Empty(t, got)
+
+		got, err = historyStorage.Query(ctx, "gauge", "Alloc", now.Add(-100*time.Minute), now, 0)
+		require.NoError(t, err)
+		assert.Empty(t, got)
+
+		got, err = historyStorage.Query(ctx, "gauge", "Alloc", now.Add(-100*time.Minute), now, time.Hour)
+		require.NoError(t, err)
+		var count int64
+		for _, bucket := range got {
+			count += bucket.Count
+		}
+		assert.Equal(t, int64(3), count)
+	})
+}
+
+func TestHistoryStorage_DBRetention(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	tiers, err := storage.ParseRetentionTiers("raw=24h,1m=720h,1h=8760h")
+	require.NoError(t, err)
+
+	historyStorage, err := storage.NewHistoryStorage(storage.TypeDB, &database.DB{DB: mockDB}, 1, tiers)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 30, 15, 0, time.UTC)
+
+	expectCompact := func(now time.Time, rawFrom time.Time, minuteFrom time.Time) {
+		mock.ExpectExec("(?s)^INSERT INTO history_rollups .+ FROM history WHERE").
+			WithArgs(int64(60), rawFrom, now).WillReturnResult(sqlmock.NewResult(0, 10))
+		mock.ExpectExec("(?s)^INSERT INTO history_rollups .+ FROM history_rollups WHERE").
+			WithArgs(int64(3600), minuteFrom, now, int64(60)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("^DELETE FROM history WHERE").
+			WithArgs(now.Add(-24 * time.Hour)).WillReturnResult(sqlmock.NewResult(0, 5))
+		mock.ExpectExec("^DELETE FROM history_rollups WHERE").
+			WithArgs(int64(60), now.Add(-720*time.Hour)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("^DELETE FROM history_rollups WHERE").
+			WithArgs(int64(3600), now.Add(-8760*time.Hour)).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+
+	t.Run("First compact", func(t *testing.T) {
+		expectCompact(now,
+			time.Date(2023, 12, 31, 12, 30, 0, 0, time.UTC),
+			time.Date(2023, 12, 2, 12, 0, 0, 0, time.UTC))
+		assert.NoError(t, historyStorage.Compact(ctx, now))
+	})
+
+	t.Run("Next compact", func(t *testing.T) {
+		next := now.Add(time.Minute)
+		expectCompact(next,
+			time.Date(2024, 1, 1, 12, 30, 0, 0, time.UTC),
+			time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+		assert.NoError(t, historyStorage.Compact(ctx, next))
+	})
+
+	t.Run("Query rollups", func(t *testing.T) {
+		bucket := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		mock.ExpectQuery("^SELECT key, time, min, max, sum, count, last FROM history_rollups WHERE (.+) ORDER BY key, time$").
+			WithArgs(int64(3600), "gauge", sqlmock.AnyArg(), sqlmock.AnyArg(), "Alloc").
+			WillReturnRows(sqlmock.NewRows([]string{"key", "time", "min", "max", "sum", "count", "last"}).
+				AddRow("Alloc", bucket, 1.0, 3.0, 4.0, int64(2), 3.0))
+
+		got, err := historyStorage.Query(ctx, "gauge", "Alloc", time.Now().Add(-100*24*time.Hour), time.Now(), time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, []storage.Bucket{
+			{Type: "gauge", ID: "Alloc", Time: bucket, Min: 1, Max: 3, Sum: 4, Count: 2, Last: 3},
+		}, got)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,9 +3,9 @@ package sync
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -19,6 +19,8 @@ import (
 
 const (
 	separator = string("\t")
+	// metaName starts the lines holding the metadata of a metric as JSON
+	metaName = "meta"
 )
 
 type Storager interface {
@@ -26,20 +28,31 @@ type Storager interface {
 	GetString(ctx context.Context, key string) (string, error)
 	GetAllString(ctx context.Context) (map[string]string, error)
 	SetString(ctx context.Context, values ...storage.Valuer[string]) error
+	GetAllUpdated(ctx context.Context) (map[string]time.Time, error)
+	SetUpdated(ctx context.Context, updated map[string]time.Time) error
+}
+
+type MetaStorager interface {
+	GetAll(ctx context.Context) ([]storage.Meta, error)
+	Set(ctx context.Context, metas ...storage.Meta) error
 }
 
 type Sync struct {
-	file     *os.File
-	storages map[string]Storager
+	file      *os.File
+	storages  map[string]Storager
+	meta      MetaStorager
+	intervals chan time.Duration
 }
 
 type Config struct {
 	StoreInterval   int64
 	FileStoragePath string
 	Restore         bool
+	// Meta is saved along with the metrics when set.
+	Meta MetaStorager
 }
 
-func Start(ctx context.Context, cfg Config, storages ...Storager) (io.Closer, error) {
+func Start(ctx context.Context, cfg Config, storages ...Storager) (*Sync, error) {
 	if len(storages) == 0 {
 		return nil, errors.New("no storages")
 	}
@@ -54,8 +67,10 @@ func Start(ctx context.Context, cfg Config, storages ...Storager) (io.Closer, er
 	}
 
 	s := &Sync{
-		file:     nil,
-		storages: storagesMap,
+		file:      nil,
+		storages:  storagesMap,
+		meta:      cfg.Meta,
+		intervals: make(chan time.Duration, 1),
 	}
 
 	err := s.openFile(cfg.FileStoragePath)
@@ -69,25 +84,51 @@ func Start(ctx context.Context, cfg Config, storages ...Storager) (io.Closer, er
 		}
 	}
 
-	if cfg.StoreInterval > 0 {
-		go s.listen(ctx, time.Duration(cfg.StoreInterval)*time.Second)
-	}
+	go s.listen(ctx, time.Duration(cfg.StoreInterval)*time.Second)
 
 	return s, nil
 }
 
+// SetStoreInterval changes the interval of the file store, 0 stops the
+// periodic saving.
+func (s *Sync) SetStoreInterval(storeInterval int64) error {
+	if storeInterval < 0 {
+		return errors.New("invalid store interval")
+	}
+
+	select {
+	case <-s.intervals:
+	default:
+	}
+	s.intervals <- time.Duration(storeInterval) * time.Second
+
+	return nil
+}
+
 func (s *Sync) Close() error {
 	return s.file.Close()
 }
 
 func (s *Sync) listen(ctx context.Context, interval time.Duration) {
-	ticker := time.NewTicker(interval)
+	ticker := time.NewTicker(time.Hour)
 	defer ticker.Stop()
 
+	if interval > 0 {
+		ticker.Reset(interval)
+	} else {
+		ticker.Stop()
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case interval = <-s.intervals:
+			if interval > 0 {
+				ticker.Reset(interval)
+			} else {
+				ticker.Stop()
+			}
 		case <-ticker.C:
 			err := s.saveDataToFile(ctx)
 			if err != nil {
@@ -108,6 +149,7 @@ func (s *Sync) restore(ctx context.Context) error {
 	}
 
 	var dataList []column
+	var metas []storage.Meta
 	scanner := bufio.NewScanner(s.file)
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -116,6 +158,15 @@ func (s *Sync) restore(ctx context.Context) error {
 			continue
 		}
 
+		if data, ok := strings.CutPrefix(line, metaName+separator); ok {
+			var meta storage.Meta
+			if err := json.Unmarshal([]byte(data), &meta); err != nil {
+				return errors.New("invalid line")
+			}
+			metas = append(metas, meta)
+			continue
+		}
+
 		d, err := s.lineToData(line)
 		if err != nil {
 			return err
@@ -132,6 +183,12 @@ func (s *Sync) restore(ctx context.Context) error {
 		return err
 	}
 
+	if s.meta != nil {
+		if err := s.meta.Set(ctx, metas...); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -146,6 +203,20 @@ func (s *Sync) saveDataToFile(ctx context.Context) error {
 		text = text + s.dataToLine(d) + "\n"
 	}
 
+	if s.meta != nil {
+		metas, err := s.meta.GetAll(ctx)
+		if err != nil {
+			return err
+		}
+		for _, meta := range metas {
+			data, err := json.Marshal(meta)
+			if err != nil {
+				return err
+			}
+			text = text + metaName + separator + string(data) + "\n"
+		}
+	}
+
 	err = s.writeToFile(text)
 	if err != nil {
 		return err
@@ -155,22 +226,38 @@ func (s *Sync) saveDataToFile(ctx context.Context) error {
 }
 
 type column struct {
-	name  string
-	key   string
-	value string
+	name    string
+	key     string
+	value   string
+	updated time.Time
 }
 
+// lineToData also accepts the lines written before the time of the last
+// update was saved.
 func (s *Sync) lineToData(line string) (*column, error) {
 	split := strings.Split(line, separator)
-	if len(split) != 3 {
+	if len(split) != 3 && len(split) != 4 {
 		return nil, errors.New("invalid line")
 	}
 
-	return &column{split[0], split[1], split[2]}, nil
+	data := &column{name: split[0], key: split[1], value: split[2]}
+	if len(split) == 4 {
+		updated, err := time.Parse(time.RFC3339Nano, split[3])
+		if err != nil {
+			return nil, errors.New("invalid line")
+		}
+		data.updated = updated
+	}
+
+	return data, nil
 }
 
 func (s *Sync) dataToLine(data column) string {
-	return fmt.Sprintf("%s%s%s%s%s", data.name, separator, data.key, separator, data.value)
+	line := fmt.Sprintf("%s%s%s%s%s", data.name, separator, data.key, separator, data.value)
+	if !data.updated.IsZero() {
+		line += separator + data.updated.Format(time.RFC3339Nano)
+	}
+	return line
 }
 
 func (s *Sync) readDataFromStorage(ctx context.Context) ([]column, error) {
@@ -180,8 +267,12 @@ func (s *Sync) readDataFromStorage(ctx context.Context) ([]column, error) {
 		if err != nil {
 			return nil, err
 		}
+		updated, err := storager.GetAllUpdated(ctx)
+		if err != nil {
+			return nil, err
+		}
 		for k, v := range data {
-			dataList = append(dataList, column{storager.GetName(), k, v})
+			dataList = append(dataList, column{storager.GetName(), k, v, updated[k]})
 		}
 	}
 	return dataList, nil
@@ -189,6 +280,7 @@ func (s *Sync) readDataFromStorage(ctx context.Context) ([]column, error) {
 
 func (s *Sync) writeDataToStorage(ctx context.Context, data ...column) error {
 	columns := map[string][]storage.Valuer[string]{}
+	updated := map[string]map[string]time.Time{}
 
 	for _, d := range data {
 		if _, ok := s.storages[d.name]; !ok {
@@ -200,6 +292,13 @@ func (s *Sync) writeDataToStorage(ctx context.Context, data ...column) error {
 		}
 
 		columns[d.name] = append(columns[d.name], storage.Value[string]{Key: d.key, Value: d.value})
+
+		if !d.updated.IsZero() {
+			if _, ok := updated[d.name]; !ok {
+				updated[d.name] = map[string]time.Time{}
+			}
+			updated[d.name][d.key] = d.updated
+		}
 	}
 
 	for key, value := range columns {
@@ -207,6 +306,11 @@ func (s *Sync) writeDataToStorage(ctx context.Context, data ...column) error {
 		if err != nil {
 			return err
 		}
+
+		err = s.storages[key].SetUpdated(ctx, updated[key])
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil